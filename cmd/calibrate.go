@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/audiolibrelab/jamcapture/internal/service"
+
+	"github.com/spf13/cobra"
+)
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate [monitor-channel]",
+	Short: "Measure the latency of a monitor channel",
+	Long: `Measure the delay a monitor channel needs, such as a browser heard over
+Bluetooth headphones. A test signal is played to the default output and recorded
+both by the monitor channel and by an input channel placed to hear it, such as a
+microphone next to the headphones. The offset between the two recordings can be
+written to the channel's delay in the config file.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		channel := ""
+		if len(args) == 1 {
+			channel = args[0]
+		}
+		input, _ := cmd.Flags().GetString("input")
+		save, _ := cmd.Flags().GetBool("yes")
+
+		svc := service.New(cfg, cfgFile, nil)
+
+		fmt.Println("Playing the test signal, keep quiet for a few seconds...")
+		result, err := svc.Calibrate(channel, input)
+		if err != nil {
+			return fmt.Errorf("calibration failed: %w", err)
+		}
+
+		fmt.Printf("Channel: %s (heard through %s)\n", result.Channel, result.Input)
+		fmt.Printf("Measured latency: %.2fms (confidence %.2f)\n", result.Latency, result.Confidence)
+		fmt.Printf("Current delay: %gms\n", result.CurrentDelay)
+
+		if result.Delay == result.CurrentDelay {
+			fmt.Println("The configured delay already matches")
+			return nil
+		}
+		if !save {
+			fmt.Printf("Write delay: %g to the config file? [y/N] ", result.Delay)
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			save = strings.EqualFold(strings.TrimSpace(answer), "y")
+		}
+		if !save {
+			return nil
+		}
+
+		updated, err := svc.SaveChannelDelay(profile, result.Channel, result.Delay)
+		if err != nil {
+			return fmt.Errorf("failed to save delay: %w", err)
+		}
+		fmt.Printf("Delay of %s set to %gms in %s\n", result.Channel, result.Delay, updated)
+		return nil
+	},
+}
+
+func init() {
+	calibrateCmd.Flags().StringP("input", "i", "", "input channel hearing the monitored output (default: first input channel)")
+	calibrateCmd.Flags().BoolP("yes", "y", false, "write the measured delay without asking")
+}
@@ -52,9 +52,9 @@ var infoCmd = &cobra.Command{
 		fmt.Printf("channels:\n")
 		for _, channel := range cfg.Channels {
 			inheritanceStatus := cfg.Inheritance.Channels[channel.Name]
-			fmt.Printf("  %s: volume=%.1f %s, delay=%d %s\n",
+			fmt.Printf("  %s: volume=%.1f %s, delay=%g %s\n",
 				channel.Name, channel.Volume, getInheritanceIndicator(inheritanceStatus.Volume),
-				channel.Delay, getInheritanceIndicator(inheritanceStatus.Delay))
+				channel.DelayMs(cfg.GetSampleRate()), getInheritanceIndicator(inheritanceStatus.Delay))
 		}
 
 		// Output configuration
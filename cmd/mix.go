@@ -38,13 +38,13 @@ and delay compensation for Bluetooth latency. Outputs a mixed FLAC file.`,
 			effectiveBackingVol = backingVol
 		}
 		if delay >= 0 {
-			effectiveDelay = delay
+			effectiveDelay = float64(delay)
 		}
 
 		fmt.Printf("Mixing song: %s\n", songName)
 		fmt.Printf("Guitar volume: %.1f\n", effectiveGuitarVol)
 		fmt.Printf("Backing volume: %.1f\n", effectiveBackingVol)
-		fmt.Printf("Backing track delay: %dms\n", effectiveDelay)
+		fmt.Printf("Backing track delay: %gms\n", effectiveDelay)
 
 		var err error
 		if guitarVol > 0 || backingVol > 0 || delay >= 0 {
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
 	"github.com/audiolibrelab/jamcapture/internal/service"
 
 	"github.com/spf13/cobra"
@@ -31,26 +35,54 @@ The recording will be saved as an MKV file with separate tracks for guitar and b
 		slog.Debug("Creating service instance")
 		svc := service.New(cfg, cfgFile, logWriter)
 
+		// The metronome's tempo and count-in can be overridden for this take
+		bpm, _ := cmd.Flags().GetFloat64("bpm")
+		var countIn *int
+		if cmd.Flags().Changed("count-in") {
+			bars, _ := cmd.Flags().GetInt("count-in")
+			countIn = &bars
+		}
+
 		// Start ready state - recording will start automatically when sources are available
 		slog.Info("Calling StartReady to begin source monitoring")
-		if err := svc.StartReady(songName); err != nil {
+		if err := svc.StartReadyWithMetronome(songName, bpm, countIn); err != nil {
 			slog.Error("StartReady failed", "error", err)
 			return fmt.Errorf("failed to start ready: %w", err)
 		}
 
-		slog.Info("Waiting for audio sources... Recording will start automatically - Press Ctrl+C to stop")
+		switch cfg.GetTrigger().Mode {
+		case config.TriggerManual:
+			slog.Info("Waiting for audio sources... Press Enter to start recording - Press Ctrl+C to stop")
+			go func() {
+				bufio.NewReader(os.Stdin).ReadString('\n')
+				if err := svc.StartRecording(); err != nil {
+					slog.Error("StartRecording failed", "error", err)
+				}
+			}()
+		case config.TriggerSignal:
+			slog.Info("Waiting for audio sources... Recording will start on signal - Press Ctrl+C to stop", "channel", cfg.GetTrigger().Channel)
+		default:
+			slog.Info("Waiting for audio sources... Recording will start automatically - Press Ctrl+C to stop")
+		}
 
 		// Handle interruption
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-		// Wait for interrupt signal
-		<-sigChan
-		slog.Info("Stopping recording...")
+		// Wait for interrupt signal, or for the session to end on its own
+		select {
+		case <-sigChan:
+			slog.Info("Stopping recording...")
 
-		// Stop recording
-		if err := svc.StopRecording(); err != nil {
-			return fmt.Errorf("failed to stop recording: %w", err)
+			// Stop recording
+			if err := svc.StopRecording(); err != nil {
+				return fmt.Errorf("failed to stop recording: %w", err)
+			}
+		case <-waitUnattendedStop(svc):
+			if reason := svc.GetLastError(); reason != "" {
+				return fmt.Errorf("recording ended: %s", reason)
+			}
+			slog.Info("Recording stopped on silence")
 		}
 
 		// Execute pipeline if specified
@@ -58,6 +90,24 @@ The recording will be saved as an MKV file with separate tracks for guitar and b
 	},
 }
 
+// waitUnattendedStop returns a channel closed when the session returns to
+// STANDBY on its own: auto_split stopped it after silence, or READY timed out
+func waitUnattendedStop(svc service.Service) <-chan struct{} {
+	stopped := make(chan struct{})
+
+	go func() {
+		for range time.Tick(time.Second) {
+			if status, _ := svc.GetRecordingStatus(); status == service.StatusStandby {
+				close(stopped)
+				return
+			}
+		}
+	}()
+	return stopped
+}
+
 func init() {
 	recordCmd.Flags().StringP("output", "o", "", "output directory (overrides config)")
+	recordCmd.Flags().Float64("bpm", 0, "metronome tempo (overrides config, enables the click)")
+	recordCmd.Flags().Int("count-in", 0, "bars of metronome count-in before the take (overrides config)")
 }
\ No newline at end of file
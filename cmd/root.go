@@ -98,6 +98,7 @@ func init() {
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(sourcesCmd)
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(calibrateCmd)
 }
 
 // setupLogging configures slog based on the verbose level
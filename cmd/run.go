@@ -74,13 +74,13 @@ If no pipeline is specified, will try to infer the action based on existing file
 					effectiveBackingVol = backingVol
 				}
 				if delay >= 0 {
-					effectiveDelay = delay
+					effectiveDelay = float64(delay)
 				}
 
 				fmt.Printf("Mixing song: %s\n", songName)
 				fmt.Printf("Guitar volume: %.1f\n", effectiveGuitarVol)
 				fmt.Printf("Backing volume: %.1f\n", effectiveBackingVol)
-				fmt.Printf("Backing track delay: %dms\n", effectiveDelay)
+				fmt.Printf("Backing track delay: %gms\n", effectiveDelay)
 
 				var err error
 				if guitarVol > 0 || backingVol > 0 || delay >= 0 {
@@ -131,6 +131,7 @@ func startServer(configPath string, port int, enableTray bool) error {
 		// Clean shutdown
 		cancel()
 		wg.Wait()
+		svc.Shutdown()
 
 		slog.Info("JamCapture stopped")
 		return nil
@@ -145,11 +146,24 @@ func startServer(configPath string, port int, enableTray bool) error {
 			return fmt.Errorf("failed to create server: %w", err)
 		}
 
-		// Start server (this blocks)
+		// Stop the recorders of the server on shutdown
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		stopped := make(chan struct{})
+		go func() {
+			<-sigChan
+			slog.Info("Received shutdown signal")
+			shutdownServer(srv)
+			close(stopped)
+		}()
+
+		// Start server (this blocks until shut down)
 		if err := srv.Start(); err != nil {
 			return fmt.Errorf("server failed: %w", err)
 		}
+		<-stopped
 
+		slog.Info("JamCapture stopped")
 		return nil
 	}
 }
@@ -176,9 +190,20 @@ func startWebServer(ctx context.Context, configFile string, port int) error {
 	select {
 	case <-ctx.Done():
 		slog.Info("Shutting down web server")
+		shutdownServer(srv)
 		return nil
 
 	case err := <-serverErr:
 		return err
 	}
-}
\ No newline at end of file
+}
+
+// shutdownServer gives the requests in flight a few seconds to finish, then
+// stops the server and its recorders
+func shutdownServer(srv *server.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("Web server did not shut down cleanly", "error", err)
+	}
+}
@@ -38,14 +38,34 @@ var sourcesCmd = &cobra.Command{
 	},
 }
 
-// listAvailableSources lists available audio sources using PipeWire backend
+// listAvailableSources lists available audio sources using the configured backend
 func listAvailableSources(cfg *config.Config) error {
 	fmt.Printf("🎵 Audio Sources (%s)\n", runtime.GOOS)
 	fmt.Printf("═══════════════════════════════════════\n\n")
 
+	if backend := audio.NewBackend(cfg); backend.GetType() == audio.BackendTypeFake {
+		return listFakeSources(backend)
+	}
+
 	return listPipeWireSources()
 }
 
+// listFakeSources lists the ports of the hardware-free fake backend
+func listFakeSources(backend audio.AudioBackend) error {
+	sources, err := backend.ListSources()
+	if err != nil {
+		return fmt.Errorf("failed to get fake sources: %w", err)
+	}
+
+	fmt.Printf("📋 FAKE SOURCES (%d found):\n", len(sources))
+	for i, source := range sources {
+		fmt.Printf("  %d. %s\n", i+1, source)
+	}
+	fmt.Println()
+
+	return nil
+}
+
 
 // listPipeWireSources lists available PipeWire/JACK sources
 func listPipeWireSources() error {
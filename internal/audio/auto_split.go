@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// silenceAction is what the auto-split policy asks the recorder to do
+type silenceAction int
+
+const (
+	silenceNone  silenceAction = iota
+	silenceSplit               // Close the take and start the next one
+	silenceStop                // Stop recording
+)
+
+// silenceDetector tracks how long every input channel has stayed below the
+// auto-split threshold. Monitor channels such as a backing track are ignored,
+// so a take is split when the musicians stop, not when the music does.
+type silenceDetector struct {
+	policy    config.AutoSplitConfig
+	inputs    map[string]bool
+	lastSound time.Time
+	heard     bool // Sound was heard since the current take started
+}
+
+// newSilenceDetector creates a detector for the input channels of a session
+func newSilenceDetector(policy config.AutoSplitConfig, channels []config.Channel, now time.Time) *silenceDetector {
+	inputs := make(map[string]bool)
+	for _, channel := range channels {
+		if channel.Type == "input" {
+			inputs[channel.Name] = true
+		}
+	}
+	return &silenceDetector{policy: policy, inputs: inputs, lastSound: now}
+}
+
+// update feeds the latest levels and returns the action due at now.
+// A take that stayed silent since it started is never split again, so a long
+// pause produces one take rather than a series of empty ones.
+func (d *silenceDetector) update(levels []ChannelLevel, now time.Time) silenceAction {
+	for _, level := range levels {
+		if d.inputs[level.Name] && level.Peak >= d.policy.Threshold {
+			d.lastSound = now
+			d.heard = true
+			return silenceNone
+		}
+	}
+
+	silent := now.Sub(d.lastSound)
+	if d.policy.StopAfter > 0 && silent >= time.Duration(d.policy.StopAfter*float64(time.Minute)) {
+		return silenceStop
+	}
+	if d.policy.Silence > 0 && d.heard && silent >= time.Duration(d.policy.Silence*float64(time.Second)) {
+		return silenceSplit
+	}
+	return silenceNone
+}
+
+// newTake resets the detector for a take that just started
+func (d *silenceDetector) newTake() {
+	d.heard = false
+}
+
+// takeFile returns the file of a take: the session file for the first take,
+// then song_take02.mkv, song_take03.mkv...
+func takeFile(firstFile string, number int) string {
+	if number <= 1 {
+		return firstFile
+	}
+	return fmt.Sprintf("%s_take%02d.mkv", strings.TrimSuffix(firstFile, ".mkv"), number)
+}
+
+// startTake appends a take to the session and makes it the output file
+func (s *SessionInfo) startTake(file string, start time.Time) {
+	s.Takes = append(s.Takes, TakeInfo{Number: len(s.Takes) + 1, File: file, StartTime: start})
+	s.OutputFile = file
+}
+
+// endTake records the duration of the current take
+func (s *SessionInfo) endTake(end time.Time) {
+	if len(s.Takes) == 0 {
+		return
+	}
+	take := &s.Takes[len(s.Takes)-1]
+	take.Duration = end.Sub(take.StartTime).Seconds()
+}
+
+// dropLastTake forgets the current take and returns its file, so that a take
+// holding nothing but the silence before an automatic stop can be removed.
+// The first take is always kept.
+func (s *SessionInfo) dropLastTake() (string, bool) {
+	if len(s.Takes) < 2 {
+		return "", false
+	}
+	file := s.Takes[len(s.Takes)-1].File
+	s.Takes = s.Takes[:len(s.Takes)-1]
+	s.OutputFile = s.Takes[len(s.Takes)-1].File
+	return file, true
+}
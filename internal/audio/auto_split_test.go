@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+func TestSilenceDetector_SplitsOnlyAfterSound(t *testing.T) {
+	policy := config.AutoSplitConfig{Silence: 2, Threshold: -50, StopAfter: 1}
+	channels := []config.Channel{
+		{Name: "guitar", Type: "input"},
+		{Name: "chrome", Type: "monitor"},
+	}
+	start := time.Now()
+	detector := newSilenceDetector(policy, channels, start)
+
+	quiet := []ChannelLevel{{Name: "guitar", Peak: -70}, {Name: "chrome", Peak: -6}}
+	loud := []ChannelLevel{{Name: "guitar", Peak: -12}, {Name: "chrome", Peak: -6}}
+
+	// A loud backing track alone is silence for the inputs, but nothing was heard yet
+	if action := detector.update(quiet, start.Add(5*time.Second)); action != silenceNone {
+		t.Errorf("Expected no split before any sound, got %v", action)
+	}
+
+	detector.update(loud, start.Add(10*time.Second))
+	if action := detector.update(quiet, start.Add(11*time.Second)); action != silenceNone {
+		t.Errorf("Expected no split before the silence length, got %v", action)
+	}
+	if action := detector.update(quiet, start.Add(12*time.Second)); action != silenceSplit {
+		t.Errorf("Expected a split after 2s of silence, got %v", action)
+	}
+
+	detector.newTake()
+	if action := detector.update(quiet, start.Add(30*time.Second)); action != silenceNone {
+		t.Errorf("Expected a silent take not to be split again, got %v", action)
+	}
+	if action := detector.update(quiet, start.Add(70*time.Second)); action != silenceStop {
+		t.Errorf("Expected a stop after a minute of silence, got %v", action)
+	}
+}
+
+func TestSessionInfo_Takes(t *testing.T) {
+	start := time.Now()
+	session := &SessionInfo{}
+	session.startTake("/rec/song.mkv", start)
+	session.endTake(start.Add(90 * time.Second))
+	session.startTake(takeFile("/rec/song.mkv", 2), start.Add(90*time.Second))
+
+	if session.OutputFile != "/rec/song_take02.mkv" {
+		t.Errorf("Expected the second take file to be current, got %s", session.OutputFile)
+	}
+	if session.Takes[0].Duration != 90 || session.Takes[1].Number != 2 {
+		t.Errorf("Unexpected takes: %+v", session.Takes)
+	}
+
+	if file, ok := session.dropLastTake(); !ok || file != "/rec/song_take02.mkv" {
+		t.Errorf("Expected the second take to be dropped, got %s %v", file, ok)
+	}
+	if _, ok := session.dropLastTake(); ok {
+		t.Error("Expected the first take to be kept")
+	}
+	if session.OutputFile != "/rec/song.mkv" {
+		t.Errorf("Expected the first take file to be current again, got %s", session.OutputFile)
+	}
+}
@@ -12,6 +12,7 @@ type BackendType string
 
 const (
 	BackendTypePipeWire BackendType = "pipewire"
+	BackendTypeFake     BackendType = "fake"
 	BackendTypeAuto     BackendType = "auto"
 )
 
@@ -32,16 +33,21 @@ type AudioBackend interface {
 
 // NewRecorder creates a recorder using the appropriate backend based on configuration
 func NewRecorder(cfg *config.Config, logWriter io.Writer) Recorder {
+	return NewBackend(cfg).NewRecorder(cfg, logWriter)
+}
+
+// NewBackend creates the audio backend selected by configuration
+func NewBackend(cfg *config.Config) AudioBackend {
 	backendType := determineBackend(cfg)
 
 	switch backendType {
+	case BackendTypeFake:
+		return NewFakeBackend(cfg)
 	case BackendTypePipeWire:
-		backend := &PipeWireBackend{}
-		return backend.NewRecorder(cfg, logWriter)
+		return &PipeWireBackend{}
 	default:
-		// Default to PipeWire as the only available backend
-		backend := &PipeWireBackend{}
-		return backend.NewRecorder(cfg, logWriter)
+		// Default to PipeWire for real audio hardware
+		return &PipeWireBackend{}
 	}
 }
 
@@ -52,12 +58,14 @@ func determineBackend(cfg *config.Config) BackendType {
 		switch strings.ToLower(cfg.Audio.Backend) {
 		case "pipewire":
 			return BackendTypePipeWire
+		case "fake", "file":
+			return BackendTypeFake
 		case "auto":
-			return BackendTypePipeWire // Only PipeWire is available now
+			return BackendTypePipeWire // PipeWire is the only hardware backend
 		}
 	}
 
-	// Only PipeWire backend is available
+	// PipeWire is the default backend
 	return BackendTypePipeWire
 }
 
@@ -0,0 +1,391 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+const (
+	// calibrationClient prefixes the capture clients of a calibration, so
+	// that it never links to the inputs of a recording session
+	calibrationClient = "jamcapture_calibrate"
+
+	// calibrationSignalChannel names the playback channel driving the test
+	// signal, which only serves to check that the monitor carried it
+	calibrationSignalChannel = "calibration_signal"
+
+	// calibrationChirp is the length of each sweep of the test signal
+	calibrationChirp = 100 * time.Millisecond
+
+	// calibrationLow and calibrationHigh are the frequencies the sweeps go
+	// through, wide enough for a sharp correlation peak
+	calibrationLow  = 300.0
+	calibrationHigh = 6000.0
+
+	// calibrationLevel is the peak level of the test signal
+	calibrationLevel = 0.5
+
+	// calibrationMaxLatency is the longest round trip the calibration finds
+	calibrationMaxLatency = time.Second
+
+	// calibrationMinConfidence is the correlation below which the input is
+	// assumed not to have heard the test signal
+	calibrationMinConfidence = 0.2
+)
+
+// calibrationGaps separates the sweeps of the test signal. Uneven gaps keep a
+// shifted copy of the signal from lining up with more than one sweep.
+var calibrationGaps = []time.Duration{500 * time.Millisecond, 700 * time.Millisecond, 600 * time.Millisecond, 800 * time.Millisecond}
+
+// CalibrationOptions selects the channels of a latency measurement
+type CalibrationOptions struct {
+	Channel string // Monitor channel whose delay is measured, the first one when empty
+	Input   string // Input channel hearing the monitored output, the first one when empty
+}
+
+// CalibrationResult is the latency measured for a monitor channel
+type CalibrationResult struct {
+	Channel      string  `json:"channel"`
+	Input        string  `json:"input"`
+	Latency      float64 `json:"latency_ms"`    // From the capture of the monitor's sources to the input, in milliseconds
+	Delay        float64 `json:"delay"`         // Latency rounded to a tenth of a millisecond for the channel's 'delay'
+	CurrentDelay float64 `json:"current_delay"` // The channel's delay in milliseconds when measured
+	Confidence   float64 `json:"confidence"`    // Normalized correlation peak, 0 to 1
+}
+
+// Calibrate measures the latency of a monitor channel. It plays a test signal
+// to the default output, which must be the monitored one, and records the
+// monitor channel from its configured sources along with an input channel,
+// such as a microphone next to the headphones. The offset between the two
+// recordings is the delay that lines the monitor track up with what the
+// musicians played.
+func Calibrate(cfg *config.Config, options CalibrationOptions, logWriter io.Writer) (*CalibrationResult, error) {
+	if determineBackend(cfg) != BackendTypePipeWire {
+		return nil, fmt.Errorf("calibration needs the pipewire audio backend, got: %s", cfg.Audio.Backend)
+	}
+
+	monitor, input, err := calibrationChannels(cfg.Channels, options)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "jamcapture-calibrate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calibration directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	signalFile := filepath.Join(dir, "signal.wav")
+	if err := writeCalibrationSignal(signalFile, cfg.GetSampleRate()); err != nil {
+		return nil, err
+	}
+
+	session := *cfg
+	session.Channels = calibrationSessionChannels(monitor, input)
+	session.Output.Directory = dir
+	session.AutoMix = false
+	session.Trigger, session.AutoSplit, session.Segments, session.Metronome = nil, nil, nil, nil
+
+	recorder := NewPipeWireRecorder(&session, logWriter)
+	recorder.SetClientName(calibrationClient)
+	recorder.SetBackingTrack(signalFile)
+	defer recorder.Cleanup()
+
+	slog.Info("Calibrating", "channel", monitor.Name, "input", input.Name)
+	if err := recorder.StartReady("calibration"); err != nil {
+		return nil, fmt.Errorf("failed to start calibration: %w", err)
+	}
+	if err := waitForRecording(recorder, session.GetReady().TimeoutDuration()); err != nil {
+		recorder.CancelReady()
+		return nil, err
+	}
+
+	time.Sleep(backingLeadIn + calibrationSignalLength() + calibrationMaxLatency + time.Second)
+	if err := recorder.Stop(); err != nil {
+		return nil, fmt.Errorf("failed to stop calibration: %w", err)
+	}
+
+	_, info := recorder.GetStatus()
+	var tracks [3][]float64
+	for i := range tracks {
+		if tracks[i], err = DecodeTrack(info.OutputFile, i, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	heard, captured, played := tracks[0], tracks[1], tracks[2]
+
+	// The monitor's sources must carry the signal for its latency to be measured
+	sampleRate := float64(cfg.GetSampleRate())
+	maxLag := int(calibrationMaxLatency.Seconds() * sampleRate)
+	if _, confidence := CrossCorrelate(played, captured, 0, maxLag); confidence < calibrationMinConfidence {
+		return nil, fmt.Errorf("the sources of %s did not carry the test signal (confidence %.2f) - they must capture the default output while calibrating", monitor.Name, confidence)
+	}
+
+	lag, confidence := CrossCorrelate(captured, heard, 0, maxLag)
+	if confidence < calibrationMinConfidence {
+		return nil, fmt.Errorf("%s did not clearly hear the test signal (confidence %.2f) - turn the monitored output up or move the microphone closer", input.Name, confidence)
+	}
+
+	latency := lag / sampleRate * 1000
+	result := &CalibrationResult{
+		Channel:      monitor.Name,
+		Input:        input.Name,
+		Latency:      math.Round(latency*100) / 100,
+		Delay:        math.Round(latency*10) / 10,
+		CurrentDelay: monitor.DelayMs(cfg.GetSampleRate()),
+		Confidence:   math.Round(confidence*100) / 100,
+	}
+	slog.Info("Calibration complete", "channel", result.Channel, "latency_ms", result.Latency, "confidence", result.Confidence)
+	return result, nil
+}
+
+// calibrationChannels picks the monitor channel measured and the input
+// channel hearing it
+func calibrationChannels(channels []config.Channel, options CalibrationOptions) (config.Channel, config.Channel, error) {
+	pick := func(name, channelType string) (config.Channel, error) {
+		for _, channel := range channels {
+			if (name == "" || channel.Name == name) && channel.Type == channelType {
+				return channel, nil
+			}
+		}
+		if name == "" {
+			return config.Channel{}, fmt.Errorf("calibration needs a '%s' channel in the profile", channelType)
+		}
+		return config.Channel{}, fmt.Errorf("no '%s' channel named '%s' in the profile", channelType, name)
+	}
+
+	monitor, err := pick(options.Channel, "monitor")
+	if err != nil {
+		return config.Channel{}, config.Channel{}, err
+	}
+	input, err := pick(options.Input, "input")
+	if err != nil {
+		return config.Channel{}, config.Channel{}, err
+	}
+	return monitor, input, nil
+}
+
+// calibrationSessionChannels returns the channels a calibration records: the
+// input, the monitor from its own sources, and a playback channel that plays
+// the test signal to the default output
+func calibrationSessionChannels(monitor, input config.Channel) []config.Channel {
+	return []config.Channel{
+		input,
+		monitor,
+		{Name: calibrationSignalChannel, AudioMode: "stereo", Type: config.ChannelTypePlayback, Volume: 1.0},
+	}
+}
+
+// waitForRecording waits for a calibration to find its input and monitor
+// sources and start
+func waitForRecording(recorder *PipeWireRecorder, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = config.DefaultReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		switch status, _ := recorder.GetStatus(); status {
+		case StatusRecording:
+			return nil
+		case StatusStandby, StatusError:
+			if err := recorder.GetLastError(); err != nil {
+				return fmt.Errorf("calibration did not start: %w", err)
+			}
+			return fmt.Errorf("calibration did not start")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("calibration sources did not appear within %s", timeout)
+}
+
+// calibrationSignalLength returns how long the test signal plays
+func calibrationSignalLength() time.Duration {
+	length := calibrationChirp
+	for _, gap := range calibrationGaps {
+		length += gap
+	}
+	return length
+}
+
+// calibrationSignal returns the test signal: logarithmic sweeps separated by
+// the calibration gaps, each faded in and out over 5ms
+func calibrationSignal(sampleRate int) []float64 {
+	rate := float64(sampleRate)
+	chirp := int(calibrationChirp.Seconds() * rate)
+	fade := int(0.005 * rate)
+	signal := make([]float64, int(calibrationSignalLength().Seconds()*rate))
+
+	sweep := make([]float64, chirp)
+	duration := calibrationChirp.Seconds()
+	growth := math.Log(calibrationHigh / calibrationLow)
+	for i := range sweep {
+		t := float64(i) / rate
+		phase := 2 * math.Pi * calibrationLow * duration / growth * (math.Exp(t/duration*growth) - 1)
+		envelope := math.Min(1, math.Min(float64(i)/float64(fade), float64(chirp-i)/float64(fade)))
+		sweep[i] = calibrationLevel * envelope * math.Sin(phase)
+	}
+
+	start := 0
+	for i := 0; i <= len(calibrationGaps); i++ {
+		copy(signal[start:], sweep)
+		if i < len(calibrationGaps) {
+			start += int(calibrationGaps[i].Seconds() * rate)
+		}
+	}
+	return signal
+}
+
+// writeCalibrationSignal writes the test signal as a mono float32 WAV file
+func writeCalibrationSignal(file string, sampleRate int) error {
+	signal := calibrationSignal(sampleRate)
+
+	data := make([]byte, 4*len(signal))
+	for i, sample := range signal {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(sample)))
+	}
+	content := append(wavHeader(sampleRate, 1, uint32(len(data))), data...)
+	if err := os.WriteFile(file, content, 0644); err != nil {
+		return fmt.Errorf("failed to write calibration signal: %w", err)
+	}
+	return nil
+}
+
+// DecodeTrack returns a track of a recording downmixed to mono, resampled to
+// sampleRate unless 0, and cut after limit unless 0
+func DecodeTrack(file string, stream, sampleRate int, limit time.Duration) ([]float64, error) {
+	args := []string{"-v", "error", "-i", file, "-map", fmt.Sprintf("0:a:%d", stream)}
+	if sampleRate > 0 {
+		args = append(args, "-ar", fmt.Sprintf("%d", sampleRate))
+	}
+	if limit > 0 {
+		args = append(args, "-t", fmt.Sprintf("%.3f", limit.Seconds()))
+	}
+	cmd := exec.Command("ffmpeg", append(args, "-f", "f32le", "-ac", "1", "-")...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to decode track %d of %s: %w", stream, filepath.Base(file), err)
+	}
+
+	var samples []float64
+	reader := bufio.NewReader(stdout)
+	sample := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, sample); err != nil {
+			break
+		}
+		samples = append(samples, float64(math.Float32frombits(binary.LittleEndian.Uint32(sample))))
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to decode track %d of %s: %w", stream, filepath.Base(file), err)
+	}
+	return samples, nil
+}
+
+// CrossCorrelate returns the lag, between minLag and maxLag frames, by which
+// signal is late on reference, and how alike they are at that lag from 0 to
+// 1. A negative lag means signal is early. The lag is refined between frames
+// from the shape of the peak.
+func CrossCorrelate(reference, signal []float64, minLag, maxLag int) (float64, float64) {
+	size := 1
+	for size < len(reference)+len(signal) {
+		size <<= 1
+	}
+
+	a := make([]complex128, size)
+	b := make([]complex128, size)
+	var referenceEnergy, signalEnergy float64
+	for i, sample := range reference {
+		a[i] = complex(sample, 0)
+		referenceEnergy += sample * sample
+	}
+	for i, sample := range signal {
+		b[i] = complex(sample, 0)
+		signalEnergy += sample * sample
+	}
+	if referenceEnergy == 0 || signalEnergy == 0 {
+		return 0, 0
+	}
+
+	// The correlation is the inverse transform of conj(A)·B
+	fft(a, false)
+	fft(b, false)
+	for i := range a {
+		a[i] = complex(real(a[i]), -imag(a[i])) * b[i]
+	}
+	fft(a, true)
+
+	// Negative lags wrap around to the end of the correlation
+	at := func(lag int) float64 {
+		return real(a[(lag%size+size)%size])
+	}
+	if limit := size/2 - 1; maxLag > limit {
+		maxLag = limit
+	}
+	if limit := -(size/2 - 1); minLag < limit {
+		minLag = limit
+	}
+	best := minLag
+	for lag := minLag + 1; lag <= maxLag; lag++ {
+		if at(lag) > at(best) {
+			best = lag
+		}
+	}
+
+	// A parabola through the peak and its neighbours finds its top
+	lag := float64(best)
+	if best > minLag && best < maxLag {
+		left, peak, right := at(best-1), at(best), at(best+1)
+		if curve := left - 2*peak + right; curve < 0 {
+			lag += 0.5 * (left - right) / curve
+		}
+	}
+
+	confidence := at(best) / float64(size) / math.Sqrt(referenceEnergy*signalEnergy)
+	return lag, math.Max(0, confidence)
+}
+
+// fft transforms x in place, its length a power of two. The inverse
+// transform is left unnormalized, multiplied by the length of x.
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		half := size / 2
+		for k := 0; k < half; k++ {
+			sin, cos := math.Sincos(sign * 2 * math.Pi * float64(k) / float64(size))
+			twiddle := complex(cos, sin)
+			for start := 0; start < n; start += size {
+				even, odd := x[start+k], x[start+k+half]*twiddle
+				x[start+k], x[start+k+half] = even+odd, even-odd
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+func TestCrossCorrelate_FindsTheEchoOfTheTestSignal(t *testing.T) {
+	const sampleRate, latency = 16000, 3517
+	signal := calibrationSignal(sampleRate)
+
+	// The input hears a quiet, late copy of the signal over room noise
+	noise := rand.New(rand.NewSource(1))
+	played := make([]float64, len(signal)+sampleRate)
+	heard := make([]float64, len(played))
+	copy(played, signal)
+	for i := range heard {
+		heard[i] = 0.02 * noise.NormFloat64()
+		if i >= latency {
+			heard[i] += 0.3 * played[i-latency]
+		}
+	}
+
+	lag, confidence := CrossCorrelate(played, heard, 0, sampleRate)
+	if math.Abs(lag-latency) > 0.5 {
+		t.Errorf("Expected a lag of %d frames, got %.2f", latency, lag)
+	}
+	if confidence < calibrationMinConfidence {
+		t.Errorf("Expected a confident match, got %.2f", confidence)
+	}
+
+	// Swapped, the played signal is early on what was heard
+	if lag, _ := CrossCorrelate(heard, played, -sampleRate, sampleRate); math.Abs(lag+latency) > 0.5 {
+		t.Errorf("Expected a lag of -%d frames, got %.2f", latency, lag)
+	}
+
+	// Noise alone must not look like an echo
+	if _, confidence := CrossCorrelate(played, heard[len(heard)-sampleRate/2:], 0, sampleRate); confidence >= calibrationMinConfidence {
+		t.Errorf("Expected no match on noise, got %.2f", confidence)
+	}
+}
+
+func TestFFT_RoundTrip(t *testing.T) {
+	x := []complex128{1, 2, 3, 4, 0, -1, 0.5, 2}
+	y := append([]complex128(nil), x...)
+	fft(y, false)
+	fft(y, true)
+	for i := range x {
+		if got := y[i] / complex(float64(len(y)), 0); math.Abs(real(got)-real(x[i])) > 1e-9 || math.Abs(imag(got)) > 1e-9 {
+			t.Errorf("Sample %d: expected %v, got %v", i, x[i], got)
+		}
+	}
+}
+
+func TestCalibrationChannels(t *testing.T) {
+	channels := []config.Channel{
+		{Name: "guitar", Type: "input"},
+		{Name: "mic", Type: "input"},
+		{Name: "chrome", Type: "monitor", Delay: 250},
+	}
+
+	monitor, input, err := calibrationChannels(channels, CalibrationOptions{Input: "mic"})
+	if err != nil || monitor.Name != "chrome" || input.Name != "mic" {
+		t.Errorf("Expected chrome heard by mic, got %s and %s (%v)", monitor.Name, input.Name, err)
+	}
+
+	if _, _, err := calibrationChannels(channels, CalibrationOptions{Channel: "guitar"}); err == nil || !strings.Contains(err.Error(), "no 'monitor' channel named 'guitar'") {
+		t.Errorf("Expected an input channel to be refused as the monitor, got: %v", err)
+	}
+	if _, _, err := calibrationChannels(channels[:2], CalibrationOptions{}); err == nil {
+		t.Error("Expected a profile without monitor channel to fail")
+	}
+}
+
+func TestCalibrationSessionChannels(t *testing.T) {
+	monitor := config.Channel{Name: "chrome", Type: "monitor", AudioMode: "stereo", Sources: []string{"Chrome:output_FL", "Chrome:output_FR"}}
+	input := config.Channel{Name: "mic", Type: "input", AudioMode: "mono", Sources: []string{"system:capture_2"}}
+
+	// The monitor is recorded from its own sources, the playback channel only drives the signal
+	channels := calibrationSessionChannels(monitor, input)
+	if len(channels) != 3 || channels[0].Name != "mic" || channels[1].Name != "chrome" || channels[1].Type != "monitor" ||
+		strings.Join(channels[1].Sources, ",") != "Chrome:output_FL,Chrome:output_FR" {
+		t.Fatalf("Expected the mic then chrome on its sources, got %+v", channels)
+	}
+	if playback := channels[2]; playback.Type != config.ChannelTypePlayback || playback.Name == monitor.Name || playback.Name == input.Name {
+		t.Errorf("Expected a separate playback channel for the test signal, got %+v", playback)
+	}
+}
@@ -0,0 +1,137 @@
+package audio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// ChannelState is the connection state of a channel
+type ChannelState string
+
+const (
+	ChannelWaiting     ChannelState = "waiting"     // At least one source is missing
+	ChannelFound       ChannelState = "found"       // All sources are present, not linked yet
+	ChannelDuplicate   ChannelState = "duplicate"   // A source is exposed by several clients
+	ChannelLinked      ChannelState = "linked"      // All sources are linked to the capture
+	ChannelDropped     ChannelState = "dropped"     // A source link was lost while recording
+	ChannelReconnected ChannelState = "reconnected" // All sources linked again after a dropout
+)
+
+// ChannelStatus reports the connection state of one channel
+type ChannelStatus struct {
+	Name        string       `json:"name"`
+	State       ChannelState `json:"state"`
+	LinkedPorts []string     `json:"linked_ports,omitempty"` // Source ports currently linked to the capture
+	LastChange  time.Time    `json:"last_change"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// channelTracker keeps the state of every channel and when it last changed
+type channelTracker struct {
+	mutex    sync.Mutex
+	channels map[string]*ChannelStatus
+}
+
+// set updates a channel. LastChange only moves when the state, the linked
+// ports or the error actually change.
+func (t *channelTracker) set(name string, state ChannelState, linkedPorts []string, errMsg string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.channels == nil {
+		t.channels = make(map[string]*ChannelStatus)
+	}
+
+	current, exists := t.channels[name]
+	if exists && current.State == state && current.Error == errMsg && equalPorts(current.LinkedPorts, linkedPorts) {
+		return
+	}
+
+	t.channels[name] = &ChannelStatus{
+		Name:        name,
+		State:       state,
+		LinkedPorts: append([]string(nil), linkedPorts...),
+		LastChange:  time.Now(),
+		Error:       errMsg,
+	}
+}
+
+// state returns the current state of a channel, or "" if it was never set
+func (t *channelTracker) state(name string) ChannelState {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if current, exists := t.channels[name]; exists {
+		return current.State
+	}
+	return ""
+}
+
+// all returns a copy of every channel status
+func (t *channelTracker) all() map[string]ChannelStatus {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	result := make(map[string]ChannelStatus, len(t.channels))
+	for name, current := range t.channels {
+		status := *current
+		status.LinkedPorts = append([]string(nil), current.LinkedPorts...)
+		result[name] = status
+	}
+	return result
+}
+
+// scan sets each channel to waiting, found or duplicate from a port snapshot
+// and returns the resulting statuses
+func (t *channelTracker) scan(channels []config.Channel, snapshot *PortSnapshot) map[string]ChannelStatus {
+	for _, channel := range channels {
+		t.set(channel.Name, sourceState(channel, snapshot), nil, "")
+	}
+	return t.all()
+}
+
+// link sets a channel's state from the sources linked to its capture inputs.
+// A channel that has dropped once reports reconnected until the take ends,
+// so a damaged take stays visible.
+func (t *channelTracker) link(name string, linkedPorts []string, allLinked bool) {
+	state := ChannelLinked
+	switch {
+	case !allLinked:
+		state = ChannelDropped
+	case t.state(name) == ChannelDropped || t.state(name) == ChannelReconnected:
+		state = ChannelReconnected
+	}
+	t.set(name, state, linkedPorts, "")
+}
+
+// sourceState derives the pre-recording state of a channel from a port snapshot
+func sourceState(channel config.Channel, snapshot *PortSnapshot) ChannelState {
+	state := ChannelFound
+	for _, source := range channel.Sources {
+		if source == "" || source == "disabled" {
+			continue
+		}
+		if snapshot.HasDuplicates(source) {
+			return ChannelDuplicate
+		}
+		if !snapshot.Exists(source) {
+			state = ChannelWaiting
+		}
+	}
+	return state
+}
+
+// equalPorts reports whether two port lists are identical
+func equalPorts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
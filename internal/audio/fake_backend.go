@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"io"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// FakeBackend implements the AudioBackend interface without audio hardware.
+// Its ports live in memory and can be scripted with AddPort/RemovePort, and
+// its recorders render each channel from WAV files or generated tones.
+type FakeBackend struct {
+	graph *MemoryPortGraph
+}
+
+// NewFakeBackend creates a fake backend whose initial ports come from the
+// fake configuration, or from every configured channel source if none are listed
+func NewFakeBackend(cfg *config.Config) *FakeBackend {
+	if cfg.Audio.Fake != nil && len(cfg.Audio.Fake.Ports) > 0 {
+		return &FakeBackend{graph: NewMemoryPortGraph(cfg.Audio.Fake.Ports...)}
+	}
+
+	var ports []string
+	for _, channel := range cfg.Channels {
+		for _, source := range channel.Sources {
+			if source != "" && source != "disabled" {
+				ports = append(ports, source)
+			}
+		}
+	}
+
+	return &FakeBackend{graph: NewMemoryPortGraph(ports...)}
+}
+
+// NewRecorder creates a new fake recorder bound to this backend's ports
+func (b *FakeBackend) NewRecorder(cfg *config.Config, logWriter io.Writer) Recorder {
+	return NewFakeRecorder(cfg, logWriter, b)
+}
+
+// ListSources returns the ports currently present in the fake graph
+func (b *FakeBackend) ListSources() ([]string, error) {
+	return b.graph.ListPorts()
+}
+
+// ValidateSource checks that a port exists exactly once in the fake graph
+func (b *FakeBackend) ValidateSource(source string) error {
+	return NewPipeWireWithGraph(b.graph).ValidatePort(source)
+}
+
+// GetType returns the backend type
+func (b *FakeBackend) GetType() BackendType {
+	return BackendTypeFake
+}
+
+// AddPort adds a port to the fake graph. Adding an existing name again
+// simulates a duplicate client such as a second browser instance.
+func (b *FakeBackend) AddPort(name string) {
+	b.graph.AddPort(name)
+}
+
+// RemovePort removes one instance of a port from the fake graph
+func (b *FakeBackend) RemovePort(name string) bool {
+	return b.graph.RemovePort(name)
+}
+
+// Graph returns the in-memory port graph backing this backend
+func (b *FakeBackend) Graph() *MemoryPortGraph {
+	return b.graph
+}
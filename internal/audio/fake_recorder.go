@@ -0,0 +1,723 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+const (
+	// fakePollInterval is how often the fake recorder checks its port graph while READY
+	fakePollInterval = 50 * time.Millisecond
+
+	// fakeMinDuration keeps very short takes above the output size sanity check
+	fakeMinDuration = 1 * time.Second
+
+	// fakeDefaultFrequency is the tone generated for ports without a configured signal
+	fakeDefaultFrequency = 440.0
+
+	// fakeToneAmplitude is the amplitude of ffmpeg's sine source, used for fake levels
+	fakeToneAmplitude = 0.125
+)
+
+// FakeRecorder implements the Recorder interface on top of a FakeBackend.
+// It follows the same STANDBY→READY→RECORDING→STOP flow as PipeWireRecorder,
+// and on Stop renders a real multi-track MKV with ffmpeg from the signals
+// configured for each source port.
+type FakeRecorder struct {
+	cfg       *config.Config
+	logWriter io.Writer
+	backend   *FakeBackend
+
+	// Recording state
+	mutex          sync.RWMutex
+	status         Status
+	session        *SessionInfo
+	presentSources map[string]bool // Sources present when recording started
+	channels       channelTracker
+	sources        sourceResolver // Concrete ports of the source patterns
+	armedAt        time.Time
+	splitStop      chan struct{} // Stops the auto-split watcher
+
+	// Source monitoring
+	sourceMonitorStop chan struct{}
+	sourceMonitorDone chan struct{}
+
+	// lastError is why the recorder last ended a session on its own
+	lastError error
+}
+
+// NewFakeRecorder creates a new fake recorder
+func NewFakeRecorder(cfg *config.Config, logWriter io.Writer, backend *FakeBackend) *FakeRecorder {
+	if logWriter == nil {
+		logWriter = io.Discard
+	}
+	if backend == nil {
+		backend = NewFakeBackend(cfg)
+	}
+
+	return &FakeRecorder{
+		cfg:       cfg,
+		logWriter: logWriter,
+		backend:   backend,
+		status:    StatusStandby,
+	}
+}
+
+// StartReady transitions from STANDBY to READY state
+func (r *FakeRecorder) StartReady(songName string) error {
+	// A monitor left running by a previous ERROR state is replaced below
+	r.stopSourceMonitoring()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusStandby && r.status != StatusError {
+		return fmt.Errorf("can only start ready from standby or error state, current: %s", r.status)
+	}
+
+	if songName == "" {
+		return fmt.Errorf("song name is required")
+	}
+	r.lastError = nil
+
+	r.sources.reset()
+	snapshot := r.snapshot()
+	r.sources.resolve(r.cfg.Channels, snapshot, r.backend.graph)
+
+	if err := os.MkdirAll(r.cfg.Output.Directory, 0755); err != nil {
+		r.status = StatusError
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	cleanName := r.cleanFileName(songName)
+	outputFile := filepath.Join(r.cfg.Output.Directory, cleanName+".mkv")
+
+	channelNames := make([]string, len(r.sessionChannels()))
+	for i, ch := range r.sessionChannels() {
+		channelNames[i] = fmt.Sprintf("%s:[%s]", ch.Name, strings.Join(ch.Sources, ","))
+	}
+
+	if r.hasDuplicateSources(snapshot) {
+		r.status = StatusError
+		r.startSourceMonitoring()
+		return fmt.Errorf("duplicate audio sources detected - please close conflicting applications before starting recording")
+	}
+
+	r.session = &SessionInfo{
+		SongName:     songName,
+		StartTime:    time.Now(),
+		OutputFile:   outputFile,
+		ChannelCount: len(r.sessionChannels()),
+		ChannelNames: channelNames,
+		Trigger:      r.cfg.GetTrigger().Mode,
+	}
+
+	r.status = StatusReady
+	r.startSourceMonitoring()
+
+	slog.Info("Fake ready state activated", "song", songName, "channels", len(r.sessionChannels()))
+	return nil
+}
+
+// StartRecording begins recording from READY state
+func (r *FakeRecorder) StartRecording() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusReady {
+		return fmt.Errorf("can only start recording from ready state, current: %s", r.status)
+	}
+
+	if r.session == nil {
+		return fmt.Errorf("no session prepared, call StartReady first")
+	}
+
+	now := time.Now()
+	if r.session.Armed {
+		// The fake signals run continuously, so the pre-roll is the time
+		// spent armed, up to the configured length
+		preRoll := math.Min(r.cfg.GetTrigger().PreRoll, now.Sub(r.armedAt).Seconds())
+		r.session.Armed = false
+		r.session.PreRoll = preRoll
+		now = now.Add(-time.Duration(preRoll * float64(time.Second)))
+	} else {
+		r.linkSources(r.snapshot())
+	}
+
+	r.session.StartTime = now
+	r.session.startTake(r.session.OutputFile, now)
+	r.status = StatusRecording
+
+	if policy := r.cfg.GetAutoSplit(); policy != nil {
+		r.splitStop = make(chan struct{})
+		go r.watchSilence(*policy, r.splitStop)
+	}
+
+	slog.Info("Fake recording started", "song", r.session.SongName, "channels", len(r.sessionChannels()))
+	return nil
+}
+
+// arm links the sources of a READY session whose trigger is not "sources",
+// so that levels are reported while waiting for the trigger
+func (r *FakeRecorder) arm() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusReady || r.session == nil || r.session.Armed {
+		return
+	}
+
+	r.linkSources(r.snapshot())
+	r.armedAt = time.Now()
+	r.session.Armed = true
+
+	slog.Info("Fake session armed", "song", r.session.SongName, "trigger", r.session.Trigger)
+}
+
+// linkSources remembers which sources are linked for the take. Must hold r.mutex.
+func (r *FakeRecorder) linkSources(snapshot *PortSnapshot) {
+	// Remember which sources were linked; missing ones are recorded as silence
+	r.presentSources = make(map[string]bool)
+	for _, channel := range r.sessionChannels() {
+		for _, source := range channel.Sources {
+			if source != "" && source != "disabled" && r.sourceAvailable(snapshot, channel, source) {
+				r.presentSources[source] = true
+			}
+		}
+	}
+
+	// Present sources count as linked for the whole take
+	r.channels.scan(r.sessionChannels(), snapshot)
+	for _, channel := range r.sessionChannels() {
+		var linkedPorts []string
+		allLinked := true
+		for _, source := range channel.Sources {
+			if source == "" || source == "disabled" {
+				continue
+			}
+			if r.presentSources[source] {
+				linkedPorts = append(linkedPorts, source)
+			} else {
+				allLinked = false
+			}
+		}
+		r.channels.link(channel.Name, linkedPorts, allLinked)
+	}
+}
+
+// Stop ends the current recording session and renders the MKV file
+func (r *FakeRecorder) Stop() error {
+	r.stopSourceMonitoring()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusRecording {
+		return fmt.Errorf("no recording in progress")
+	}
+
+	if r.splitStop != nil {
+		close(r.splitStop)
+		r.splitStop = nil
+	}
+
+	if err := r.renderTake(time.Now()); err != nil {
+		r.status = StatusError
+		return err
+	}
+
+	r.status = StatusStandby
+	slog.Debug("Fake recording completed successfully", "output", r.session.OutputFile)
+	return nil
+}
+
+// renderTake ends the current take and renders its MKV file. Must hold r.mutex.
+func (r *FakeRecorder) renderTake(end time.Time) error {
+	r.session.endTake(end)
+	take := r.session.Takes[len(r.session.Takes)-1]
+
+	duration := time.Duration(take.Duration * float64(time.Second))
+	if duration < fakeMinDuration {
+		duration = fakeMinDuration
+	}
+
+	os.Remove(take.File)
+
+	args := r.buildRenderArgs(duration, take.File)
+	slog.Info("Rendering fake recording", "command", "ffmpeg "+strings.Join(args, " "))
+
+	cmd := exec.Command("ffmpeg", args...)
+	output, err := cmd.CombinedOutput()
+	fmt.Fprint(r.logWriter, string(output))
+	if err != nil {
+		return fmt.Errorf("failed to render fake recording: %w (output: %s)", err, string(output))
+	}
+
+	fileInfo, err := os.Stat(take.File)
+	if err != nil {
+		return fmt.Errorf("recording file not found: %s", take.File)
+	}
+	if fileInfo.Size() < 1024 {
+		return fmt.Errorf("recording failed: file too small (%d bytes)", fileInfo.Size())
+	}
+
+	slog.Debug("Fake take rendered", "output", take.File, "duration", duration)
+	return nil
+}
+
+// watchSilence applies the auto-split policy to the fake levels until the recording stops
+func (r *FakeRecorder) watchSilence(policy config.AutoSplitConfig, stop <-chan struct{}) {
+	detector := newSilenceDetector(policy, r.sessionChannels(), time.Now())
+	ticker := time.NewTicker(fakePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case now := <-ticker.C:
+			switch detector.update(r.GetLevels(), now) {
+			case silenceSplit:
+				if err := r.splitTake(now); err != nil {
+					slog.Error("Failed to split fake take", "error", err)
+					continue
+				}
+				detector.newTake()
+
+			case silenceStop:
+				slog.Info("Fake inputs silent - stopping recording", "minutes", policy.StopAfter)
+				if err := r.Stop(); err != nil {
+					slog.Error("Failed to stop fake recording on silence", "error", err)
+					return
+				}
+				if !detector.heard {
+					r.removeSilentTake()
+				}
+				return
+			}
+		}
+	}
+}
+
+// splitTake renders the current take and starts the next one
+func (r *FakeRecorder) splitTake(now time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusRecording {
+		return fmt.Errorf("no recording in progress")
+	}
+
+	if err := r.renderTake(now); err != nil {
+		return err
+	}
+
+	number := len(r.session.Takes) + 1
+	file := takeFile(r.session.Takes[0].File, number)
+	r.session.startTake(file, now)
+
+	slog.Info("Fake take split on silence", "song", r.session.SongName, "take", number, "file", file)
+	return nil
+}
+
+// removeSilentTake deletes a take holding only the silence before an automatic stop
+func (r *FakeRecorder) removeSilentTake() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.session == nil {
+		return
+	}
+	if file, ok := r.session.dropLastTake(); ok {
+		os.Remove(file)
+		slog.Info("Removed silent take", "file", file)
+	}
+}
+
+// CancelReady cancels ready state and returns to STANDBY
+func (r *FakeRecorder) CancelReady() error {
+	r.mutex.Lock()
+	if r.status != StatusReady {
+		status := r.status
+		r.mutex.Unlock()
+		return fmt.Errorf("can only cancel from ready state, current: %s", status)
+	}
+	r.status = StatusStandby
+	r.session = nil
+	r.mutex.Unlock()
+
+	r.stopSourceMonitoring()
+	slog.Debug("Fake ready state cancelled, returned to standby")
+	return nil
+}
+
+// GetStatus returns the current status and session info
+func (r *FakeRecorder) GetStatus() (Status, *SessionInfo) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var sessionCopy *SessionInfo
+	if r.session != nil {
+		sessionCopy = r.session.clone()
+	}
+
+	return r.status, sessionCopy
+}
+
+// GetChannelStatus returns the connection state of configured channels
+func (r *FakeRecorder) GetChannelStatus() map[string]ChannelStatus {
+	r.mutex.RLock()
+	status, armed := r.status, r.armed()
+	r.mutex.RUnlock()
+
+	if status == StatusRecording || armed {
+		return r.channels.all()
+	}
+
+	snapshot := r.snapshot()
+	r.sources.resolve(r.cfg.Channels, snapshot, r.backend.graph)
+	return r.channels.scan(r.sessionChannels(), snapshot)
+}
+
+// GetLevels reports the level of the generated signal for every channel
+// with a linked source still in the graph, and silence for the others, while
+// recording or armed. Removing a port is how tests go quiet.
+func (r *FakeRecorder) GetLevels() []ChannelLevel {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.status != StatusRecording && !r.armed() {
+		return nil
+	}
+
+	snapshot := r.snapshot()
+	now := time.Now()
+	levels := make([]ChannelLevel, 0, len(r.sessionChannels()))
+	for _, channel := range r.sessionChannels() {
+		level := ChannelLevel{Name: channel.Name, Peak: minLevelDB, RMS: minLevelDB, Time: now}
+		for _, source := range channel.Sources {
+			if r.presentSources[source] && snapshot.Exists(source) {
+				level.Peak = toDBFS(fakeToneAmplitude)
+				level.RMS = toDBFS(fakeToneAmplitude / math.Sqrt2)
+				break
+			}
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// armed reports whether the session is waiting for its trigger. Must hold r.mutex.
+func (r *FakeRecorder) armed() bool {
+	return r.session != nil && r.session.Armed
+}
+
+// Cleanup stops any background monitoring
+func (r *FakeRecorder) Cleanup() error {
+	r.stopSourceMonitoring()
+	slog.Debug("Fake recorder cleaned up")
+	return nil
+}
+
+// buildRenderArgs builds the ffmpeg arguments producing one track per channel,
+// with the same stream layout and titles as a PipeWire capture
+func (r *FakeRecorder) buildRenderArgs(duration time.Duration, outputFile string) []string {
+	sampleRate := r.cfg.GetSampleRate()
+
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	var filterParts []string
+	inputIndex := 0
+
+	for i, channel := range r.sessionChannels() {
+		var sourceRefs []string
+		for j, source := range channel.Sources {
+			if source == "" || source == "disabled" {
+				continue
+			}
+
+			args = append(args, r.signalInputArgs(source, sampleRate)...)
+
+			sourceRef := fmt.Sprintf("[s%d_%d]", i, j)
+			filterParts = append(filterParts, fmt.Sprintf("[%d:a]aformat=sample_rates=%d:channel_layouts=mono%s", inputIndex, sampleRate, sourceRef))
+			sourceRefs = append(sourceRefs, sourceRef)
+			inputIndex++
+		}
+
+		trackRef := fmt.Sprintf("[t%d]", i)
+		switch len(sourceRefs) {
+		case 0:
+			args = append(args, "-f", "lavfi", "-i", fmt.Sprintf("anullsrc=channel_layout=mono:sample_rate=%d", sampleRate))
+			filterParts = append(filterParts, fmt.Sprintf("[%d:a]anull%s", inputIndex, trackRef))
+			inputIndex++
+		case 1:
+			filterParts = append(filterParts, fmt.Sprintf("%sanull%s", sourceRefs[0], trackRef))
+		default:
+			// A group has no layout to join into: its channels stay discrete
+			if layout := channel.ChannelLayout(); layout != "" && channel.ChannelCount() == len(sourceRefs) {
+				filterParts = append(filterParts, fmt.Sprintf("%sjoin=inputs=%d:channel_layout=%s%s", strings.Join(sourceRefs, ""), len(sourceRefs), layout, trackRef))
+			} else {
+				filterParts = append(filterParts, fmt.Sprintf("%samerge=inputs=%d%s", strings.Join(sourceRefs, ""), len(sourceRefs), trackRef))
+			}
+		}
+	}
+
+	args = append(args, "-filter_complex", strings.Join(filterParts, ";"))
+
+	for i, channel := range r.sessionChannels() {
+		args = append(args, "-map", fmt.Sprintf("[t%d]", i))
+		args = append(args, fmt.Sprintf("-metadata:s:a:%d", i), fmt.Sprintf("title=%s", channel.Name))
+	}
+
+	args = append(args,
+		"-t", fmt.Sprintf("%.3f", duration.Seconds()),
+		"-ar", fmt.Sprintf("%d", sampleRate),
+	)
+	args = append(args, r.cfg.GetCapture().EncoderArgs()...)
+	args = append(args, "-y", outputFile)
+
+	return args
+}
+
+// signalInputArgs returns the ffmpeg input for a source port: its configured
+// WAV file or tone, or silence if the port was absent when recording started
+func (r *FakeRecorder) signalInputArgs(source string, sampleRate int) []string {
+	if !r.presentSources[source] {
+		return []string{"-f", "lavfi", "-i", fmt.Sprintf("anullsrc=channel_layout=mono:sample_rate=%d", sampleRate)}
+	}
+
+	frequency := fakeDefaultFrequency
+	if r.cfg.Audio.Fake != nil {
+		for _, signal := range r.cfg.Audio.Fake.Signals {
+			if signal.Port != source {
+				continue
+			}
+			if signal.File != "" {
+				return []string{"-stream_loop", "-1", "-i", signal.File}
+			}
+			if signal.Frequency > 0 {
+				frequency = signal.Frequency
+			}
+			break
+		}
+	}
+
+	return []string{"-f", "lavfi", "-i", fmt.Sprintf("sine=frequency=%g:sample_rate=%d", frequency, sampleRate)}
+}
+
+// startSourceMonitoring polls the fake graph and auto-transitions to RECORDING
+func (r *FakeRecorder) startSourceMonitoring() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	r.sourceMonitorStop = stop
+	r.sourceMonitorDone = done
+
+	events, unsubscribe := r.backend.graph.Subscribe()
+
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+
+		ticker := time.NewTicker(fakePollInterval)
+		defer ticker.Stop()
+
+		// A nil channel never fires, so "never" waits until cancelled
+		limit := r.cfg.GetReady().TimeoutDuration()
+		var timeout <-chan time.Time
+		if limit > 0 {
+			timeout = time.After(limit)
+		}
+
+		for {
+			select {
+			case <-stop:
+				return
+
+			case <-timeout:
+				statuses := r.channels.scan(r.sessionChannels(), r.snapshot())
+				r.mutex.Lock()
+				if r.armed() {
+					// An armed session waits for its trigger without limit
+					r.mutex.Unlock()
+					timeout = nil
+					continue
+				}
+				if r.status == StatusReady || r.status == StatusError {
+					r.lastError = newReadyTimeoutError(limit, r.sessionChannels(), statuses)
+					slog.Warn("Fake source monitoring timeout - returning to STANDBY", "error", r.lastError)
+					r.status = StatusStandby
+					r.session = nil
+				}
+				r.mutex.Unlock()
+				return
+
+			case <-events:
+				if r.checkSources() {
+					return
+				}
+
+			case <-ticker.C:
+				if r.checkSources() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// checkSources runs one monitoring pass and reports whether monitoring is finished
+func (r *FakeRecorder) checkSources() bool {
+	snapshot := r.snapshot()
+	r.sources.resolve(r.cfg.Channels, snapshot, r.backend.graph)
+	hasDuplicates := r.hasDuplicateSources(snapshot)
+
+	r.mutex.Lock()
+	currentStatus := r.status
+	if currentStatus == StatusReady && hasDuplicates {
+		slog.Info("Fake duplicate sources detected - returning to STANDBY")
+		r.lastError = errDuplicatesWhileReady
+		r.status = StatusStandby
+		r.session = nil
+		r.mutex.Unlock()
+		return true
+	}
+	if currentStatus == StatusError && !hasDuplicates {
+		slog.Info("Fake duplicate sources resolved - recovering from ERROR to STANDBY")
+		r.status = StatusStandby
+		r.session = nil
+	}
+	r.mutex.Unlock()
+
+	// A manual trigger may have started the take
+	if currentStatus == StatusRecording {
+		return true
+	}
+
+	if currentStatus == StatusReady && r.checkAllSourcesAvailable(snapshot) {
+		trigger := r.cfg.GetTrigger()
+		if trigger.Mode != config.TriggerSources {
+			r.arm()
+			if trigger.Mode == config.TriggerManual || !signalDetected(r.GetLevels(), trigger) {
+				return false
+			}
+			slog.Info("Fake trigger signal detected - starting recording", "channel", trigger.Channel, "threshold", trigger.Threshold)
+		} else {
+			slog.Info("All fake sources available - starting recording automatically")
+		}
+		if err := r.StartRecording(); err != nil {
+			slog.Error("Failed to auto-start fake recording", "error", err)
+			r.mutex.Lock()
+			r.status = StatusError
+			r.mutex.Unlock()
+		}
+		return true
+	}
+
+	return false
+}
+
+// stopSourceMonitoring stops the source monitoring goroutine.
+// Must be called without holding r.mutex.
+func (r *FakeRecorder) stopSourceMonitoring() {
+	r.mutex.Lock()
+	stop, done := r.sourceMonitorStop, r.sourceMonitorDone
+	r.sourceMonitorStop, r.sourceMonitorDone = nil, nil
+	r.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// checkAllSourcesAvailable reports whether every enabled channel has all its sources
+func (r *FakeRecorder) checkAllSourcesAvailable(snapshot *PortSnapshot) bool {
+	channelsWithSources := 0
+	for _, channel := range r.sessionChannels() {
+		hasAnySources := false
+		for _, source := range channel.Sources {
+			if source == "" || source == "disabled" {
+				continue
+			}
+			hasAnySources = true
+			if !r.sourceAvailable(snapshot, channel, source) {
+				return false
+			}
+		}
+		if hasAnySources {
+			channelsWithSources++
+		}
+	}
+	return channelsWithSources > 0
+}
+
+// sourceAvailable reports whether a source can be linked: present, and either
+// unique or duplicated under a rule that picks instances
+func (r *FakeRecorder) sourceAvailable(snapshot *PortSnapshot, channel config.Channel, source string) bool {
+	if snapshot.HasDuplicates(source) {
+		return !r.blocksDuplicates(channel)
+	}
+	return snapshot.Exists(source)
+}
+
+// blocksDuplicates reports whether duplicate sources of a channel prevent recording
+func (r *FakeRecorder) blocksDuplicates(channel config.Channel) bool {
+	return r.cfg.GetDuplicateRule(channel).Policy == config.DuplicatesBlock
+}
+
+// GetLastError returns why the recorder last ended a session on its own
+func (r *FakeRecorder) GetLastError() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.lastError
+}
+
+// hasDuplicateSources checks if any configured sources have duplicates that
+// the channel's duplicate rule does not resolve
+func (r *FakeRecorder) hasDuplicateSources(snapshot *PortSnapshot) bool {
+	for _, channel := range r.sessionChannels() {
+		if !r.blocksDuplicates(channel) {
+			continue
+		}
+		for _, source := range channel.Sources {
+			if source != "" && source != "disabled" && snapshot.HasDuplicates(source) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sessionChannels returns the configured channels with their source patterns
+// replaced by the ports they resolved to
+func (r *FakeRecorder) sessionChannels() []config.Channel {
+	return r.sources.apply(r.cfg.Channels)
+}
+
+// snapshot takes one view of the fake graph shared by a whole pass
+func (r *FakeRecorder) snapshot() *PortSnapshot {
+	ports, _ := r.backend.graph.ListPorts()
+	return NewPortSnapshot(ports)
+}
+
+// cleanFileName sanitizes a filename
+// Allows: letters, numbers, spaces, hyphens, underscores
+func (r *FakeRecorder) cleanFileName(name string) string {
+	var result strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == ' ' || r == '-' || r == '_' {
+			result.WriteRune(r)
+		}
+	}
+	return strings.ReplaceAll(strings.TrimSpace(result.String()), " ", "_")
+}
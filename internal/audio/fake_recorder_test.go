@@ -0,0 +1,256 @@
+package audio
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// newFakeTestConfig returns a config with a mono guitar and a stereo Chrome monitor
+func newFakeTestConfig(t *testing.T) *config.Config {
+	return &config.Config{
+		Audio: config.AudioConfig{SampleRate: 48000, Backend: "fake"},
+		Channels: []config.Channel{
+			{Name: "guitar", Sources: []string{"system:capture_1"}, AudioMode: "mono", Type: "input", Volume: 2.0},
+			{Name: "chrome", Sources: []string{"Chrome:output_FL", "Chrome:output_FR"}, AudioMode: "stereo", Type: "monitor", Volume: 0.8},
+		},
+		Output: config.OutputConfig{Directory: t.TempDir(), Format: "flac"},
+	}
+}
+
+func TestDetermineBackend_Fake(t *testing.T) {
+	for _, name := range []string{"fake", "file", "FAKE"} {
+		cfg := &config.Config{Audio: config.AudioConfig{Backend: name}}
+		if backend := determineBackend(cfg); backend != BackendTypeFake {
+			t.Errorf("Expected fake backend for '%s', got %s", name, backend)
+		}
+	}
+
+	cfg := newFakeTestConfig(t)
+	if _, ok := NewRecorder(cfg, nil).(*FakeRecorder); !ok {
+		t.Error("Expected NewRecorder to return a FakeRecorder for backend 'fake'")
+	}
+}
+
+func TestFakeBackend_DefaultPortsFromChannels(t *testing.T) {
+	backend := NewFakeBackend(newFakeTestConfig(t))
+
+	ports, _ := backend.ListSources()
+	if len(ports) != 3 {
+		t.Fatalf("Expected 3 ports from configured sources, got %d: %v", len(ports), ports)
+	}
+	if err := backend.ValidateSource("Chrome:output_FL"); err != nil {
+		t.Errorf("Expected Chrome:output_FL to be valid, got: %v", err)
+	}
+}
+
+func TestFakeBackend_ScriptedPorts(t *testing.T) {
+	cfg := newFakeTestConfig(t)
+	cfg.Audio.Fake = &config.FakeConfig{Ports: []string{"system:capture_1"}}
+	backend := NewFakeBackend(cfg)
+
+	if err := backend.ValidateSource("Chrome:output_FL"); err == nil || !strings.Contains(err.Error(), "port not found") {
+		t.Errorf("Expected 'port not found' error, got: %v", err)
+	}
+
+	backend.AddPort("Chrome:output_FL")
+	backend.AddPort("Chrome:output_FL")
+	if err := backend.ValidateSource("Chrome:output_FL"); err == nil || !strings.Contains(err.Error(), "duplicate sources detected") {
+		t.Errorf("Expected 'duplicate sources detected' error, got: %v", err)
+	}
+
+	if !backend.RemovePort("Chrome:output_FL") {
+		t.Error("Expected RemovePort to remove an existing port")
+	}
+	if err := backend.ValidateSource("Chrome:output_FL"); err != nil {
+		t.Errorf("Expected single Chrome:output_FL to be valid, got: %v", err)
+	}
+}
+
+func TestFakeRecorder_ReadyWaitsForSources(t *testing.T) {
+	cfg := newFakeTestConfig(t)
+	cfg.Audio.Fake = &config.FakeConfig{Ports: []string{"system:capture_1"}}
+	backend := NewFakeBackend(cfg)
+	rec := NewFakeRecorder(cfg, nil, backend)
+	defer rec.Cleanup()
+
+	if err := rec.StartReady("Test Song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+
+	time.Sleep(3 * fakePollInterval)
+	if status, _ := rec.GetStatus(); status != StatusReady {
+		t.Fatalf("Expected READY while Chrome is missing, got %s", status)
+	}
+	if channelStatus := rec.GetChannelStatus(); channelStatus["chrome"].State != ChannelWaiting || channelStatus["guitar"].State != ChannelFound {
+		t.Errorf("Unexpected channel status: %v", channelStatus)
+	}
+
+	backend.AddPort("Chrome:output_FL")
+	backend.AddPort("Chrome:output_FR")
+	waitForStatusWithin(t, rec, StatusRecording, 2*time.Second)
+
+	levels := rec.GetLevels()
+	if len(levels) != 2 || levels[0].Peak <= minLevelDB || levels[0].Clipping {
+		t.Errorf("Expected a tone level for every channel, got %+v", levels)
+	}
+}
+
+func TestFakeRecorder_ManualTriggerWaitsArmed(t *testing.T) {
+	cfg := newFakeTestConfig(t)
+	cfg.Trigger = &config.TriggerConfig{Mode: config.TriggerManual, PreRoll: 2}
+	rec := NewFakeRecorder(cfg, nil, nil)
+	defer rec.Cleanup()
+
+	if err := rec.StartReady("Manual Song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+
+	// Every source is present, but a manual session only arms
+	waitForArmed(t, rec)
+	time.Sleep(3 * fakePollInterval)
+	if status, _ := rec.GetStatus(); status != StatusReady {
+		t.Fatalf("Expected READY until started manually, got %s", status)
+	}
+	if levels := rec.GetLevels(); len(levels) != 2 {
+		t.Errorf("Expected levels while armed, got %+v", levels)
+	}
+
+	if err := rec.StartRecording(); err != nil {
+		t.Fatalf("StartRecording failed: %v", err)
+	}
+	_, session := rec.GetStatus()
+	if session.Armed || session.PreRoll <= 0 || session.PreRoll > 2 {
+		t.Errorf("Expected a started session with up to 2s of pre-roll, got %+v", session)
+	}
+}
+
+func TestFakeRecorder_DuplicateSourcesBlockReady(t *testing.T) {
+	cfg := newFakeTestConfig(t)
+	backend := NewFakeBackend(cfg)
+	backend.AddPort("Chrome:output_FL")
+	rec := NewFakeRecorder(cfg, nil, backend)
+	defer rec.Cleanup()
+
+	err := rec.StartReady("dup")
+	if err == nil || !strings.Contains(err.Error(), "duplicate audio sources detected") {
+		t.Fatalf("Expected duplicate sources error, got: %v", err)
+	}
+	if status, _ := rec.GetStatus(); status != StatusError {
+		t.Fatalf("Expected ERROR, got %s", status)
+	}
+
+	// Closing the second browser recovers to STANDBY
+	backend.RemovePort("Chrome:output_FL")
+	waitForStatusWithin(t, rec, StatusStandby, 2*time.Second)
+}
+
+func TestFakeRecorder_CancelReady(t *testing.T) {
+	cfg := newFakeTestConfig(t)
+	cfg.Audio.Fake = &config.FakeConfig{Ports: []string{"none:port"}}
+	rec := NewFakeRecorder(cfg, nil, nil)
+
+	if err := rec.StartReady("cancel"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	if err := rec.CancelReady(); err != nil {
+		t.Fatalf("CancelReady failed: %v", err)
+	}
+	if status, session := rec.GetStatus(); status != StatusStandby || session != nil {
+		t.Errorf("Expected STANDBY without session, got %s %v", status, session)
+	}
+}
+
+func TestFakeRecorder_BuildRenderArgs(t *testing.T) {
+	cfg := newFakeTestConfig(t)
+	cfg.Audio.Fake = &config.FakeConfig{
+		Signals: []config.FakeSignal{
+			{Port: "system:capture_1", Frequency: 880},
+			{Port: "Chrome:output_FL", File: "/tmp/backing.wav"},
+		},
+	}
+	rec := NewFakeRecorder(cfg, nil, nil)
+	rec.presentSources = map[string]bool{"system:capture_1": true, "Chrome:output_FL": true}
+
+	args := strings.Join(rec.buildRenderArgs(2*time.Second, "/tmp/out.mkv"), " ")
+
+	expected := []string{
+		"-f lavfi -i sine=frequency=880:sample_rate=48000",
+		"-stream_loop -1 -i /tmp/backing.wav",
+		"-f lavfi -i anullsrc=channel_layout=mono:sample_rate=48000", // Chrome:output_FR absent
+		"[s1_0][s1_1]join=inputs=2:channel_layout=stereo[t1]",
+		"-map [t0] -metadata:s:a:0 title=guitar",
+		"-map [t1] -metadata:s:a:1 title=chrome",
+		"-t 2.000 -ar 48000 -c:a flac -y /tmp/out.mkv",
+	}
+	for _, part := range expected {
+		if !strings.Contains(args, part) {
+			t.Errorf("Expected render args to contain '%s', got: %s", part, args)
+		}
+	}
+}
+
+func TestFakeRecorder_FullFlow(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not available")
+	}
+
+	cfg := newFakeTestConfig(t)
+	rec := NewFakeRecorder(cfg, nil, nil)
+	defer rec.Cleanup()
+
+	if err := rec.StartReady("full flow"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	waitForStatusWithin(t, rec, StatusRecording, 2*time.Second)
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	_, session := rec.GetStatus()
+	if _, err := os.Stat(session.OutputFile); err != nil {
+		t.Errorf("Expected output file %s: %v", session.OutputFile, err)
+	}
+}
+
+func TestFakeRecorder_GetStatusCopiesTheWholeSession(t *testing.T) {
+	cfg := newFakeTestConfig(t)
+	rec := NewFakeRecorder(cfg, nil, nil)
+	defer rec.Cleanup()
+
+	rec.session = &SessionInfo{
+		SongName:         "copy",
+		OutputFile:       "copy.mkv",
+		ChannelCount:     2,
+		ChannelNames:     []string{"guitar", "chrome"},
+		ConnectionEvents: []ConnectionEvent{{Channel: "chrome"}},
+		Trigger:          config.TriggerSignal,
+		Armed:            true,
+		PreRoll:          1.5,
+		Takes:            []TakeInfo{{Number: 1}},
+		Overdub:          "overdub1",
+		BackingTrack:     "backing.flac",
+		BPM:              120,
+		CountingIn:       true,
+	}
+
+	_, session := rec.GetStatus()
+	if session.SongName != "copy" || session.OutputFile != "copy.mkv" || session.ChannelCount != 2 || len(session.ConnectionEvents) != 1 ||
+		session.Trigger != config.TriggerSignal || !session.Armed || session.PreRoll != 1.5 || session.Overdub != "overdub1" || session.BackingTrack != "backing.flac" ||
+		session.BPM != 120 || !session.CountingIn {
+		t.Errorf("Expected every field of the session, got %+v", session)
+	}
+
+	// The copy must not share its slices with the recording session
+	session.ChannelNames[0] = "changed"
+	session.ConnectionEvents[0].Channel = "changed"
+	session.Takes[0].Number = 2
+	if rec.session.ChannelNames[0] != "guitar" || rec.session.ConnectionEvents[0].Channel != "chrome" || rec.session.Takes[0].Number != 1 {
+		t.Errorf("Expected a deep copy, the session changed to %+v", rec.session)
+	}
+}
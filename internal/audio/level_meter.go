@@ -0,0 +1,143 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// levelRate is how many level measurements are taken per second
+	levelRate = 20
+
+	// minLevelDB is the level reported for digital silence
+	minLevelDB = -96.0
+
+	// clipLevel is the linear peak from which a channel is reported as clipping
+	clipLevel = 0.999
+)
+
+// ChannelLevel is the signal level of one channel over the last metering window
+type ChannelLevel struct {
+	Name     string    `json:"name"`
+	Peak     float64   `json:"peak"` // dBFS
+	RMS      float64   `json:"rms"`  // dBFS
+	Clipping bool      `json:"clipping"`
+	Time     time.Time `json:"time"`
+}
+
+// LevelSource is implemented by recorders that meter their channels while recording
+type LevelSource interface {
+	// GetLevels returns the latest level of every metered channel, in channel order
+	GetLevels() []ChannelLevel
+}
+
+// LevelMeter keeps the latest level of each channel fed from the capture pipeline
+type LevelMeter struct {
+	mutex  sync.RWMutex
+	order  []string
+	levels map[string]ChannelLevel
+}
+
+// NewLevelMeter creates a meter for the given channels, all starting silent
+func NewLevelMeter(channelNames []string) *LevelMeter {
+	m := &LevelMeter{
+		order:  append([]string(nil), channelNames...),
+		levels: make(map[string]ChannelLevel, len(channelNames)),
+	}
+	for _, name := range channelNames {
+		m.levels[name] = ChannelLevel{Name: name, Peak: minLevelDB, RMS: minLevelDB}
+	}
+	return m
+}
+
+// Levels returns the latest level of every channel, in channel order
+func (m *LevelMeter) Levels() []ChannelLevel {
+	if m == nil {
+		return nil
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	levels := make([]ChannelLevel, 0, len(m.order))
+	for _, name := range m.order {
+		levels = append(levels, m.levels[name])
+	}
+	return levels
+}
+
+// Update stores a measurement for a channel
+func (m *LevelMeter) Update(name string, samples []float32) {
+	peak, rms := measureLevel(samples)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.levels[name] = ChannelLevel{
+		Name:     name,
+		Peak:     toDBFS(peak),
+		RMS:      toDBFS(rms),
+		Clipping: peak >= clipLevel,
+		Time:     time.Now(),
+	}
+}
+
+// Consume reads interleaved little-endian float32 samples until EOF and
+// updates the channel once per metering window. It must keep reading for as
+// long as the writer runs, otherwise the capture process would block.
+func (m *LevelMeter) Consume(name string, channels, sampleRate int, reader io.Reader) {
+	if channels < 1 {
+		channels = 1
+	}
+	frames := sampleRate / levelRate
+	if frames < 1 {
+		frames = 1
+	}
+
+	buffer := make([]byte, frames*channels*4)
+	samples := make([]float32, frames*channels)
+
+	for {
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				slog.Debug("Level meter input closed", "channel", name, "error", err)
+			}
+			return
+		}
+
+		for i := range samples {
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(buffer[i*4:]))
+		}
+		m.Update(name, samples)
+	}
+}
+
+// measureLevel returns the linear peak and RMS of a block of samples
+func measureLevel(samples []float32) (peak, rms float64) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+
+	var sumSquares float64
+	for _, sample := range samples {
+		value := math.Abs(float64(sample))
+		if value > peak {
+			peak = value
+		}
+		sumSquares += value * value
+	}
+
+	return peak, math.Sqrt(sumSquares / float64(len(samples)))
+}
+
+// toDBFS converts a linear level to dBFS, floored at minLevelDB
+func toDBFS(level float64) float64 {
+	if level <= 0 {
+		return minLevelDB
+	}
+	return math.Max(20*math.Log10(level), minLevelDB)
+}
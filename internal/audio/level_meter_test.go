@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestMeasureLevel(t *testing.T) {
+	peak, rms := measureLevel([]float32{0.5, -0.5, 0.5, -0.5})
+	if peak != 0.5 || rms != 0.5 {
+		t.Errorf("Expected peak and RMS of 0.5 for a square wave, got %v, %v", peak, rms)
+	}
+
+	if db := toDBFS(0.5); math.Abs(db-(-6.02)) > 0.01 {
+		t.Errorf("Expected -6.02 dBFS, got %.2f", db)
+	}
+	if db := toDBFS(0); db != minLevelDB {
+		t.Errorf("Expected silence at %v dBFS, got %v", minLevelDB, db)
+	}
+}
+
+func TestLevelMeter_Consume(t *testing.T) {
+	const sampleRate = 400 // 20 frames per window
+	meter := NewLevelMeter([]string{"guitar", "chrome"})
+
+	// One window of stereo samples with a clipped left channel
+	var input bytes.Buffer
+	for i := 0; i < sampleRate/levelRate; i++ {
+		binary.Write(&input, binary.LittleEndian, float32(1.0))
+		binary.Write(&input, binary.LittleEndian, float32(0.1))
+	}
+	meter.Consume("chrome", 2, sampleRate, &input)
+
+	levels := meter.Levels()
+	if len(levels) != 2 || levels[0].Name != "guitar" || levels[1].Name != "chrome" {
+		t.Fatalf("Expected levels in channel order, got %+v", levels)
+	}
+	if levels[0].Peak != minLevelDB || levels[0].Clipping {
+		t.Errorf("Expected guitar to be silent, got %+v", levels[0])
+	}
+	if levels[1].Peak != 0 || !levels[1].Clipping {
+		t.Errorf("Expected chrome to peak at 0 dBFS and clip, got %+v", levels[1])
+	}
+	if levels[1].RMS >= 0 || levels[1].RMS < -4 {
+		t.Errorf("Expected chrome RMS around -3 dBFS, got %.2f", levels[1].RMS)
+	}
+}
@@ -0,0 +1,252 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os/exec"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+const (
+	// clickLength is how long each click sounds
+	clickLength = 30 * time.Millisecond
+
+	// clickDecay is the time constant of the click's exponential fade
+	clickDecay = 8 * time.Millisecond
+
+	// clickFrequency and accentFrequency are the pitches of a beat and of the
+	// first beat of a bar
+	clickFrequency  = 1000.0
+	accentFrequency = 1500.0
+)
+
+// metronomeState is the metronome of the current session
+type metronomeState struct {
+	bpm     float64 // Tempo requested for the next session, the profile's when 0
+	countIn *int    // Count-in requested for the next session, the profile's when nil
+
+	config    *config.MetronomeConfig // Metronome of the session, nil without click
+	cmd       *exec.Cmd
+	timer     *time.Timer // Ends the count-in
+	countedIn bool        // The count-in of the session is over
+}
+
+// clickSource generates the click of a metronome as mono float32 samples,
+// the first beat of every bar accented. Each beat starts on the frame nearest
+// its exact time, so the click never drifts from the tempo.
+type clickSource struct {
+	sampleRate  int
+	bpm         float64
+	beatsPerBar int
+	volume      float64
+	frame       int64
+}
+
+// newClickSource creates the click of a metronome
+func newClickSource(metronome config.MetronomeConfig, sampleRate int) *clickSource {
+	return &clickSource{
+		sampleRate:  sampleRate,
+		bpm:         metronome.BPM,
+		beatsPerBar: metronome.BeatsPerBar(),
+		volume:      metronome.Volume,
+	}
+}
+
+// beatStart returns the first frame of a beat
+func (c *clickSource) beatStart(beat int64) int64 {
+	return int64(math.Round(float64(beat) * 60 * float64(c.sampleRate) / c.bpm))
+}
+
+// sample returns the click at a frame
+func (c *clickSource) sample(frame int64) float32 {
+	beat := int64(float64(frame) * c.bpm / (60 * float64(c.sampleRate)))
+	for beat > 0 && c.beatStart(beat) > frame {
+		beat--
+	}
+	for c.beatStart(beat+1) <= frame {
+		beat++
+	}
+
+	t := float64(frame-c.beatStart(beat)) / float64(c.sampleRate)
+	if t >= clickLength.Seconds() {
+		return 0
+	}
+
+	frequency := clickFrequency
+	if beat%int64(c.beatsPerBar) == 0 {
+		frequency = accentFrequency
+	}
+	return float32(c.volume * math.Exp(-t/clickDecay.Seconds()) * math.Sin(2*math.Pi*frequency*t))
+}
+
+// Read fills p with whole samples. The click never ends.
+func (c *clickSource) Read(p []byte) (int, error) {
+	n := len(p) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		binary.LittleEndian.PutUint32(p[i:], math.Float32bits(c.sample(c.frame)))
+		c.frame++
+	}
+	return n, nil
+}
+
+// wavStreamHeader returns the header of a float32 WAV stream of unknown
+// length, which players read until the stream ends
+func wavStreamHeader(sampleRate, channels int) []byte {
+	return wavHeader(sampleRate, channels, math.MaxUint32)
+}
+
+// wavHeader returns the header of a float32 WAV file holding dataSize bytes
+// of samples, or of a stream when dataSize is math.MaxUint32
+func wavHeader(sampleRate, channels int, dataSize uint32) []byte {
+	riffSize := uint32(math.MaxUint32)
+	if dataSize != math.MaxUint32 {
+		riffSize = 36 + dataSize
+	}
+
+	var header bytes.Buffer
+	header.WriteString("RIFF")
+	binary.Write(&header, binary.LittleEndian, riffSize)
+	header.WriteString("WAVEfmt ")
+	binary.Write(&header, binary.LittleEndian, uint32(16))
+	binary.Write(&header, binary.LittleEndian, uint16(3)) // IEEE float
+	binary.Write(&header, binary.LittleEndian, uint16(channels))
+	binary.Write(&header, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&header, binary.LittleEndian, uint32(sampleRate*channels*4))
+	binary.Write(&header, binary.LittleEndian, uint16(channels*4))
+	binary.Write(&header, binary.LittleEndian, uint16(32))
+	header.WriteString("data")
+	binary.Write(&header, binary.LittleEndian, dataSize)
+	return header.Bytes()
+}
+
+// SetMetronome overrides the tempo and count-in of the next session. A bpm
+// of 0 and a nil countIn keep the profile's.
+func (r *PipeWireRecorder) SetMetronome(bpm float64, countIn *int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.click.bpm, r.click.countIn = bpm, countIn
+}
+
+// sessionMetronome returns the profile's metronome with the requested
+// overrides. A tempo alone is enough to click on a profile without metronome.
+func (r *PipeWireRecorder) sessionMetronome() *config.MetronomeConfig {
+	metronome := r.cfg.GetMetronome()
+	if r.click.bpm > 0 {
+		if metronome == nil {
+			metronome = (&config.Config{Metronome: &config.MetronomeConfig{}}).GetMetronome()
+		}
+		metronome.BPM = r.click.bpm
+	}
+	if metronome != nil && r.click.countIn != nil {
+		metronome.CountIn = *r.click.countIn
+	}
+	return metronome
+}
+
+// clickNode returns the name of the node playing the click
+func (r *PipeWireRecorder) clickNode() string {
+	return r.clientName + "_click"
+}
+
+// metronomeChannel returns the channel recording the click of a session, if any
+func (r *PipeWireRecorder) metronomeChannel() (config.Channel, bool) {
+	if r.click.config == nil || !r.click.config.Record {
+		return config.Channel{}, false
+	}
+	return config.Channel{Name: config.MetronomeChannel, AudioMode: "mono", Type: "monitor", Volume: 1.0}, true
+}
+
+// holdForCountIn starts the count-in of a session and reports whether its
+// take must wait for the downbeat that ends it. Must hold r.mutex.
+func (r *PipeWireRecorder) holdForCountIn() bool {
+	metronome := r.click.config
+	if metronome == nil || metronome.CountIn == 0 || r.click.countedIn {
+		return false
+	}
+	if r.click.timer != nil {
+		return true
+	}
+
+	// Without a click the count-in still holds the take, the musicians
+	// follow the status instead
+	if err := r.startMetronome(); err != nil {
+		slog.Error("Failed to play count-in", "error", err)
+	}
+
+	duration := metronome.CountInDuration()
+	r.click.timer = time.AfterFunc(duration, r.endCountIn)
+	r.session.CountingIn = true
+
+	slog.Info("Counting in", "bars", metronome.CountIn, "bpm", metronome.BPM, "time_signature", metronome.TimeSignature, "duration", duration)
+	return true
+}
+
+// endCountIn starts the take on the downbeat that ends the count-in
+func (r *PipeWireRecorder) endCountIn() {
+	r.mutex.Lock()
+	r.click.timer = nil
+	r.click.countedIn = true
+	if r.session != nil {
+		r.session.CountingIn = false
+	}
+	r.mutex.Unlock()
+
+	if err := r.StartRecording(); err != nil {
+		slog.Error("Failed to start recording after count-in", "error", err)
+		r.mutex.Lock()
+		r.stopMetronome()
+		r.mutex.Unlock()
+	}
+}
+
+// startMetronome plays the click of the session, if it has one and it is not
+// playing yet. Must hold r.mutex.
+func (r *PipeWireRecorder) startMetronome() error {
+	metronome := r.click.config
+	if metronome == nil || r.click.cmd != nil {
+		return nil
+	}
+
+	sampleRate := r.sampleRate()
+	cmd := r.playerCommand("-", r.clickNode(), "jamcapture metronome", metronome.Target)
+	cmd.Stdin = io.MultiReader(bytes.NewReader(wavStreamHeader(sampleRate, 1)), newClickSource(*metronome, sampleRate))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start metronome: %w", err)
+	}
+	r.click.cmd = cmd
+
+	slog.Info("Metronome playing", "bpm", metronome.BPM, "time_signature", metronome.TimeSignature, "target", metronome.Target)
+	return nil
+}
+
+// startMetronomeTrack plays the click of a take that just started and links
+// it to the metronome track when the click is recorded. Must hold r.mutex.
+func (r *PipeWireRecorder) startMetronomeTrack(stop <-chan struct{}) error {
+	if err := r.startMetronome(); err != nil {
+		return err
+	}
+	if channel, recorded := r.metronomeChannel(); recorded && r.click.cmd != nil {
+		go r.linkPlayer(channel, r.clickNode(), []string{"MONO"}, stop)
+	}
+	return nil
+}
+
+// stopMetronome ends the count-in and the click. Must hold r.mutex.
+func (r *PipeWireRecorder) stopMetronome() {
+	if r.click.timer != nil {
+		r.click.timer.Stop()
+		r.click.timer = nil
+	}
+	if r.click.cmd != nil && r.click.cmd.Process != nil {
+		r.click.cmd.Process.Kill()
+		r.click.cmd.Wait()
+	}
+	r.click.cmd = nil
+}
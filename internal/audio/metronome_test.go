@@ -0,0 +1,132 @@
+package audio
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// zeroCrossings counts the sign changes of a click between two frames
+func zeroCrossings(c *clickSource, from, to int64) int {
+	crossings := 0
+	previous := c.sample(from)
+	for frame := from + 1; frame < to; frame++ {
+		sample := c.sample(frame)
+		if (previous < 0) != (sample < 0) && sample != 0 {
+			crossings++
+		}
+		previous = sample
+	}
+	return crossings
+}
+
+func TestClickSource_BeatsAndAccent(t *testing.T) {
+	click := newClickSource(config.MetronomeConfig{BPM: 120, TimeSignature: "3/4", Volume: 0.5}, 48000)
+
+	// At 120 bpm a beat lasts 24000 frames, the click its first 1440
+	for _, frame := range []int64{10, 24010, 48010, 72010} {
+		if click.sample(frame) == 0 {
+			t.Errorf("Expected a click at frame %d", frame)
+		}
+	}
+	for _, frame := range []int64{2000, 23990, 26000} {
+		if sample := click.sample(frame); sample != 0 {
+			t.Errorf("Expected silence at frame %d, got %f", frame, sample)
+		}
+	}
+
+	// The first beat of every bar is pitched higher
+	accent, beat := zeroCrossings(click, 0, 1440), zeroCrossings(click, 24000, 25440)
+	if accent <= beat {
+		t.Errorf("Expected the accent to be higher than a beat, got %d and %d crossings", accent, beat)
+	}
+	if next := zeroCrossings(click, 72000, 73440); next != accent {
+		t.Errorf("Expected the fourth beat to be accented in 3/4, got %d crossings instead of %d", next, accent)
+	}
+
+	for frame := int64(0); frame < 1440; frame++ {
+		if sample := click.sample(frame); sample > 0.5 || sample < -0.5 {
+			t.Fatalf("Expected the click to stay within its volume, got %f at frame %d", sample, frame)
+		}
+	}
+}
+
+func TestClickSource_ReadsWholeSamples(t *testing.T) {
+	click := newClickSource(config.MetronomeConfig{BPM: 90, TimeSignature: "4/4", Volume: 1}, 44100)
+
+	buffer := make([]byte, 4*100+3)
+	n, err := click.Read(buffer)
+	if err != nil || n != 400 {
+		t.Fatalf("Expected 400 bytes, got %d (%v)", n, err)
+	}
+	if click.frame != 100 {
+		t.Errorf("Expected 100 frames read, got %d", click.frame)
+	}
+}
+
+func TestWavStreamHeader(t *testing.T) {
+	header := wavStreamHeader(48000, 1)
+	if len(header) != 44 {
+		t.Fatalf("Expected a 44 byte header, got %d", len(header))
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:16]) != "WAVEfmt " || string(header[36:40]) != "data" {
+		t.Errorf("Unexpected chunk ids in %q", header)
+	}
+	if format := binary.LittleEndian.Uint16(header[20:]); format != 3 {
+		t.Errorf("Expected IEEE float format, got %d", format)
+	}
+	if rate := binary.LittleEndian.Uint32(header[24:]); rate != 48000 {
+		t.Errorf("Expected 48000 Hz, got %d", rate)
+	}
+}
+
+func TestPipeWireRecorder_CountInHoldsTheTake(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL", "Chrome:output_FR")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	// One bar of 4/4 at 480 bpm counts in for half a second
+	rec.cfg.Metronome = &config.MetronomeConfig{BPM: 120, CountIn: 2, Record: true}
+	countIn := 1
+	rec.SetMetronome(480, &countIn)
+
+	if err := rec.StartReady("count-in song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	graph.AddPort("system:capture_2")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, session := rec.GetStatus()
+		if session != nil && session.CountingIn {
+			if session.BPM != 480 {
+				t.Errorf("Expected the requested tempo, got %f", session.BPM)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the session to count in")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status, _ := rec.GetStatus(); status != StatusReady {
+		t.Fatalf("Expected READY during the count-in, got %s", status)
+	}
+
+	waitForStatusWithin(t, rec, StatusRecording, 2*time.Second)
+	if _, session := rec.GetStatus(); session.CountingIn {
+		t.Error("Expected the count-in to be over")
+	}
+
+	// The recorded click is captured as a last, sourceless track
+	channels := rec.sessionChannels()
+	if last := channels[len(channels)-1]; last.Name != config.MetronomeChannel || len(last.Sources) != 0 {
+		t.Errorf("Expected a metronome track, got %+v", last)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
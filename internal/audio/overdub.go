@@ -0,0 +1,611 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+const (
+	// overdubLeadIn is the silence played before the alignment marker, once
+	// the playback is linked to its reference input
+	overdubLeadIn = 3 * time.Second
+
+	// overdubGap separates the marker from the music, so the musician does not
+	// hear the marker as part of the song
+	overdubGap = time.Second
+
+	// overdubMarkerLevel is the amplitude of the single-sample alignment marker
+	overdubMarkerLevel = 0.9
+
+	// overdubRefChannel captures the playback next to the new channels, so
+	// that the marker gives the offset of the song in the capture
+	overdubRefChannel = "overdub_ref"
+
+	// overdubTakeTag is the stream metadata naming the overdub a track belongs to
+	overdubTakeTag = "take"
+)
+
+// OverdubOptions selects what an overdub plays and records
+type OverdubOptions struct {
+	File         string   // Recording the new tracks are appended to
+	Tracks       []int    // Stream indices of File played back, every audio track when empty
+	BackingTrack string   // Audio file played instead of the tracks of File
+	Channels     []string // Input channels recorded, every input channel when empty
+}
+
+// Overdubber is implemented by recorders that can record new tracks against an
+// existing take, while playing it back
+type Overdubber interface {
+	// StartOverdub starts playing and recording (STANDBY -> RECORDING). Stop
+	// appends the new tracks to the recording, aligned to its first sample.
+	StartOverdub(options OverdubOptions) error
+}
+
+// overdubSession is the state of an overdub while it records
+type overdubSession struct {
+	options      OverdubOptions
+	take         string           // Name of the overdub, e.g. overdub2
+	channels     []config.Channel // Channels captured, the reference last
+	captureFile  string           // Hidden until the new tracks are appended
+	playbackFile string
+	playbackCmd  *exec.Cmd
+	playbackStop chan struct{} // Closed to end the samples held by the player
+}
+
+// probedTrack is an audio stream of a recording
+type probedTrack struct {
+	Index    int
+	Channels int
+	Title    string
+	Take     string
+}
+
+// probeTracks lists the audio streams of a recording
+func probeTracks(file string) ([]probedTrack, error) {
+	output, err := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_streams", file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed for %s: %w", file, err)
+	}
+
+	var probe struct {
+		Streams []struct {
+			Index     int               `json:"index"`
+			CodecType string            `json:"codec_type"`
+			Channels  int               `json:"channels"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output for %s: %w", file, err)
+	}
+
+	var tracks []probedTrack
+	for _, stream := range probe.Streams {
+		if stream.CodecType != "audio" {
+			continue
+		}
+		tracks = append(tracks, probedTrack{
+			Index:    stream.Index,
+			Channels: stream.Channels,
+			Title:    streamTag(stream.Tags, "title"),
+			Take:     streamTag(stream.Tags, overdubTakeTag),
+		})
+	}
+	return tracks, nil
+}
+
+// streamTag returns a stream tag, which Matroska may report in upper case
+func streamTag(tags map[string]string, key string) string {
+	if value := tags[key]; value != "" {
+		return value
+	}
+	return tags[strings.ToUpper(key)]
+}
+
+// nextOverdubTake names the overdub following those already in a recording
+func nextOverdubTake(tracks []probedTrack) string {
+	last := 0
+	for _, track := range tracks {
+		if number, err := strconv.Atoi(strings.TrimPrefix(track.Take, "overdub")); err == nil && number > last {
+			last = number
+		}
+	}
+	return fmt.Sprintf("overdub%d", last+1)
+}
+
+// overdubChannels returns the named input channels, or every input channel
+func overdubChannels(channels []config.Channel, names []string) ([]config.Channel, error) {
+	if len(names) == 0 {
+		var inputs []config.Channel
+		for _, channel := range channels {
+			if channel.Type == "input" {
+				inputs = append(inputs, channel)
+			}
+		}
+		if len(inputs) == 0 {
+			return nil, fmt.Errorf("no input channel to overdub")
+		}
+		return inputs, nil
+	}
+
+	selected := make([]config.Channel, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, channel := range channels {
+			if channel.Name != name {
+				continue
+			}
+			if channel.Type != "input" {
+				return nil, fmt.Errorf("channel '%s' is a %s channel, only input channels can be overdubbed", name, channel.Type)
+			}
+			selected = append(selected, channel)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("channel '%s' is not configured", name)
+		}
+	}
+	return selected, nil
+}
+
+// playbackFilter builds the filter producing the overdub playback: silence
+// with a single-sample marker, then the selected tracks mixed to stereo
+func playbackFilter(inputs []string, sampleRate int) string {
+	leadIn := int64(overdubLeadIn.Seconds() * float64(sampleRate))
+
+	var parts []string
+	parts = append(parts, fmt.Sprintf("aevalsrc=exprs=if(eq(n\\,%d)\\,%g\\,0):s=%d:c=stereo:d=%g[lead]",
+		leadIn, overdubMarkerLevel, sampleRate, (overdubLeadIn+overdubGap).Seconds()))
+
+	var refs []string
+	for i, input := range inputs {
+		ref := fmt.Sprintf("[p%d]", i)
+		parts = append(parts, fmt.Sprintf("%saformat=sample_rates=%d:channel_layouts=stereo%s", input, sampleRate, ref))
+		refs = append(refs, ref)
+	}
+	parts = append(parts, fmt.Sprintf("%samix=inputs=%d:normalize=0,aformat=sample_rates=%d:channel_layouts=stereo[music]", strings.Join(refs, ""), len(refs), sampleRate))
+	parts = append(parts, "[lead][music]concat=n=2:v=0:a=1[out]")
+
+	return strings.Join(parts, ";")
+}
+
+// renderPlayback writes the overdub playback to a WAV file
+func renderPlayback(options OverdubOptions, tracks []probedTrack, outputFile string, sampleRate int) error {
+	input := options.File
+	var inputs []string
+	if options.BackingTrack != "" {
+		input = options.BackingTrack
+		inputs = []string{"[0:a:0]"}
+	} else if len(options.Tracks) > 0 {
+		for _, index := range options.Tracks {
+			found := false
+			for _, track := range tracks {
+				found = found || track.Index == index
+			}
+			if !found {
+				return fmt.Errorf("track %d not found in %s", index, filepath.Base(options.File))
+			}
+			inputs = append(inputs, fmt.Sprintf("[0:%d]", index))
+		}
+	} else {
+		for _, track := range tracks {
+			inputs = append(inputs, fmt.Sprintf("[0:%d]", track.Index))
+		}
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("nothing to play back from %s", filepath.Base(input))
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", input,
+		"-filter_complex", playbackFilter(inputs, sampleRate),
+		"-map", "[out]",
+		"-c:a", "pcm_f32le",
+		"-y", outputFile,
+	}
+	slog.Debug("Rendering overdub playback", "command", "ffmpeg "+strings.Join(args, " "))
+
+	if output, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to render playback: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// wavDataOffset returns the offset of the first sample of a WAV file, past
+// the chunks ffmpeg writes before the data chunk
+func wavDataOffset(reader io.Reader) (int64, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(reader, riff[:]); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("not a WAV file")
+	}
+
+	offset := int64(len(riff))
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(reader, chunk[:]); err != nil {
+			return 0, fmt.Errorf("no data chunk in WAV file")
+		}
+		offset += int64(len(chunk))
+		if string(chunk[0:4]) == "data" {
+			return offset, nil
+		}
+
+		// Chunks are padded to an even size
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		size += size % 2
+		if _, err := io.CopyN(io.Discard, reader, size); err != nil {
+			return 0, fmt.Errorf("no data chunk in WAV file")
+		}
+		offset += size
+	}
+}
+
+// heldSamples reads the samples of a playback once release is closed, and
+// ends the stream if stop is closed first
+type heldSamples struct {
+	reader  io.Reader
+	release <-chan struct{}
+	stop    <-chan struct{}
+}
+
+func (h *heldSamples) Read(p []byte) (int, error) {
+	select {
+	case <-h.release:
+	case <-h.stop:
+		return 0, io.EOF
+	}
+	select {
+	case <-h.stop:
+		return 0, io.EOF
+	default:
+	}
+	return h.reader.Read(p)
+}
+
+// overdubCaptureFile returns the file the new tracks of an overdub are
+// captured to. It is kept in a hidden directory next to the recording, so
+// that it is not listed as a song while the take runs.
+func overdubCaptureFile(recording, take string) string {
+	song := strings.TrimSuffix(filepath.Base(recording), ".mkv")
+	return filepath.Join(filepath.Dir(recording), ".overdub", fmt.Sprintf("%s_%s.mkv", song, take))
+}
+
+// keptCaptureFile returns where the capture of an overdub is moved to when
+// its tracks cannot be appended to the recording
+func (o *overdubSession) keptCaptureFile() string {
+	return filepath.Join(filepath.Dir(o.options.File), filepath.Base(o.captureFile))
+}
+
+// findMarker returns the frame of the first sample of a mono float32 stream
+// reaching half the marker level
+func findMarker(reader io.Reader) (int64, error) {
+	buffered := bufio.NewReader(reader)
+	sample := make([]byte, 4)
+	for frame := int64(0); ; frame++ {
+		if _, err := io.ReadFull(buffered, sample); err != nil {
+			return 0, fmt.Errorf("alignment marker not found after %d frames", frame)
+		}
+		value := math.Float32frombits(binary.LittleEndian.Uint32(sample))
+		if math.Abs(float64(value)) >= overdubMarkerLevel/2 {
+			return frame, nil
+		}
+	}
+}
+
+// findCaptureMarker decodes the reference track of a capture and returns the
+// frame at which the alignment marker was recorded
+func findCaptureMarker(captureFile string, stream int) (int64, error) {
+	cmd := exec.Command("ffmpeg", "-v", "error", "-i", captureFile, "-map", fmt.Sprintf("0:a:%d", stream), "-f", "f32le", "-ac", "1", "-")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to decode reference track: %w", err)
+	}
+
+	frame, err := findMarker(stdout)
+	cmd.Process.Kill()
+	cmd.Wait()
+	return frame, err
+}
+
+// streamArgs scopes encoder options to one output audio stream:
+// -c:a flac becomes -c:a:3 flac
+func streamArgs(args []string, stream int) []string {
+	scoped := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case !strings.HasPrefix(arg, "-"):
+			scoped[i] = arg
+		case strings.HasSuffix(arg, ":a"):
+			scoped[i] = fmt.Sprintf("%s:%d", arg, stream)
+		default:
+			scoped[i] = fmt.Sprintf("%s:a:%d", arg, stream)
+		}
+	}
+	return scoped
+}
+
+// mergeArgs builds the FFmpeg arguments appending the overdub tracks of a
+// capture to the recording: the existing streams are copied, the new ones are
+// trimmed to the first frame of the song and encoded like a capture
+func (o *overdubSession) mergeArgs(baseTracks int, start int64, capture config.CaptureConfig, outputFile string) []string {
+	recorded := o.channels[:len(o.channels)-1]
+
+	var filters []string
+	for i, channel := range recorded {
+		filter := fmt.Sprintf("[1:a:%d]atrim=start_sample=%d,asetpts=PTS-STARTPTS", i, start)
+		if layout := channel.ChannelLayout(); layout != "" && channel.ChannelCount() > 2 {
+			filter += ",channelmap=channel_layout=" + layout
+		}
+		filters = append(filters, fmt.Sprintf("%s[o%d]", filter, i))
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", o.options.File,
+		"-i", o.captureFile,
+		"-filter_complex", strings.Join(filters, ";"),
+		"-map", "0",
+	}
+	for i := range recorded {
+		args = append(args, "-map", fmt.Sprintf("[o%d]", i))
+	}
+	args = append(args, "-c", "copy")
+	for i, channel := range recorded {
+		stream := baseTracks + i
+		args = append(args, streamArgs(capture.EncoderArgs(), stream)...)
+		args = append(args,
+			fmt.Sprintf("-metadata:s:a:%d", stream), fmt.Sprintf("title=%s (%s)", channel.Name, o.take),
+			fmt.Sprintf("-metadata:s:a:%d", stream), fmt.Sprintf("%s=%s", overdubTakeTag, o.take),
+		)
+	}
+	return append(args, "-y", outputFile)
+}
+
+// merge appends the overdub tracks to the recording, replacing it once the
+// new file is complete
+func (o *overdubSession) merge(capture config.CaptureConfig, sampleRate int) error {
+	marker, err := findCaptureMarker(o.captureFile, len(o.channels)-1)
+	if err != nil {
+		return err
+	}
+	start := marker + int64(overdubGap.Seconds()*float64(sampleRate))
+
+	tracks, err := probeTracks(o.options.File)
+	if err != nil {
+		return err
+	}
+
+	mergedFile := strings.TrimSuffix(o.options.File, ".mkv") + ".merging.mkv"
+	args := o.mergeArgs(len(tracks), start, capture, mergedFile)
+	slog.Debug("Appending overdub tracks", "command", "ffmpeg "+strings.Join(args, " "))
+
+	if output, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		os.Remove(mergedFile)
+		return fmt.Errorf("failed to append tracks: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+	if err := os.Rename(mergedFile, o.options.File); err != nil {
+		os.Remove(mergedFile)
+		return fmt.Errorf("failed to replace recording: %w", err)
+	}
+
+	slog.Info("Overdub appended", "file", o.options.File, "take", o.take, "offset_frames", start, "tracks", len(o.channels)-1)
+	return nil
+}
+
+// startPlayback starts the player of the overdub on its stdin. The samples
+// are held until linked reports the reference channel linked, so that the
+// marker cannot be played before the capture records it.
+func (o *overdubSession) startPlayback(cmd *exec.Cmd, linked func() bool) error {
+	file, err := os.Open(o.playbackFile)
+	if err != nil {
+		return fmt.Errorf("failed to open playback: %w", err)
+	}
+	header, err := wavDataOffset(file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read playback: %w", err)
+	}
+
+	release := make(chan struct{})
+	o.playbackStop = make(chan struct{})
+	cmd.Stdin = io.MultiReader(io.LimitReader(file, header), &heldSamples{reader: file, release: release, stop: o.playbackStop})
+	if err := cmd.Start(); err != nil {
+		file.Close()
+		o.playbackStop = nil
+		return fmt.Errorf("failed to start playback: %w", err)
+	}
+	o.playbackCmd = cmd
+
+	go func(stop <-chan struct{}) {
+		defer file.Close()
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for !linked() {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+		slog.Debug("Overdub reference linked, releasing the playback", "take", o.take)
+		close(release)
+		<-stop
+	}(o.playbackStop)
+	return nil
+}
+
+// stopPlayback ends the playback if it is still running
+func (o *overdubSession) stopPlayback() {
+	if o.playbackStop != nil {
+		close(o.playbackStop)
+		o.playbackStop = nil
+	}
+	if o.playbackCmd != nil && o.playbackCmd.Process != nil {
+		o.playbackCmd.Process.Kill()
+		o.playbackCmd.Wait()
+	}
+	o.playbackCmd = nil
+}
+
+// StartOverdub plays a recording, or a backing track, and records input
+// channels against it (STANDBY -> RECORDING)
+func (r *PipeWireRecorder) StartOverdub(options OverdubOptions) error {
+	// A monitor left over from an earlier ERROR state must not outlive this session
+	r.stopSourceMonitoring()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusStandby && r.status != StatusError {
+		return fmt.Errorf("can only start an overdub from standby or error state, current: %s", r.status)
+	}
+	if _, err := os.Stat(options.File); err != nil {
+		return fmt.Errorf("recording to overdub not found: %s", options.File)
+	}
+	r.lastError = nil
+
+	if err := r.checkClock(); err != nil {
+		return err
+	}
+
+	r.sources.reset()
+	snapshot := r.refreshSnapshot()
+	r.sources.resolve(r.cfg.Channels, snapshot, r.pipewire.graph)
+	channels, err := overdubChannels(r.sessionChannels(), options.Channels)
+	if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		for _, source := range channel.Sources {
+			if source != "" && source != "disabled" && !snapshot.Exists(source) {
+				return fmt.Errorf("source %s of channel '%s' is not available", source, channel.Name)
+			}
+		}
+	}
+
+	tracks, err := probeTracks(options.File)
+	if err != nil {
+		return err
+	}
+
+	song := strings.TrimSuffix(filepath.Base(options.File), ".mkv")
+	take := nextOverdubTake(tracks)
+	playbackNode := r.clientName + "_overdub_playback"
+	overdub := &overdubSession{
+		options:      options,
+		take:         take,
+		captureFile:  overdubCaptureFile(options.File, take),
+		playbackFile: filepath.Join(os.TempDir(), fmt.Sprintf("jamcapture_%s_%s.wav", song, take)),
+		channels: append(channels, config.Channel{
+			Name:      overdubRefChannel,
+			Sources:   []string{playbackNode + ":output_FL"},
+			AudioMode: "mono",
+			Type:      "monitor",
+			Volume:    1.0,
+		}),
+	}
+
+	if err := renderPlayback(options, tracks, overdub.playbackFile, r.sampleRate()); err != nil {
+		return err
+	}
+
+	// The capture must run before the playback starts, or the marker is lost
+	os.Remove(overdub.captureFile)
+	if err := os.MkdirAll(filepath.Dir(overdub.captureFile), 0755); err != nil {
+		os.Remove(overdub.playbackFile)
+		return fmt.Errorf("failed to create overdub directory: %w", err)
+	}
+	if err := r.startCapture(overdub.channels, overdub.captureFile); err != nil {
+		os.Remove(overdub.playbackFile)
+		r.status = StatusError
+		return fmt.Errorf("failed to start FFmpeg: %w", err)
+	}
+	linked := func() bool {
+		state := r.channels.state(overdubRefChannel)
+		return state == ChannelLinked || state == ChannelReconnected
+	}
+	if err := overdub.startPlayback(r.playerCommand("-", playbackNode, "jamcapture overdub", ""), linked); err != nil {
+		r.stopFFmpeg()
+		os.Remove(overdub.playbackFile)
+		r.status = StatusError
+		return err
+	}
+
+	channelNames := make([]string, len(channels))
+	for i, channel := range channels {
+		channelNames[i] = fmt.Sprintf("%s:[%s]", channel.Name, strings.Join(channel.Sources, ","))
+	}
+	r.overdub = overdub
+	r.session = &SessionInfo{
+		SongName:     song,
+		StartTime:    time.Now(),
+		OutputFile:   options.File,
+		ChannelCount: len(channels),
+		ChannelNames: channelNames,
+		Trigger:      config.TriggerManual,
+		Overdub:      take,
+	}
+
+	r.channels.scan(overdub.channels, snapshot)
+	r.isRecording = true
+	r.recordingStarted = time.Now()
+	r.status = StatusRecording
+
+	slog.Info("PipeWire overdub started", "file", options.File, "take", take, "channels", len(channels))
+
+	r.stopChan = make(chan struct{})
+	go r.recordingWorker(overdub.channels, r.stopChan)
+
+	return nil
+}
+
+// finishOverdub stops the playback of a stopped overdub and appends its
+// tracks to the recording. The raw capture is moved next to the recording if
+// they cannot be appended. Must hold r.mutex.
+func (r *PipeWireRecorder) finishOverdub() error {
+	overdub := r.overdub
+	r.overdub = nil
+
+	overdub.stopPlayback()
+	defer os.Remove(overdub.playbackFile)
+
+	if err := r.finishOutput(overdub.captureFile); err != nil {
+		r.status = StatusError
+		return fmt.Errorf("failed to finish overdub: %w", err)
+	}
+	if err := overdub.merge(r.cfg.GetCapture(), r.sampleRate()); err != nil {
+		r.status = StatusError
+		kept := overdub.keptCaptureFile()
+		if renameErr := os.Rename(overdub.captureFile, kept); renameErr != nil {
+			kept = overdub.captureFile
+		}
+		os.Remove(filepath.Dir(overdub.captureFile))
+		return fmt.Errorf("failed to add %s to %s, raw capture kept in %s: %w", overdub.take, filepath.Base(overdub.options.File), kept, err)
+	}
+	os.Remove(overdub.captureFile)
+	// Only removed once no other overdub is being captured
+	os.Remove(filepath.Dir(overdub.captureFile))
+
+	r.status = StatusStandby
+	return nil
+}
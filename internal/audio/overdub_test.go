@@ -0,0 +1,192 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+func TestFindMarker(t *testing.T) {
+	samples := make([]float32, 100)
+	samples[10] = 0.1 // Playback noise stays below the marker
+	samples[42] = 0.88
+	samples[60] = 0.9
+
+	var buffer bytes.Buffer
+	for _, sample := range samples {
+		binary.Write(&buffer, binary.LittleEndian, math.Float32bits(sample))
+	}
+
+	frame, err := findMarker(&buffer)
+	if err != nil {
+		t.Fatalf("findMarker failed: %v", err)
+	}
+	if frame != 42 {
+		t.Errorf("Expected the marker at frame 42, got %d", frame)
+	}
+
+	if _, err := findMarker(bytes.NewReader(make([]byte, 400))); err == nil {
+		t.Error("Expected an error for a capture without marker")
+	}
+}
+
+func TestStreamArgs(t *testing.T) {
+	args := []string{"-c:a", "flac", "-sample_fmt", "s32", "-bits_per_raw_sample", "24", "-compression_level", "5"}
+	expected := "-c:a:3 flac -sample_fmt:a:3 s32 -bits_per_raw_sample:a:3 24 -compression_level:a:3 5"
+	if got := strings.Join(streamArgs(args, 3), " "); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestNextOverdubTake(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracks   []probedTrack
+		expected string
+	}{
+		{"original take only", []probedTrack{{Index: 0}, {Index: 1}}, "overdub1"},
+		{"after two overdubs", []probedTrack{{Index: 0}, {Index: 1, Take: "overdub2"}, {Index: 2, Take: "overdub1"}}, "overdub3"},
+		{"unrelated tag", []probedTrack{{Index: 0, Take: "live"}}, "overdub1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextOverdubTake(tt.tracks); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOverdubChannels(t *testing.T) {
+	channels := []config.Channel{
+		{Name: "guitar", Sources: []string{"system:capture_1"}, Type: "input"},
+		{Name: "backing", Sources: []string{"spotify:output_FL", "spotify:output_FR"}, Type: "monitor"},
+		{Name: "vocals", Sources: []string{"system:capture_2"}, Type: "input"},
+	}
+
+	all, err := overdubChannels(channels, nil)
+	if err != nil {
+		t.Fatalf("overdubChannels failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "guitar" || all[1].Name != "vocals" {
+		t.Errorf("Expected every input channel, got %v", all)
+	}
+
+	selected, err := overdubChannels(channels, []string{"vocals"})
+	if err != nil {
+		t.Fatalf("overdubChannels failed: %v", err)
+	}
+	if len(selected) != 1 || selected[0].Name != "vocals" {
+		t.Errorf("Expected the vocals channel, got %v", selected)
+	}
+
+	if _, err := overdubChannels(channels, []string{"backing"}); err == nil {
+		t.Error("Expected an error for a monitor channel")
+	}
+	if _, err := overdubChannels(channels, []string{"bass"}); err == nil {
+		t.Error("Expected an error for an unknown channel")
+	}
+}
+
+func TestOverdubMergeArgs(t *testing.T) {
+	overdub := &overdubSession{
+		options:     OverdubOptions{File: "/rec/song.mkv"},
+		take:        "overdub1",
+		captureFile: "/rec/song_overdub1.mkv",
+		channels: []config.Channel{
+			{Name: "guitar", Sources: []string{"system:capture_1"}, AudioMode: "mono", Type: "input"},
+			{Name: overdubRefChannel, Sources: []string{"jamcapture_overdub_playback:output_FL"}, AudioMode: "mono", Type: "monitor"},
+		},
+	}
+
+	args := strings.Join(overdub.mergeArgs(2, 192000, config.CaptureConfig{Codec: "pcm_s24le", BitDepth: 24}, "/rec/song.merging.mkv"), " ")
+
+	for _, expected := range []string{
+		"-i /rec/song.mkv -i /rec/song_overdub1.mkv",
+		"-filter_complex [1:a:0]atrim=start_sample=192000,asetpts=PTS-STARTPTS[o0]",
+		"-map 0 -map [o0] -c copy -c:a:2 pcm_s24le",
+		"-metadata:s:a:2 title=guitar (overdub1) -metadata:s:a:2 take=overdub1",
+	} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("Expected %q in %q", expected, args)
+		}
+	}
+	if strings.Contains(args, "[1:a:1]") {
+		t.Errorf("Expected the reference track to be left out, got %q", args)
+	}
+}
+
+func TestWavDataOffset(t *testing.T) {
+	// ffmpeg writes a LIST chunk, of odd size here, before the samples
+	var wav bytes.Buffer
+	wav.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	wav.WriteString("fmt \x10\x00\x00\x00")
+	wav.Write(make([]byte, 16))
+	wav.WriteString("LIST\x05\x00\x00\x00INFOx\x00")
+	wav.WriteString("data\x08\x00\x00\x00")
+	wav.Write(make([]byte, 8))
+
+	offset, err := wavDataOffset(bytes.NewReader(wav.Bytes()))
+	if err != nil {
+		t.Fatalf("wavDataOffset failed: %v", err)
+	}
+	if offset != int64(wav.Len()-8) {
+		t.Errorf("Expected the samples at %d, got %d", wav.Len()-8, offset)
+	}
+
+	if _, err := wavDataOffset(strings.NewReader("RIFF\x00\x00\x00\x00WAVE")); err == nil {
+		t.Error("Expected an error for a WAV file without data chunk")
+	}
+	if _, err := wavDataOffset(strings.NewReader("not a wav file")); err == nil {
+		t.Error("Expected an error for a file that is not a WAV file")
+	}
+}
+
+func TestHeldSamples(t *testing.T) {
+	release, stop := make(chan struct{}), make(chan struct{})
+	held := &heldSamples{reader: strings.NewReader("samples"), release: release, stop: stop}
+
+	read := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(held)
+		read <- string(data)
+	}()
+
+	select {
+	case data := <-read:
+		t.Fatalf("Expected the samples to be held, got %q", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if data := <-read; data != "samples" {
+		t.Errorf("Expected the samples once released, got %q", data)
+	}
+
+	// Stopping ends a stream that was never released
+	held = &heldSamples{reader: strings.NewReader("samples"), release: make(chan struct{}), stop: stop}
+	close(stop)
+	if data, err := io.ReadAll(held); err != nil || len(data) != 0 {
+		t.Errorf("Expected an empty stream once stopped, got %q (%v)", data, err)
+	}
+}
+
+func TestOverdubCaptureFile(t *testing.T) {
+	overdub := &overdubSession{
+		options:     OverdubOptions{File: "/rec/song.mkv"},
+		captureFile: overdubCaptureFile("/rec/song.mkv", "overdub2"),
+	}
+	if overdub.captureFile != "/rec/.overdub/song_overdub2.mkv" {
+		t.Errorf("Expected the capture in a hidden directory, got %s", overdub.captureFile)
+	}
+	if kept := overdub.keptCaptureFile(); kept != "/rec/song_overdub2.mkv" {
+		t.Errorf("Expected a failed capture next to the recording, got %s", kept)
+	}
+}
@@ -3,78 +3,96 @@ package audio
 import (
 	"fmt"
 	"log/slog"
-	"os/exec"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
 )
 
+// retryPolicy controls how often a port connection is attempted
+type retryPolicy struct {
+	maxRetries int
+	delay      time.Duration
+}
+
 // PipeWire manages PipeWire/JACK port operations
-type PipeWire struct{}
+type PipeWire struct {
+	graph PortGraph
+
+	// Retry strategies for ConnectPortsWithRetry
+	ephemeralRetry retryPolicy
+	hardwareRetry  retryPolicy
+
+	// Cached port snapshot shared by validations
+	snapshotMutex sync.Mutex
+	snapshot      *PortSnapshot
+	snapshotTTL   time.Duration
+}
 
-// NewPipeWire creates a new PipeWire instance
+// NewPipeWire creates a new PipeWire instance backed by pw-link
 func NewPipeWire() *PipeWire {
-	return &PipeWire{}
+	return NewPipeWireWithGraph(&PwLinkGraph{})
+}
+
+// NewPipeWireWithGraph creates a PipeWire instance operating on the given port graph
+func NewPipeWireWithGraph(graph PortGraph) *PipeWire {
+	return &PipeWire{
+		graph: graph,
+		// Browsers and streaming apps may take longer to appear
+		ephemeralRetry: retryPolicy{maxRetries: 15, delay: 1 * time.Second},
+		// Hardware devices should be available quickly
+		hardwareRetry: retryPolicy{maxRetries: 5, delay: 500 * time.Millisecond},
+		snapshotTTL:   defaultSnapshotTTL,
+	}
 }
 
 // ListPorts returns all available JACK ports via PipeWire
 func (pw *PipeWire) ListPorts() ([]string, error) {
-	cmd := exec.Command("pw-link", "-io")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list PipeWire ports: %w", err)
-	}
+	return pw.graph.ListPorts()
+}
 
-	lines := strings.Split(string(output), "\n")
-	var ports []string
+// Snapshot returns the cached port snapshot, listing the graph again once
+// the cached one is older than the snapshot TTL
+func (pw *PipeWire) Snapshot() (*PortSnapshot, error) {
+	pw.snapshotMutex.Lock()
+	snapshot := pw.snapshot
+	pw.snapshotMutex.Unlock()
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && !strings.HasPrefix(line, "Input ports:") && !strings.HasPrefix(line, "Output ports:") {
-			ports = append(ports, line)
-		}
+	if snapshot != nil && !snapshot.expired(pw.snapshotTTL) {
+		return snapshot, nil
 	}
 
-	return ports, nil
+	return pw.RefreshSnapshot()
 }
 
-// ValidatePort checks if a specific port exists and has no duplicates
-func (pw *PipeWire) ValidatePort(portName string) error {
-	if portName == "" || portName == "disabled" {
-		return nil
-	}
-
-	if !pw.portExists(portName) {
-		return fmt.Errorf("port not found: %s", portName)
-	}
-
-	// Check for duplicate sources
-	duplicates, err := pw.findPortDuplicates(portName)
+// RefreshSnapshot lists the graph now and caches the result for later validations
+func (pw *PipeWire) RefreshSnapshot() (*PortSnapshot, error) {
+	snapshot, err := TakePortSnapshot(pw.graph)
 	if err != nil {
-		return fmt.Errorf("failed to check port duplicates: %w", err)
+		return nil, err
 	}
 
-	if len(duplicates) > 1 {
-		return fmt.Errorf("duplicate sources detected for '%s': %v. Please close conflicting applications", portName, duplicates)
-	}
+	pw.snapshotMutex.Lock()
+	pw.snapshot = snapshot
+	pw.snapshotMutex.Unlock()
 
-	return nil
+	return snapshot, nil
 }
 
-// findPortDuplicates finds all ports with exactly the same name
-func (pw *PipeWire) findPortDuplicates(portName string) ([]string, error) {
-	allPorts, err := pw.ListPorts()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list ports: %w", err)
+// ValidatePort checks if a specific port exists and has no duplicates
+func (pw *PipeWire) ValidatePort(portName string) error {
+	if portName == "" || portName == "disabled" {
+		return nil
 	}
 
-	var duplicates []string
-	for _, port := range allPorts {
-		if port == portName {
-			duplicates = append(duplicates, port)
-		}
+	snapshot, err := pw.Snapshot()
+	if err != nil {
+		slog.Debug("Failed to check port existence", "port", portName, "error", err)
+		return fmt.Errorf("port not found: %s", portName)
 	}
 
-	return duplicates, nil
+	return snapshot.ValidatePort(portName)
 }
 
 // findPortDuplicatesInList finds duplicates in a provided port list (for testing)
@@ -89,52 +107,52 @@ func (pw *PipeWire) findPortDuplicatesInList(portName string, allPorts []string)
 	return duplicates
 }
 
-// portExists checks if a port exists in the current JACK graph
+// portExists checks if a port exists in the current JACK graph.
+// It always lists the graph so that retry loops see ports as they appear.
 func (pw *PipeWire) portExists(portName string) bool {
-	cmd := exec.Command("pw-link", "-io")
-	output, err := cmd.Output()
+	snapshot, err := pw.RefreshSnapshot()
 	if err != nil {
 		slog.Debug("Failed to check port existence", "port", portName, "error", err)
 		return false
 	}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.TrimSpace(line) == portName {
-			return true
-		}
-	}
-
-	return false
+	return snapshot.Exists(portName)
 }
 
 // ConnectPortsWithRetry connects two JACK ports with intelligent retry logic
 func (pw *PipeWire) ConnectPortsWithRetry(sourcePort, destPort string) error {
+	_, err := pw.ConnectSourceWithRetry(sourcePort, destPort, config.DuplicateRule{Policy: config.DuplicatesBlock})
+	return err
+}
+
+// ConnectSourceWithRetry connects a source like ConnectPortsWithRetry. When
+// several clients expose the source, the instances picked by the duplicate
+// rule are linked. It returns the ports that were linked: the source name,
+// or the IDs of the picked instances.
+func (pw *PipeWire) ConnectSourceWithRetry(sourcePort, destPort string, rule config.DuplicateRule) ([]string, error) {
 	// Determine if this is an ephemeral port (browser, etc.)
 	isEphemeral := pw.isEphemeralPort(sourcePort)
 
-	var maxRetries int
-	var retryDelay time.Duration
-
+	policy := pw.hardwareRetry
 	if isEphemeral {
-		// Browsers and streaming apps may take longer to appear
-		maxRetries = 15
-		retryDelay = 1 * time.Second
-		slog.Debug("Using ephemeral port retry strategy", "source", sourcePort, "retries", maxRetries)
+		policy = pw.ephemeralRetry
+		slog.Debug("Using ephemeral port retry strategy", "source", sourcePort, "retries", policy.maxRetries)
 	} else {
-		// Hardware devices should be available quickly
-		maxRetries = 5
-		retryDelay = 500 * time.Millisecond
-		slog.Debug("Using hardware port retry strategy", "source", sourcePort, "retries", maxRetries)
+		slog.Debug("Using hardware port retry strategy", "source", sourcePort, "retries", policy.maxRetries)
 	}
+	maxRetries := policy.maxRetries
+	retryDelay := policy.delay
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		if pw.portExists(sourcePort) {
 			// Port exists, try to connect
-			err := pw.connectPorts(sourcePort, destPort)
+			ports, err := pw.pickInstances(sourcePort, rule)
+			if err == nil {
+				err = pw.connectAll(ports, destPort)
+			}
 			if err == nil {
-				slog.Debug("Successfully connected ports", "source", sourcePort, "dest", destPort, "attempt", attempt)
-				return nil
+				slog.Debug("Successfully connected ports", "source", sourcePort, "ports", ports, "dest", destPort, "attempt", attempt)
+				return ports, nil
 			}
 			slog.Debug("Connection attempt failed", "source", sourcePort, "dest", destPort, "attempt", attempt, "error", err)
 		} else {
@@ -146,15 +164,129 @@ func (pw *PipeWire) ConnectPortsWithRetry(sourcePort, destPort string) error {
 		}
 	}
 
-	return fmt.Errorf("failed to connect %s to %s after %d attempts", sourcePort, destPort, maxRetries)
+	return nil, fmt.Errorf("failed to connect %s to %s after %d attempts", sourcePort, destPort, maxRetries)
+}
+
+// connectAll links every picked instance of a source to the same input,
+// where PipeWire mixes them
+func (pw *PipeWire) connectAll(ports []string, destPort string) error {
+	for _, port := range ports {
+		if err := pw.connectPorts(port, destPort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickInstances returns the instances of a duplicated source chosen by the
+// duplicate rule, or the port name when it is unique, duplicates block, or
+// the graph cannot tell instances apart. A "match" rule fails until an
+// instance with the configured properties appears.
+func (pw *PipeWire) pickInstances(portName string, rule config.DuplicateRule) ([]string, error) {
+	if rule.Policy == "" || rule.Policy == config.DuplicatesBlock {
+		return []string{portName}, nil
+	}
+
+	lister, ok := pw.graph.(PortInstanceLister)
+	if !ok {
+		return []string{portName}, nil
+	}
+	ids, err := lister.ListPortIDs(portName)
+	if err != nil || len(ids) < 2 {
+		return []string{portName}, nil
+	}
+
+	var picked []string
+	switch rule.Policy {
+	case config.DuplicatesOldest:
+		picked = ids[:1]
+	case config.DuplicatesNewest:
+		picked = ids[len(ids)-1:]
+	case config.DuplicatesAll:
+		picked = ids
+	case config.DuplicatesMatch:
+		matching := pw.matchingInstances(ids, rule.Match)
+		if len(matching) == 0 {
+			return nil, fmt.Errorf("none of the %d instances of %s matches %v", len(ids), portName, rule.Match)
+		}
+		// Several matching windows are resolved like "newest"
+		picked = matching[len(matching)-1:]
+	default:
+		return []string{portName}, nil
+	}
+
+	slog.Info("Resolved duplicate source", "source", portName, "policy", rule.Policy, "port_ids", picked, "instances", len(ids))
+	return picked, nil
+}
+
+// matchingInstances returns the instances whose properties have every
+// configured value
+func (pw *PipeWire) matchingInstances(ids []string, matches []config.PropertyMatch) []string {
+	lister, ok := pw.graph.(PortPropertiesLister)
+	if !ok {
+		return nil
+	}
+
+	var matching []string
+	for _, id := range ids {
+		props, err := lister.PortProperties(id)
+		if err != nil {
+			slog.Debug("Failed to read port properties", "port_id", id, "error", err)
+			continue
+		}
+		if propertiesMatch(props, matches) {
+			matching = append(matching, id)
+		}
+	}
+	return matching
+}
+
+// propertiesMatch reports whether properties have every configured value
+func propertiesMatch(props map[string]string, matches []config.PropertyMatch) bool {
+	for _, match := range matches {
+		if props[match.Property] != match.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// instanceExists reports whether one of the instances linked for a source
+// is still in the graph. A source linked by name only needs any instance.
+func (pw *PipeWire) instanceExists(snapshot *PortSnapshot, portName string, ports []string) bool {
+	if !snapshot.Exists(portName) {
+		return false
+	}
+
+	lister, ok := pw.graph.(PortInstanceLister)
+	if !ok {
+		return true
+	}
+
+	var ids []string
+	for _, port := range ports {
+		if port == portName {
+			return true
+		}
+		if ids == nil {
+			var err error
+			if ids, err = lister.ListPortIDs(portName); err != nil {
+				return true
+			}
+		}
+		for _, id := range ids {
+			if id == port {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // connectPorts performs the actual port connection
 func (pw *PipeWire) connectPorts(sourcePort, destPort string) error {
-	cmd := exec.Command("pw-link", sourcePort, destPort)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to connect ports: %w (output: %s)", err, string(output))
+	if err := pw.graph.Connect(sourcePort, destPort); err != nil {
+		return err
 	}
 
 	slog.Debug("Connected ports successfully", "source", sourcePort, "dest", destPort)
@@ -181,10 +313,8 @@ func (pw *PipeWire) isEphemeralPort(portName string) bool {
 
 // DisconnectPorts disconnects two JACK ports
 func (pw *PipeWire) DisconnectPorts(sourcePort, destPort string) error {
-	cmd := exec.Command("pw-link", "-d", sourcePort, destPort)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to disconnect ports: %w (output: %s)", err, string(output))
+	if err := pw.graph.Disconnect(sourcePort, destPort); err != nil {
+		return err
 	}
 
 	slog.Debug("Disconnected ports successfully", "source", sourcePort, "dest", destPort)
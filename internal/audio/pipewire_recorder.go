@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,6 +16,15 @@ import (
 	"github.com/audiolibrelab/jamcapture/internal/config"
 )
 
+const (
+	// sourcePollInterval is how often linked sources are checked while recording
+	// when the port graph cannot push events
+	sourcePollInterval = 500 * time.Millisecond
+
+	// sourceFallbackInterval is the safety-net check interval for event-driven graphs
+	sourceFallbackInterval = 5 * time.Second
+)
+
 // PipeWireRecorder implements the Recorder interface using PipeWire/JACK
 type PipeWireRecorder struct {
 	cfg       *config.Config
@@ -23,6 +33,9 @@ type PipeWireRecorder struct {
 	// PipeWire components
 	pipewire *PipeWire
 
+	// clientName prefixes the JACK client capturing each channel
+	clientName string
+
 	// Recording state
 	mutex       sync.RWMutex
 	status      Status
@@ -30,36 +43,102 @@ type PipeWireRecorder struct {
 	isRecording bool
 	stopChan    chan struct{}
 
+	// recordingStarted is when FFmpeg was started, the origin of connection event offsets
+	recordingStarted time.Time
+
 	// FFmpeg process
 	ffmpegCmd *exec.Cmd
 	stdoutBuf strings.Builder
 	stderrBuf strings.Builder
 
+	// startCapture launches the capture process (buildAndStartFFmpeg by default).
+	// An empty outputFile only feeds the meter and the trigger taps.
+	startCapture func(channels []config.Channel, outputFile string) error
+
+	// startEncoder launches the process writing the take of an armed session and
+	// returns one raw audio input per channel (buildAndStartEncoder by default)
+	startEncoder func(channels []config.Channel, outputFile string) ([]io.WriteCloser, error)
+
+	// joinSegments builds a take from its segments once written (stitchSegments by default)
+	joinSegments func(outputFile string) error
+
 	// Source monitoring
 	sourceMonitorStop chan struct{}
 	sourceMonitorDone chan struct{}
 
-	// Channel status cache
-	channelStatusCache     map[string]string
-	channelStatusCacheTime time.Time
+	// lastError is why the recorder last ended a session on its own
+	lastError error
+
+	// Per-channel connection state
+	channels channelTracker
+
+	// Concrete ports of the glob, regex and selector sources of the session
+	sources sourceResolver
+
+	// Per-channel levels fed by the FFmpeg metering outputs
+	meter *LevelMeter
+
+	// Armed capture of the signal and manual triggers, nil unless armed
+	taps       map[string]*triggerTap
+	tapsDone   sync.WaitGroup
+	armedAt    time.Time
+	encoderCmd *exec.Cmd
+
+	// Encoders of takes closed by an auto-split that are still finishing their file
+	closingTakes sync.WaitGroup
+
+	// Playback and capture of an overdub, nil unless overdubbing
+	overdub *overdubSession
+
+	// Backing track played into the playback channel when a take starts
+	backing backingTrack
+
+	// Click of the session and its count-in
+	click metronomeState
 }
 
 // NewPipeWireRecorder creates a new PipeWire-based recorder
 func NewPipeWireRecorder(cfg *config.Config, logWriter io.Writer) *PipeWireRecorder {
+	return NewPipeWireRecorderWithGraph(cfg, logWriter, NewWatchedPortGraph())
+}
+
+// NewPipeWireRecorderWithGraph creates a PipeWire recorder operating on the given port graph
+func NewPipeWireRecorderWithGraph(cfg *config.Config, logWriter io.Writer, graph PortGraph) *PipeWireRecorder {
 	if logWriter == nil {
 		logWriter = io.Discard
 	}
 
-	return &PipeWireRecorder{
-		cfg:       cfg,
-		logWriter: logWriter,
-		pipewire:  NewPipeWire(),
-		status:    StatusStandby,
+	r := &PipeWireRecorder{
+		cfg:        cfg,
+		logWriter:  logWriter,
+		pipewire:   NewPipeWireWithGraph(graph),
+		status:     StatusStandby,
+		clientName: DefaultClientName,
 	}
+	r.startCapture = r.buildAndStartFFmpeg
+	r.startEncoder = r.buildAndStartEncoder
+	r.joinSegments = stitchSegments
+	return r
+}
+
+// SetClientName sets the prefix of the capture clients, used from the next session
+func (r *PipeWireRecorder) SetClientName(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.clientName = name
+}
+
+// captureClient returns the name of the JACK client capturing a channel
+func (r *PipeWireRecorder) captureClient(channel string) string {
+	return r.clientName + "_" + channel
 }
 
 // StartReady transitions from STANDBY to READY state
 func (r *PipeWireRecorder) StartReady(songName string) error {
+	// A monitor left over from an earlier ERROR state must not outlive this session
+	r.stopSourceMonitoring()
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -70,6 +149,16 @@ func (r *PipeWireRecorder) StartReady(songName string) error {
 	if songName == "" {
 		return fmt.Errorf("song name is required")
 	}
+	r.lastError = nil
+
+	if err := r.checkClock(); err != nil {
+		return err
+	}
+
+	// Source patterns are resolved against the graph from now on
+	r.sources.reset()
+	snapshot := r.refreshSnapshot()
+	r.sources.resolve(r.cfg.Channels, snapshot, r.pipewire.graph)
 
 	// Create output directory
 	if err := os.MkdirAll(r.cfg.Output.Directory, 0755); err != nil {
@@ -77,18 +166,25 @@ func (r *PipeWireRecorder) StartReady(songName string) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	if err := r.prepareBackingTrack(); err != nil {
+		return err
+	}
+
+	r.click.config = r.sessionMetronome()
+	r.click.countedIn = false
+
 	// Prepare session info
 	cleanName := r.cleanFileName(songName)
 	outputFile := filepath.Join(r.cfg.Output.Directory, cleanName+".mkv")
 
-	enabledChannels := r.cfg.Channels
+	enabledChannels := r.sessionChannels()
 	channelNames := make([]string, len(enabledChannels))
 	for i, ch := range enabledChannels {
 		channelNames[i] = fmt.Sprintf("%s:[%s]", ch.Name, strings.Join(ch.Sources, ","))
 	}
 
 	// Validate sources before transitioning to READY state
-	if r.hasDuplicateSources() {
+	if r.hasDuplicateSources(snapshot) {
 		r.status = StatusError
 		// Start monitoring even in error state so we can auto-recover when duplicates are resolved
 		r.startSourceMonitoring()
@@ -101,6 +197,10 @@ func (r *PipeWireRecorder) StartReady(songName string) error {
 		OutputFile:   outputFile,
 		ChannelCount: len(enabledChannels),
 		ChannelNames: channelNames,
+		Trigger:      r.cfg.GetTrigger().Mode,
+	}
+	if r.click.config != nil {
+		r.session.BPM = r.click.config.BPM
 	}
 
 	r.status = StatusReady
@@ -125,93 +225,453 @@ func (r *PipeWireRecorder) StartRecording() error {
 		return fmt.Errorf("no session prepared, call StartReady first")
 	}
 
-	// Update channel status cache before starting
-	r.scanChannelStatus()
+	// The take waits for the downbeat that ends the count-in
+	if r.holdForCountIn() {
+		return nil
+	}
+
+	if r.taps != nil {
+		return r.fireTrigger()
+	}
+
+	// Record which channels were found before linking starts
+	r.channels.scan(r.sessionChannels(), r.currentSnapshot())
 
 	// Remove existing output file
 	os.Remove(r.session.OutputFile)
 
 	// Build and start FFmpeg command
-	enabledChannels := r.cfg.Channels
-	if err := r.buildAndStartFFmpeg(enabledChannels, r.session.OutputFile); err != nil {
+	enabledChannels := r.sessionChannels()
+	if err := r.startCapture(enabledChannels, r.session.OutputFile); err != nil {
 		r.status = StatusError
 		return fmt.Errorf("failed to start FFmpeg: %w", err)
 	}
 
 	r.isRecording = true
+	r.recordingStarted = time.Now()
+	r.session.startTake(r.session.OutputFile, r.recordingStarted)
 	r.status = StatusRecording
 
 	slog.Info("PipeWire recording started", "song", r.session.SongName, "channels", len(enabledChannels))
 
 	// Start background goroutine to monitor FFmpeg and handle connections
 	r.stopChan = make(chan struct{})
-	go r.recordingWorker(enabledChannels)
+	go r.recordingWorker(enabledChannels, r.stopChan)
+
+	if err := r.startBackingTrack(enabledChannels, r.stopChan); err != nil {
+		slog.Error("Failed to play backing track", "error", err)
+	}
+	if err := r.startMetronomeTrack(r.stopChan); err != nil {
+		slog.Error("Failed to play metronome", "error", err)
+	}
 
 	return nil
 }
 
+// arm starts the capture of a READY session whose trigger is not "sources":
+// sources are linked and metered, and every channel keeps its pre-roll until
+// the trigger fires
+func (r *PipeWireRecorder) arm() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusReady || r.session == nil {
+		return fmt.Errorf("can only arm from ready state, current: %s", r.status)
+	}
+
+	trigger := r.cfg.GetTrigger()
+	enabledChannels := r.sessionChannels()
+	r.channels.scan(enabledChannels, r.currentSnapshot())
+
+	r.taps = make(map[string]*triggerTap, len(enabledChannels))
+	for _, channel := range enabledChannels {
+		r.taps[channel.Name] = newTriggerTap(channel.Name, trigger.PreRoll, channel.ChannelCount(), r.sampleRate())
+	}
+
+	if err := r.startCapture(enabledChannels, ""); err != nil {
+		r.taps = nil
+		r.status = StatusError
+		return fmt.Errorf("failed to start FFmpeg: %w", err)
+	}
+
+	r.armedAt = time.Now()
+	r.session.Armed = true
+
+	slog.Info("PipeWire session armed", "song", r.session.SongName, "trigger", trigger.Mode, "pre_roll", trigger.PreRoll)
+
+	r.stopChan = make(chan struct{})
+	go r.recordingWorker(enabledChannels, r.stopChan)
+
+	switch trigger.Mode {
+	case config.TriggerSignal:
+		go r.watchSignal(trigger, r.meter, r.stopChan)
+	case config.TriggerSources:
+		// Only armed for auto-split: the take starts right away
+		return r.fireTrigger()
+	}
+
+	return nil
+}
+
+// watchSignal starts recording once the trigger channel reaches the threshold
+func (r *PipeWireRecorder) watchSignal(trigger config.TriggerConfig, meter *LevelMeter, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second / levelRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-ticker.C:
+			if !signalDetected(meter.Levels(), trigger) {
+				continue
+			}
+			slog.Info("PipeWire trigger signal detected - starting recording", "channel", trigger.Channel, "threshold", trigger.Threshold)
+			if err := r.StartRecording(); err != nil {
+				slog.Error("Failed to start PipeWire recording on signal", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// fireTrigger starts the take of an armed session: the encoder receives the
+// pre-roll of every channel followed by the live audio. Must hold r.mutex.
+func (r *PipeWireRecorder) fireTrigger() error {
+	os.Remove(r.session.OutputFile)
+
+	enabledChannels := r.sessionChannels()
+	inputs, err := r.startEncoder(enabledChannels, r.session.OutputFile)
+	if err != nil {
+		r.status = StatusError
+		return fmt.Errorf("failed to start encoder: %w", err)
+	}
+
+	for i, channel := range enabledChannels {
+		if err := r.taps[channel.Name].fire(inputs[i]); err != nil {
+			slog.Error("Failed to write pre-roll", "channel", channel.Name, "error", err)
+		}
+	}
+
+	// The take starts with whatever pre-roll was buffered since arming
+	now := time.Now()
+	preRoll := math.Min(r.cfg.GetTrigger().PreRoll, now.Sub(r.armedAt).Seconds())
+
+	r.isRecording = true
+	r.recordingStarted = now.Add(-time.Duration(preRoll * float64(time.Second)))
+	r.session.Armed = false
+	r.session.PreRoll = preRoll
+	r.session.startTake(r.session.OutputFile, r.recordingStarted)
+	r.status = StatusRecording
+
+	if policy := r.cfg.GetAutoSplit(); policy != nil {
+		go r.watchSilence(*policy, r.stopChan)
+	}
+
+	if err := r.startBackingTrack(enabledChannels, r.stopChan); err != nil {
+		slog.Error("Failed to play backing track", "error", err)
+	}
+	if err := r.startMetronomeTrack(r.stopChan); err != nil {
+		slog.Error("Failed to play metronome", "error", err)
+	}
+
+	slog.Info("PipeWire recording triggered", "song", r.session.SongName, "pre_roll", preRoll)
+	return nil
+}
+
+// watchSilence applies the auto-split policy until the recording stops
+func (r *PipeWireRecorder) watchSilence(policy config.AutoSplitConfig, stop <-chan struct{}) {
+	detector := newSilenceDetector(policy, r.sessionChannels(), time.Now())
+	ticker := time.NewTicker(time.Second / levelRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case now := <-ticker.C:
+			switch detector.update(r.GetLevels(), now) {
+			case silenceSplit:
+				if err := r.splitTake(); err != nil {
+					slog.Error("Failed to split PipeWire take", "error", err)
+					continue
+				}
+				detector.newTake()
+
+			case silenceStop:
+				slog.Info("PipeWire inputs silent - stopping recording", "minutes", policy.StopAfter)
+				if err := r.Stop(); err != nil {
+					slog.Error("Failed to stop PipeWire recording on silence", "error", err)
+					return
+				}
+				if !detector.heard {
+					r.removeSilentTake()
+				}
+				return
+			}
+		}
+	}
+}
+
+// splitTake closes the current take and continues in the next take file.
+// The new encoder is started first and every channel switches to it at the
+// same frame, so no audio is lost between takes.
+func (r *PipeWireRecorder) splitTake() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status != StatusRecording || r.taps == nil {
+		return fmt.Errorf("no recording in progress")
+	}
+
+	number := len(r.session.Takes) + 1
+	file := takeFile(r.session.Takes[0].File, number)
+	os.Remove(file)
+
+	previous, previousFile := r.encoderCmd, r.session.OutputFile
+	inputs, err := r.startEncoder(r.sessionChannels(), file)
+	if err != nil {
+		r.encoderCmd = previous
+		return fmt.Errorf("failed to start encoder for take %d: %w", number, err)
+	}
+
+	var at int64
+	for _, tap := range r.taps {
+		at = max(at, tap.position())
+	}
+	for i, channel := range r.sessionChannels() {
+		r.taps[channel.Name].split(inputs[i], at)
+	}
+
+	// The previous encoder finishes its file once every channel has switched
+	if previous != nil {
+		r.closingTakes.Add(1)
+		go func() {
+			defer r.closingTakes.Done()
+			if err := waitEncoder(previous); err != nil {
+				slog.Error("Failed to finish take", "error", err)
+			}
+			if err := r.finishOutput(previousFile); err != nil {
+				slog.Error("Failed to finish take", "file", previousFile, "error", err)
+			}
+		}()
+	}
+
+	now := time.Now()
+	r.session.endTake(now)
+	r.session.startTake(file, now)
+	r.recordingStarted = now
+
+	slog.Info("PipeWire take split on silence", "song", r.session.SongName, "take", number, "file", file)
+	return nil
+}
+
+// removeSilentTake deletes a take holding only the silence before an automatic stop
+func (r *PipeWireRecorder) removeSilentTake() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.session == nil {
+		return
+	}
+	if file, ok := r.session.dropLastTake(); ok {
+		os.Remove(file)
+		slog.Info("Removed silent take", "file", file)
+	}
+}
+
+// disarm stops the capture of an armed session and closes the encoder
+// inputs once every tap has drained. Must hold r.mutex.
+func (r *PipeWireRecorder) disarm() error {
+	if r.stopChan != nil {
+		close(r.stopChan)
+		r.stopChan = nil
+	}
+
+	err := r.stopFFmpeg()
+	r.tapsDone.Wait()
+	for _, tap := range r.taps {
+		tap.close()
+	}
+	r.taps = nil
+
+	return err
+}
+
 // recordingWorker handles the recording process in background
-func (r *PipeWireRecorder) recordingWorker(enabledChannels []config.Channel) {
+func (r *PipeWireRecorder) recordingWorker(enabledChannels []config.Channel, stop <-chan struct{}) {
 	// Wait for FFmpeg JACK ports to appear (1 second)
 	time.Sleep(1 * time.Second)
 
+	var links []*sourceLink
+
 	// Connect all channel sources to their corresponding FFmpeg inputs
 	for _, channel := range enabledChannels {
-		// Determine if this is mono or stereo based on sources
-		if len(channel.Sources) <= 1 {
-			// Mono channel - connect to input_1
-			destPort := fmt.Sprintf("jamcapture_%s:input_1", channel.Name)
+		// Source i feeds input_i+1 of the channel's client, in layout order
+		for i, source := range channel.Sources {
+			if source == "" || source == "disabled" {
+				continue
+			}
+
+			destPort := fmt.Sprintf("%s:input_%d", r.captureClient(channel.Name), i+1)
 
 			// Wait for FFmpeg port to be available
 			if err := r.waitForSpecificPort(destPort, 5*time.Second); err != nil {
 				slog.Error("FFmpeg JACK port did not appear", "port", destPort, "error", err)
+				r.channels.set(channel.Name, ChannelDropped, nil, err.Error())
 				continue
 			}
 
-			// Connect the source to the input
-			if len(channel.Sources) > 0 {
-				source := channel.Sources[0]
-				if source != "" && source != "disabled" {
-					if err := r.pipewire.ConnectPortsWithRetry(source, destPort); err != nil {
-						slog.Error("Failed to connect mono source", "channel", channel.Name, "source", source, "dest", destPort, "error", err)
-					} else {
-						slog.Info("Connected mono source successfully", "channel", channel.Name, "source", source, "dest", destPort)
-					}
-				}
+			link := &sourceLink{channel: channel.Name, source: source, ports: []string{source}, dest: destPort, rule: r.cfg.GetDuplicateRule(channel)}
+			if ports, err := r.pipewire.ConnectSourceWithRetry(source, destPort, link.rule); err != nil {
+				slog.Error("Failed to connect source", "channel", channel.Name, "source", source, "dest", destPort, "error", err)
+				r.recordConnectionEvent(ConnectionDropped, link)
+			} else {
+				slog.Info("Connected source successfully", "channel", channel.Name, "source", source, "dest", destPort)
+				link.ports, link.linked = ports, true
 			}
-		} else {
-			// Stereo channel - connect to input_1 and input_2
-			for i, source := range channel.Sources {
-				if i >= 2 { // Only handle first 2 sources for stereo
-					break
-				}
-				if source == "" || source == "disabled" {
-					continue
-				}
+			links = append(links, link)
+		}
+	}
+
+	// Keep sources linked until recording is stopped
+	r.superviseLinks(links, stop)
+}
 
-				destPort := fmt.Sprintf("jamcapture_%s:input_%d", channel.Name, i+1)
+// sourceLink is a link from a channel source to its FFmpeg input that is
+// kept alive for the whole recording
+type sourceLink struct {
+	channel string
+	source  string
+	ports   []string // Ports actually linked: the source, or the instances picked among duplicates
+	dest    string
+	rule    config.DuplicateRule
+	linked  bool
+}
 
-				// Wait for FFmpeg port to be available
-				if err := r.waitForSpecificPort(destPort, 5*time.Second); err != nil {
-					slog.Error("FFmpeg JACK port did not appear", "port", destPort, "error", err)
-					continue
-				}
+// superviseLinks watches the linked sources until stop is closed. PipeWire
+// drops a link when its source port disappears (a reloaded browser tab, a USB
+// glitch), so a missing source marks a dropout and its return is re-linked.
+func (r *PipeWireRecorder) superviseLinks(links []*sourceLink, stop <-chan struct{}) {
+	var events <-chan PortEvent
+	unsubscribe := func() {}
+	pollInterval := sourcePollInterval
+	if source, ok := r.pipewire.graph.(PortEventSource); ok {
+		events, unsubscribe = source.Subscribe()
+		pollInterval = sourceFallbackInterval
+	}
+	defer unsubscribe()
 
-				if err := r.pipewire.ConnectPortsWithRetry(source, destPort); err != nil {
-					slog.Error("Failed to connect stereo source", "channel", channel.Name, "source", source, "dest", destPort, "error", err)
-				} else {
-					slog.Info("Connected stereo source successfully", "channel", channel.Name, "source", source, "dest", destPort)
-				}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// Catch anything that changed while the initial links were made
+	r.checkLinks(links, stop)
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			slog.Debug("PipeWire port event during recording", "type", event.Type, "port", event.Port)
+			r.checkLinks(links, stop)
+
+		case <-ticker.C:
+			r.checkLinks(links, stop)
+		}
+	}
+}
+
+// checkLinks compares the supervised links with a port snapshot, recording
+// dropouts and re-linking sources that came back
+func (r *PipeWireRecorder) checkLinks(links []*sourceLink, stop <-chan struct{}) {
+	snapshot := r.refreshSnapshot()
+
+	for _, link := range links {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		present := r.pipewire.instanceExists(snapshot, link.source, link.ports)
+		switch {
+		case link.linked && !present:
+			link.linked = false
+			slog.Warn("PipeWire source dropped during recording", "channel", link.channel, "source", link.source)
+			r.recordConnectionEvent(ConnectionDropped, link)
+
+		case !link.linked && snapshot.Exists(link.source):
+			ports, err := r.pipewire.ConnectSourceWithRetry(link.source, link.dest, link.rule)
+			if err != nil {
+				slog.Error("Failed to reconnect source", "channel", link.channel, "source", link.source, "dest", link.dest, "error", err)
+				continue
 			}
+			link.ports, link.linked = ports, true
+			slog.Info("PipeWire source reconnected during recording", "channel", link.channel, "source", link.source, "dest", link.dest)
+			r.recordConnectionEvent(ConnectionReconnected, link)
+		}
+	}
+
+	r.updateChannelStates(links)
+}
+
+// updateChannelStates derives the state of each linked channel from its supervised links
+func (r *PipeWireRecorder) updateChannelStates(links []*sourceLink) {
+	var order []string
+	linkedPorts := make(map[string][]string)
+	allLinked := make(map[string]bool)
+
+	for _, link := range links {
+		if _, seen := allLinked[link.channel]; !seen {
+			order = append(order, link.channel)
+			allLinked[link.channel] = true
+		}
+		if link.linked {
+			linkedPorts[link.channel] = append(linkedPorts[link.channel], link.source)
+		} else {
+			allLinked[link.channel] = false
 		}
 	}
 
-	// Wait for recording to be stopped
-	<-r.stopChan
+	for _, name := range order {
+		r.channels.link(name, linkedPorts[name], allLinked[name])
+	}
+}
+
+// recordConnectionEvent appends a dropout or reconnect to the session
+func (r *PipeWireRecorder) recordConnectionEvent(eventType ConnectionEventType, link *sourceLink) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Dropouts of an armed session before its trigger are not part of the take
+	if r.session == nil || r.status != StatusRecording {
+		return
+	}
+
+	now := time.Now()
+	r.session.ConnectionEvents = append(r.session.ConnectionEvents, ConnectionEvent{
+		Time:    now,
+		Offset:  now.Sub(r.recordingStarted).Seconds(),
+		Take:    len(r.session.Takes),
+		Type:    eventType,
+		Channel: link.channel,
+		Source:  link.source,
+	})
 }
 
 // Stop ends the current recording session
 func (r *PipeWireRecorder) Stop() error {
+	// Stop source monitoring
+	r.stopSourceMonitoring()
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -221,18 +681,44 @@ func (r *PipeWireRecorder) Stop() error {
 
 	slog.Debug("Stopping PipeWire recording...")
 
-	// Stop source monitoring
-	r.stopSourceMonitoring()
-
 	r.isRecording = false
-	if r.stopChan != nil {
-		close(r.stopChan)
+	r.stopBackingTrack()
+	r.stopMetronome()
+
+	if r.taps != nil {
+		// Ending the capture drains the taps, then the encoder finishes the file
+		captureErr := r.disarm()
+		encoderErr := r.stopEncoder()
+		r.closingTakes.Wait()
+		if captureErr != nil {
+			r.status = StatusError
+			return fmt.Errorf("failed to stop FFmpeg: %w", captureErr)
+		}
+		if encoderErr != nil {
+			r.status = StatusError
+			return fmt.Errorf("failed to stop encoder: %w", encoderErr)
+		}
+	} else {
+		if r.stopChan != nil {
+			close(r.stopChan)
+		}
+
+		// Stop FFmpeg process
+		if err := r.stopFFmpeg(); err != nil {
+			r.status = StatusError
+			return fmt.Errorf("failed to stop FFmpeg: %w", err)
+		}
 	}
 
-	// Stop FFmpeg process
-	if err := r.stopFFmpeg(); err != nil {
+	if r.overdub != nil {
+		return r.finishOverdub()
+	}
+
+	r.session.endTake(time.Now())
+
+	if err := r.finishOutput(r.session.OutputFile); err != nil {
 		r.status = StatusError
-		return fmt.Errorf("failed to stop FFmpeg: %w", err)
+		return fmt.Errorf("failed to finish recording: %w", err)
 	}
 
 	// Validate output file
@@ -250,16 +736,23 @@ func (r *PipeWireRecorder) Stop() error {
 // CancelReady cancels ready state and returns to STANDBY
 func (r *PipeWireRecorder) CancelReady() error {
 	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
 	if r.status != StatusReady {
+		r.mutex.Unlock()
 		return fmt.Errorf("can only cancel from ready state, current: %s", r.status)
 	}
 
-	r.stopSourceMonitoring()
+	if r.taps != nil {
+		if err := r.disarm(); err != nil {
+			slog.Debug("Failed to stop armed capture", "error", err)
+		}
+	}
+	r.stopMetronome()
 
 	r.status = StatusStandby
 	r.session = nil
+	r.mutex.Unlock()
+
+	r.stopSourceMonitoring()
 	slog.Debug("PipeWire ready state cancelled, returned to standby")
 
 	return nil
@@ -272,90 +765,75 @@ func (r *PipeWireRecorder) GetStatus() (Status, *SessionInfo) {
 
 	var sessionCopy *SessionInfo
 	if r.session != nil {
-		sessionCopy = &SessionInfo{
-			SongName:     r.session.SongName,
-			StartTime:    r.session.StartTime,
-			OutputFile:   r.session.OutputFile,
-			ChannelCount: r.session.ChannelCount,
-			ChannelNames: make([]string, len(r.session.ChannelNames)),
-		}
-		copy(sessionCopy.ChannelNames, r.session.ChannelNames)
+		sessionCopy = r.session.clone()
 	}
 
 	return r.status, sessionCopy
 }
 
-// GetChannelStatus returns the availability status of configured channels
-func (r *PipeWireRecorder) GetChannelStatus() map[string]string {
+// GetChannelStatus returns the connection state of configured channels.
+// While recording or armed it reports the supervised links, otherwise it scans the port graph.
+func (r *PipeWireRecorder) GetChannelStatus() map[string]ChannelStatus {
 	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	status, armed := r.status, r.taps != nil
+	r.mutex.RUnlock()
 
-	// If recording, return cached status
-	if r.status == StatusRecording {
-		if r.channelStatusCache != nil {
-			return r.channelStatusCache
-		}
+	if status == StatusRecording || armed {
+		return r.channels.all()
 	}
 
-	return r.scanChannelStatus()
+	snapshot := r.currentSnapshot()
+	r.sources.resolve(r.cfg.Channels, snapshot, r.pipewire.graph)
+	return r.channels.scan(r.sessionChannels(), snapshot)
 }
 
-// scanChannelStatus scans and caches channel status
-func (r *PipeWireRecorder) scanChannelStatus() map[string]string {
-	status := make(map[string]string)
-
-	for _, channel := range r.cfg.Channels {
-		channelAvailable := true
-		channelHasDuplicates := false
-
-		for _, source := range channel.Sources {
-			if source != "" && source != "disabled" {
-				if err := r.pipewire.ValidatePort(source); err != nil {
-					channelAvailable = false
-					// Check if error is due to duplicates
-					if strings.Contains(err.Error(), "duplicate sources detected") {
-						channelHasDuplicates = true
-						slog.Error("Channel source has duplicates", "channel", channel.Name, "source", source, "error", err)
-					} else {
-						slog.Debug("Channel source unavailable", "channel", channel.Name, "source", source, "error", err)
-					}
-					break
-				}
-			}
-		}
-
-		if channelHasDuplicates {
-			status[channel.Name] = "duplicate"
-		} else if channelAvailable {
-			status[channel.Name] = "available"
-		} else {
-			status[channel.Name] = "unavailable"
-		}
+// sessionChannels returns the configured channels with their source patterns
+// replaced by the ports they resolved to
+func (r *PipeWireRecorder) sessionChannels() []config.Channel {
+	channels := r.sources.apply(r.cfg.Channels)
+	if channel, recorded := r.metronomeChannel(); recorded {
+		channels = append(channels, channel)
 	}
-
-	r.channelStatusCache = status
-	r.channelStatusCacheTime = time.Now()
-
-	return status
+	return channels
 }
 
 // Cleanup cleans up PipeWire resources
 func (r *PipeWireRecorder) Cleanup() error {
+	r.stopSourceMonitoring()
+
+	if closer, ok := r.pipewire.graph.(io.Closer); ok {
+		closer.Close()
+	}
+
 	if r.ffmpegCmd != nil && r.ffmpegCmd.Process != nil {
 		r.ffmpegCmd.Process.Kill()
 		r.ffmpegCmd.Wait()
 	}
 
+	if r.encoderCmd != nil && r.encoderCmd.Process != nil {
+		r.encoderCmd.Process.Kill()
+		r.encoderCmd.Wait()
+	}
+
+	if r.overdub != nil {
+		r.overdub.stopPlayback()
+		os.Remove(r.overdub.playbackFile)
+	}
+
+	r.stopBackingTrack()
+	r.stopMetronome()
+	if r.backing.rendered != "" {
+		os.Remove(r.backing.rendered)
+	}
+
 	slog.Debug("PipeWire recorder cleaned up")
 	return nil
 }
 
 // buildAndStartFFmpeg constructs and starts the FFmpeg command for PipeWire recording
 func (r *PipeWireRecorder) buildAndStartFFmpeg(channels []config.Channel, outputFile string) error {
-	// Set PipeWire environment variables
-	env := os.Environ()
-	env = append(env, "PIPEWIRE_QUANTUM=256/48000")
-	env = append(env, "PIPEWIRE_LATENCY=256/48000")
+	// Run the JACK clients at the configured rate and buffer size
+	env := append(os.Environ(), r.pipewireEnv()...)
 
 	// Build FFmpeg command - create individual JACK clients per channel like main branch
 	args := []string{
@@ -365,43 +843,72 @@ func (r *PipeWireRecorder) buildAndStartFFmpeg(channels []config.Channel, output
 
 	// Add each channel as a separate JACK input
 	for _, channel := range channels {
-		// Determine number of channels for this input (mono=1, stereo=2)
-		channelCount := len(channel.Sources)
-		if channelCount == 0 {
-			channelCount = 1 // Default to mono
-		}
-		if channelCount > 2 {
-			channelCount = 2 // Cap at stereo
-		}
-
 		args = append(args,
 			"-f", "jack",
-			"-channels", fmt.Sprintf("%d", channelCount),
-			"-i", fmt.Sprintf("jamcapture_%s", channel.Name),
+			"-channels", fmt.Sprintf("%d", channel.ChannelCount()),
+			"-i", r.captureClient(channel.Name),
 		)
 	}
 
-	// Add sample rate
-	args = append(args, "-ar", fmt.Sprintf("%d", r.cfg.Audio.SampleRate))
+	// An armed session has no file yet: its taps feed the encoder once triggered
+	if outputFile != "" {
+		// Add sample rate
+		args = append(args, "-ar", fmt.Sprintf("%d", r.sampleRate()))
 
-	// Map each input to a separate track with metadata
-	for i, channel := range channels {
-		args = append(args, "-map", fmt.Sprintf("%d:0", i))
-		args = append(args, fmt.Sprintf("-metadata:s:a:%d", i), fmt.Sprintf("title=%s", channel.Name))
+		// Map each input to a separate track with metadata
+		for i, channel := range channels {
+			args = append(args, "-map", fmt.Sprintf("%d:0", i))
+			args = append(args, fmt.Sprintf("-metadata:s:a:%d", i), fmt.Sprintf("title=%s", channel.Name))
+		}
+		args = append(args, layoutArgs(channels)...)
+
+		// Add codec and output
+		output, err := r.outputArgs(outputFile)
+		if err != nil {
+			return err
+		}
+		args = append(args, r.cfg.GetCapture().EncoderArgs()...)
+		args = append(args, output...)
 	}
 
-	// Add codec and output
-	args = append(args,
-		"-c:a", r.cfg.Output.Format,
-		"-y", // Overwrite output
-		outputFile,
-	)
+	// Tap each input as raw float samples on its own pipe for level metering.
+	// ExtraFiles[i] becomes file descriptor 3+i in FFmpeg.
+	sampleRate := r.sampleRate()
+	var meterReaders, meterWriters []*os.File
+	started := false
+	defer func() {
+		// Until FFmpeg runs the pipes have no other owner
+		if !started {
+			for _, f := range append(meterReaders, meterWriters...) {
+				f.Close()
+			}
+		}
+	}()
+	channelNames := make([]string, len(channels))
+	for i, channel := range channels {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return fmt.Errorf("failed to create level meter pipe: %w", err)
+		}
+		meterReaders = append(meterReaders, reader)
+		meterWriters = append(meterWriters, writer)
+		channelNames[i] = channel.Name
+
+		args = append(args,
+			"-map", fmt.Sprintf("%d:0", i),
+			"-ar", fmt.Sprintf("%d", sampleRate),
+			"-c:a", "pcm_f32le",
+			"-f", "f32le",
+			fmt.Sprintf("pipe:%d", 3+i),
+		)
+	}
 
 	slog.Info("Starting PipeWire FFmpeg", "command", strings.Join(args, " "))
 
 	// Create command
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Env = env
+	cmd.ExtraFiles = meterWriters
 
 	// Capture output for debugging
 	stdout, err := cmd.StdoutPipe()
@@ -419,8 +926,29 @@ func (r *PipeWireRecorder) buildAndStartFFmpeg(channels []config.Channel, output
 		return fmt.Errorf("failed to start FFmpeg: %w", err)
 	}
 
+	started = true
 	r.ffmpegCmd = cmd
 
+	// FFmpeg holds the write ends now; readers see EOF once it exits.
+	// Channels of an armed session also copy their samples to their trigger tap.
+	meter := NewLevelMeter(channelNames)
+	for i, channel := range channels {
+		meterWriters[i].Close()
+
+		var input io.Reader = meterReaders[i]
+		if tap, ok := r.taps[channel.Name]; ok {
+			input = io.TeeReader(meterReaders[i], tap)
+		}
+
+		r.tapsDone.Add(1)
+		go func(name string, channelCount int, reader *os.File, input io.Reader) {
+			defer r.tapsDone.Done()
+			defer reader.Close()
+			meter.Consume(name, channelCount, sampleRate, input)
+		}(channel.Name, channel.ChannelCount(), meterReaders[i], input)
+	}
+	r.meter = meter
+
 	// Start output readers
 	go r.readOutput(stdout, &r.stdoutBuf, "stdout")
 	go r.readOutput(stderr, &r.stderrBuf, "stderr")
@@ -428,6 +956,206 @@ func (r *PipeWireRecorder) buildAndStartFFmpeg(channels []config.Channel, output
 	return nil
 }
 
+// buildAndStartEncoder starts the FFmpeg process writing the take of an armed
+// session from raw float samples, with the same track layout as a direct capture
+func (r *PipeWireRecorder) buildAndStartEncoder(channels []config.Channel, outputFile string) ([]io.WriteCloser, error) {
+	sampleRate := r.sampleRate()
+	args := []string{"ffmpeg", "-hide_banner"}
+
+	// ExtraFiles[i] becomes file descriptor 3+i in FFmpeg
+	var readers []*os.File
+	var writers []io.WriteCloser
+	closePipes := func() {
+		for _, f := range readers {
+			f.Close()
+		}
+		for _, w := range writers {
+			w.Close()
+		}
+	}
+	for i, channel := range channels {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			closePipes()
+			return nil, fmt.Errorf("failed to create encoder pipe: %w", err)
+		}
+		readers = append(readers, reader)
+		writers = append(writers, writer)
+
+		args = append(args,
+			"-f", "f32le",
+			"-ar", fmt.Sprintf("%d", sampleRate),
+			"-ac", fmt.Sprintf("%d", channel.ChannelCount()),
+			"-i", fmt.Sprintf("pipe:%d", 3+i),
+		)
+	}
+
+	for i, channel := range channels {
+		args = append(args, "-map", fmt.Sprintf("%d:0", i))
+		args = append(args, fmt.Sprintf("-metadata:s:a:%d", i), fmt.Sprintf("title=%s", channel.Name))
+	}
+	args = append(args, layoutArgs(channels)...)
+	output, err := r.outputArgs(outputFile)
+	if err != nil {
+		closePipes()
+		return nil, err
+	}
+	args = append(args, r.cfg.GetCapture().EncoderArgs()...)
+	args = append(args, output...)
+
+	slog.Info("Starting PipeWire encoder", "command", strings.Join(args, " "))
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.ExtraFiles = readers
+	cmd.Stdout = r.logWriter
+	cmd.Stderr = r.logWriter
+
+	if err := cmd.Start(); err != nil {
+		closePipes()
+		return nil, fmt.Errorf("failed to start FFmpeg: %w", err)
+	}
+
+	// The encoder holds the read ends now
+	for _, reader := range readers {
+		reader.Close()
+	}
+	r.encoderCmd = cmd
+
+	return writers, nil
+}
+
+// stopEncoder waits for the current encoder to finish the file once its inputs are closed
+func (r *PipeWireRecorder) stopEncoder() error {
+	if r.encoderCmd == nil {
+		return nil
+	}
+
+	err := waitEncoder(r.encoderCmd)
+	r.encoderCmd = nil
+	return err
+}
+
+// waitEncoder waits for an encoder whose inputs are closed to exit
+func waitEncoder(cmd *exec.Cmd) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("encoder process failed: %w", err)
+		}
+		slog.Debug("Encoder exited successfully")
+		return nil
+
+	case <-time.After(10 * time.Second):
+		slog.Warn("Encoder did not finish within timeout, force killing")
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+		<-done
+		return nil
+	}
+}
+
+// outputArgs returns the FFmpeg arguments writing a take: the file itself,
+// or its segments when segmented capture is enabled
+func (r *PipeWireRecorder) outputArgs(outputFile string) ([]string, error) {
+	segments := r.cfg.GetSegments()
+	if segments == nil {
+		return []string{"-y", outputFile}, nil
+	}
+	return prepareSegments(outputFile, segments.Duration)
+}
+
+// finishOutput joins the segments of a take once its writer has exited
+func (r *PipeWireRecorder) finishOutput(outputFile string) error {
+	if r.cfg.GetSegments() == nil {
+		return nil
+	}
+	return r.joinSegments(outputFile)
+}
+
+// GetLevels returns the latest level of every channel while recording or armed
+func (r *PipeWireRecorder) GetLevels() []ChannelLevel {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.status != StatusRecording && r.taps == nil {
+		return nil
+	}
+	return r.meter.Levels()
+}
+
+// capturesThroughTaps reports whether takes are written by an encoder fed
+// from the trigger taps rather than by the capture process itself: the
+// signal and manual triggers need the pre-roll, and auto-split must switch
+// files without a gap
+func (r *PipeWireRecorder) capturesThroughTaps() bool {
+	return r.cfg.GetTrigger().Mode != config.TriggerSources || r.cfg.GetAutoSplit() != nil
+}
+
+// sampleRate returns the configured capture sample rate
+func (r *PipeWireRecorder) sampleRate() int {
+	return r.cfg.GetSampleRate()
+}
+
+// pipewireEnv returns the environment asking PipeWire to run the capture
+// clients at the configured quantum and sample rate, so that the graph, the
+// capture and the file agree
+func (r *PipeWireRecorder) pipewireEnv() []string {
+	setting := fmt.Sprintf("%d/%d", r.cfg.GetQuantum(), r.sampleRate())
+	return []string{
+		"PIPEWIRE_QUANTUM=" + setting,
+		"PIPEWIRE_LATENCY=" + setting,
+	}
+}
+
+// checkClock compares the clock of the PipeWire graph with the configured
+// sample rate. A graph that cannot run at that rate is reported, or refuses
+// the session with clock_check: refuse.
+func (r *PipeWireRecorder) checkClock() error {
+	policy := r.cfg.GetClockCheck()
+	if policy == config.ClockCheckOff {
+		return nil
+	}
+
+	reader, ok := r.pipewire.graph.(ClockReader)
+	if !ok {
+		return nil
+	}
+	clock, err := reader.Clock()
+	if err != nil || clock.Rate == 0 {
+		slog.Debug("PipeWire clock rate unknown", "error", err)
+		return nil
+	}
+	if clock.Supports(r.sampleRate()) {
+		return nil
+	}
+
+	err = fmt.Errorf("PipeWire graph runs at %d Hz but sample_rate is %d Hz: set clock.allowed-rates or clock.force-rate, or change sample_rate", clock.Rate, r.sampleRate())
+	if policy == config.ClockCheckRefuse {
+		return err
+	}
+	slog.Warn("Sample rate mismatch - audio will be resampled", "graph_rate", clock.Rate, "sample_rate", r.sampleRate(), "allowed_rates", clock.AllowedRates)
+	return nil
+}
+
+// layoutArgs names the channels of each track with a layout beyond stereo.
+// FFmpeg only knows the channel count of a capture and would otherwise store
+// its default layout for that count, e.g. 4.0 rather than quad.
+func layoutArgs(channels []config.Channel) []string {
+	var args []string
+	for i, channel := range channels {
+		if layout := channel.ChannelLayout(); layout != "" && channel.ChannelCount() > 2 {
+			args = append(args, fmt.Sprintf("-filter:a:%d", i), "channelmap=channel_layout="+layout)
+		}
+	}
+	return args
+}
+
 // readOutput reads from a pipe and buffers output
 func (r *PipeWireRecorder) readOutput(pipe io.ReadCloser, buffer *strings.Builder, label string) {
 	scanner := bufio.NewScanner(pipe)
@@ -519,108 +1247,160 @@ func (r *PipeWireRecorder) validateOutputFile() error {
 	return nil
 }
 
-// startSourceMonitoring monitors source availability and auto-transitions to RECORDING
+// startSourceMonitoring monitors source availability and auto-transitions to RECORDING.
+// When the port graph pushes events, sources are checked as soon as a port
+// appears or disappears and the ticker only acts as a safety net.
 func (r *PipeWireRecorder) startSourceMonitoring() {
-	r.sourceMonitorStop = make(chan struct{})
-	r.sourceMonitorDone = make(chan struct{})
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	r.sourceMonitorStop = stop
+	r.sourceMonitorDone = done
+
+	ready := r.cfg.GetReady()
+	var events <-chan PortEvent
+	unsubscribe := func() {}
+	pollInterval := ready.PollDuration()
+	if source, ok := r.pipewire.graph.(PortEventSource); ok {
+		events, unsubscribe = source.Subscribe()
+		pollInterval = sourceFallbackInterval
+	}
 
 	go func() {
-		defer close(r.sourceMonitorDone)
+		defer close(done)
+		defer unsubscribe()
 
-		r.mutex.RLock()
-		currentStatus := r.status
-		r.mutex.RUnlock()
-		slog.Debug("PipeWire source monitoring started", "current_status", currentStatus)
+		slog.Debug("PipeWire source monitoring started", "event_driven", events != nil, "timeout", ready.Timeout)
 
-		ticker := time.NewTicker(500 * time.Millisecond)
+		ticker := time.NewTicker(pollInterval)
 		defer ticker.Stop()
 
-		timeout := time.After(30 * time.Second)
+		// A nil channel never fires, so "never" waits until cancelled
+		var timeout <-chan time.Time
+		if limit := ready.TimeoutDuration(); limit > 0 {
+			timeout = time.After(limit)
+		}
 
 		for {
 			select {
-			case <-r.sourceMonitorStop:
+			case <-stop:
 				slog.Info("PipeWire source monitoring stopped")
 				return
 
 			case <-timeout:
-				slog.Info("PipeWire source monitoring timeout - returning to STANDBY")
+				statuses := r.channels.scan(r.sessionChannels(), r.refreshSnapshot())
 				r.mutex.Lock()
 				if r.status == StatusReady || r.status == StatusError {
+					r.lastError = newReadyTimeoutError(ready.TimeoutDuration(), r.sessionChannels(), statuses)
+					slog.Warn("PipeWire source monitoring timeout - returning to STANDBY", "error", r.lastError)
 					r.status = StatusStandby
 					r.session = nil
 				}
 				r.mutex.Unlock()
 				return
 
-			case <-ticker.C:
-				r.mutex.Lock()
-				currentStatus := r.status
-				r.mutex.Unlock()
-
-				hasDuplicates := r.hasDuplicateSources()
-
-				// Handle different states
-				if currentStatus == StatusReady {
-					if hasDuplicates {
-						slog.Info("PipeWire duplicate sources detected - returning to STANDBY")
-						r.mutex.Lock()
-						r.status = StatusStandby
-						r.session = nil
-						r.mutex.Unlock()
-						return
-					}
-				} else if currentStatus == StatusError {
-					if hasDuplicates {
-						slog.Debug("PipeWire duplicates still detected while in ERROR state - continuing monitoring")
-					} else {
-						slog.Info("PipeWire duplicate sources resolved - recovering from ERROR to STANDBY")
-						r.mutex.Lock()
-						r.status = StatusStandby
-						r.session = nil
-						r.mutex.Unlock()
-					}
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				slog.Debug("PipeWire port event", "type", event.Type, "port", event.Port)
+				if r.checkSources() {
+					return
 				}
 
-				if r.checkAllSourcesAvailable() {
-					r.mutex.Lock()
-					if r.status == StatusReady {
-						r.mutex.Unlock()
-
-						slog.Info("All PipeWire sources available - starting recording automatically")
-						if err := r.StartRecording(); err != nil {
-							slog.Error("Failed to auto-start PipeWire recording", "error", err)
-							r.mutex.Lock()
-							r.status = StatusError
-							r.mutex.Unlock()
-						}
-						return
-					} else {
-						r.mutex.Unlock()
-					}
+			case <-ticker.C:
+				if r.checkSources() {
+					return
 				}
 			}
 		}
 	}()
 }
 
-// stopSourceMonitoring stops the source monitoring goroutine
-func (r *PipeWireRecorder) stopSourceMonitoring() {
-	if r.sourceMonitorStop != nil {
-		close(r.sourceMonitorStop)
-		if r.sourceMonitorDone != nil {
-			<-r.sourceMonitorDone
+// checkSources runs one monitoring pass and reports whether monitoring is finished.
+// The whole pass answers from a single port snapshot.
+func (r *PipeWireRecorder) checkSources() bool {
+	snapshot := r.refreshSnapshot()
+	r.sources.resolve(r.cfg.Channels, snapshot, r.pipewire.graph)
+	hasDuplicates := r.hasDuplicateSources(snapshot)
+
+	r.mutex.Lock()
+	currentStatus := r.status
+	if currentStatus == StatusReady && hasDuplicates {
+		slog.Info("PipeWire duplicate sources detected - returning to STANDBY")
+		r.lastError = errDuplicatesWhileReady
+		r.status = StatusStandby
+		r.session = nil
+		r.mutex.Unlock()
+		return true
+	}
+	if currentStatus == StatusError {
+		r.mutex.Unlock()
+		if hasDuplicates {
+			slog.Debug("PipeWire duplicates still detected while in ERROR state - continuing monitoring")
+			return false
+		}
+		slog.Info("PipeWire duplicate sources resolved - recovering from ERROR to STANDBY")
+		r.mutex.Lock()
+		if r.status == StatusError {
+			r.status = StatusStandby
+			r.session = nil
+		}
+		r.mutex.Unlock()
+		return true
+	}
+	r.mutex.Unlock()
+
+	if currentStatus != StatusReady {
+		return true
+	}
+
+	if r.checkAllSourcesAvailable(snapshot) {
+		if r.capturesThroughTaps() {
+			slog.Info("All PipeWire sources available - arming recording", "trigger", r.cfg.GetTrigger().Mode)
+			if err := r.arm(); err != nil {
+				slog.Error("Failed to arm PipeWire recording", "error", err)
+				r.mutex.Lock()
+				r.status = StatusError
+				r.mutex.Unlock()
+			}
+			return true
 		}
+
+		slog.Info("All PipeWire sources available - starting recording automatically")
+		if err := r.StartRecording(); err != nil {
+			slog.Error("Failed to auto-start PipeWire recording", "error", err)
+			r.mutex.Lock()
+			r.status = StatusError
+			r.mutex.Unlock()
+		}
+		return true
+	}
+
+	return false
+}
+
+// stopSourceMonitoring stops the source monitoring goroutine.
+// Must be called without holding r.mutex.
+func (r *PipeWireRecorder) stopSourceMonitoring() {
+	r.mutex.Lock()
+	stop, done := r.sourceMonitorStop, r.sourceMonitorDone
+	r.sourceMonitorStop, r.sourceMonitorDone = nil, nil
+	r.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
 	}
 }
 
-// checkAllSourcesAvailable validates all configured sources
-func (r *PipeWireRecorder) checkAllSourcesAvailable() bool {
+// checkAllSourcesAvailable validates all configured sources against a port snapshot
+func (r *PipeWireRecorder) checkAllSourcesAvailable(snapshot *PortSnapshot) bool {
 	validChannels := 0
 	totalChannelsToCheck := 0
 	hasDuplicates := false
 
-	for _, channel := range r.cfg.Channels {
+	for _, channel := range r.sessionChannels() {
 		channelHasAllSources := true
 		channelHasAnySources := false
 
@@ -629,8 +1409,18 @@ func (r *PipeWireRecorder) checkAllSourcesAvailable() bool {
 				channelHasAnySources = true
 				totalChannelsToCheck++
 
-				if err := r.pipewire.ValidatePort(source); err != nil {
-					if strings.Contains(err.Error(), "duplicate sources detected") {
+				// Duplicates are resolved when linking unless the channel's rule
+				// blocks them. A match rule waits for a matching instance.
+				if snapshot.HasDuplicates(source) && !r.blocksDuplicates(channel) {
+					if _, err := r.pipewire.pickInstances(source, r.cfg.GetDuplicateRule(channel)); err != nil {
+						slog.Debug("PipeWire duplicate sources unresolved", "channel", channel.Name, "source", source, "error", err)
+						channelHasAllSources = false
+					}
+					continue
+				}
+
+				if err := snapshot.ValidatePort(source); err != nil {
+					if snapshot.HasDuplicates(source) {
 						hasDuplicates = true
 						slog.Debug("PipeWire duplicate sources detected", "channel", channel.Name, "source", source, "error", err)
 					} else {
@@ -654,7 +1444,7 @@ func (r *PipeWireRecorder) checkAllSourcesAvailable() bool {
 
 	// Count total channels that have sources configured (not disabled)
 	totalChannelsWithSources := 0
-	for _, channel := range r.cfg.Channels {
+	for _, channel := range r.sessionChannels() {
 		hasAnySources := false
 		for _, source := range channel.Sources {
 			if source != "" && source != "disabled" {
@@ -674,28 +1464,62 @@ func (r *PipeWireRecorder) checkAllSourcesAvailable() bool {
 	return result
 }
 
-// hasDuplicateSources checks if any configured sources have duplicates
-func (r *PipeWireRecorder) hasDuplicateSources() bool {
-	for _, channel := range r.cfg.Channels {
+// hasDuplicateSources checks if any configured sources have duplicates in a
+// port snapshot that the channel's duplicate rule does not resolve
+func (r *PipeWireRecorder) hasDuplicateSources(snapshot *PortSnapshot) bool {
+	for _, channel := range r.sessionChannels() {
+		if !r.blocksDuplicates(channel) {
+			continue
+		}
 		for _, source := range channel.Sources {
-			if source != "" && source != "disabled" {
-				if err := r.pipewire.ValidatePort(source); err != nil {
-					if strings.Contains(err.Error(), "duplicate sources detected") {
-						return true
-					}
-				}
+			if source != "" && source != "disabled" && snapshot.HasDuplicates(source) {
+				return true
 			}
 		}
 	}
 	return false
 }
 
+// blocksDuplicates reports whether duplicate sources of a channel prevent recording
+func (r *PipeWireRecorder) blocksDuplicates(channel config.Channel) bool {
+	return r.cfg.GetDuplicateRule(channel).Policy == config.DuplicatesBlock
+}
+
+// GetLastError returns why the recorder last ended a session on its own
+func (r *PipeWireRecorder) GetLastError() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.lastError
+}
+
+// refreshSnapshot takes a new port snapshot for a monitoring pass.
+// A graph that cannot be listed is treated as empty.
+func (r *PipeWireRecorder) refreshSnapshot() *PortSnapshot {
+	snapshot, err := r.pipewire.RefreshSnapshot()
+	if err != nil {
+		slog.Debug("Failed to list PipeWire ports", "error", err)
+		return NewPortSnapshot(nil)
+	}
+	return snapshot
+}
+
+// currentSnapshot returns the shared port snapshot, reusing the last one
+// taken if it is still within the snapshot TTL
+func (r *PipeWireRecorder) currentSnapshot() *PortSnapshot {
+	snapshot, err := r.pipewire.Snapshot()
+	if err != nil {
+		slog.Debug("Failed to list PipeWire ports", "error", err)
+		return NewPortSnapshot(nil)
+	}
+	return snapshot
+}
+
 // waitForSpecificPort waits for a specific JACK port to appear
 func (r *PipeWireRecorder) waitForSpecificPort(portName string, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
 
 	for time.Now().Before(deadline) {
-		if err := r.pipewire.ValidatePort(portName); err == nil {
+		if err := r.refreshSnapshot().ValidatePort(portName); err == nil {
 			slog.Debug("JACK port found", "port", portName)
 			return nil
 		}
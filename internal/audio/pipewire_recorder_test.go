@@ -0,0 +1,614 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// newGraphRecorder creates a PipeWireRecorder on an in-memory graph whose capture
+// stub exposes the <client>_<channel> input ports and writes a placeholder file,
+// like ffmpeg would
+func newGraphRecorder(t *testing.T, graph *MemoryPortGraph) *PipeWireRecorder {
+	cfg := newFakeTestConfig(t)
+	rec := NewPipeWireRecorderWithGraph(cfg, nil, graph)
+	rec.pipewire.hardwareRetry = retryPolicy{maxRetries: 2, delay: time.Millisecond}
+	rec.pipewire.ephemeralRetry = retryPolicy{maxRetries: 2, delay: time.Millisecond}
+
+	rec.startCapture = func(channels []config.Channel, outputFile string) error {
+		var names []string
+		for _, channel := range channels {
+			for i := range channel.Sources {
+				graph.AddPort(fmt.Sprintf("%s:input_%d", rec.captureClient(channel.Name), i+1))
+			}
+			names = append(names, channel.Name)
+		}
+		rec.meter = NewLevelMeter(names)
+
+		// An armed capture has no file until its trigger fires
+		if outputFile == "" {
+			return nil
+		}
+		return os.WriteFile(outputFile, make([]byte, 2048), 0644)
+	}
+	rec.startEncoder = func(channels []config.Channel, outputFile string) ([]io.WriteCloser, error) {
+		inputs := make([]io.WriteCloser, len(channels))
+		for i := range inputs {
+			inputs[i] = &bufferCloser{}
+		}
+		return inputs, os.WriteFile(outputFile, make([]byte, 2048), 0644)
+	}
+
+	return rec
+}
+
+func TestPipeWireRecorder_AutoStartAndLink(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	if err := rec.StartReady("graph song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	if status, _ := rec.GetStatus(); status != StatusReady {
+		t.Fatalf("Expected READY, got %s", status)
+	}
+
+	graph.AddPort("Chrome:output_FL")
+	graph.AddPort("Chrome:output_FR")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+
+	// recordingWorker links every source to its capture input
+	deadline := time.Now().Add(3 * time.Second)
+	for !graph.IsLinked("Chrome:output_FR", "jamcapture_chrome:input_2") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	links := [][2]string{
+		{"system:capture_1", "jamcapture_guitar:input_1"},
+		{"Chrome:output_FL", "jamcapture_chrome:input_1"},
+		{"Chrome:output_FR", "jamcapture_chrome:input_2"},
+	}
+	for _, link := range links {
+		if !graph.IsLinked(link[0], link[1]) {
+			t.Errorf("Expected %s to be linked to %s", link[0], link[1])
+		}
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if status, _ := rec.GetStatus(); status != StatusStandby {
+		t.Errorf("Expected STANDBY after stop, got %s", status)
+	}
+}
+
+func TestPipeWireRecorder_LinksEverySourceOfAGroup(t *testing.T) {
+	graph := NewMemoryPortGraph()
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	rec.cfg.Channels = []config.Channel{
+		{Name: "drums", Sources: []string{"kit:capture_1", "kit:capture_2", "kit:capture_3", "kit:capture_4"}, AudioMode: config.AudioModeGroup, Type: "input", Volume: 1.0},
+	}
+	if err := rec.StartReady("drum song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		graph.AddPort(fmt.Sprintf("kit:capture_%d", i))
+	}
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+
+	waitForLink(t, graph, "kit:capture_4", "jamcapture_drums:input_4")
+	for i := 1; i <= 4; i++ {
+		source, dest := fmt.Sprintf("kit:capture_%d", i), fmt.Sprintf("jamcapture_drums:input_%d", i)
+		if !graph.IsLinked(source, dest) {
+			t.Errorf("Expected %s to be linked to %s", source, dest)
+		}
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+func TestPipeWireRecorder_ClientNameKeepsRecordersApart(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL", "Chrome:output_FR")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	rec.SetClientName("jamcapture-room")
+	if err := rec.StartReady("room song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	graph.AddPort("system:capture_2")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+
+	waitForLink(t, graph, "system:capture_1", "jamcapture-room_guitar:input_1")
+	if graph.IsLinked("system:capture_1", "jamcapture_guitar:input_1") {
+		t.Error("Expected no link to the default recorder's client")
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+func TestPipeWireRecorder_PlaybackChannelLinksThePlayer(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL", "Chrome:output_FR")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	backing := config.Channel{Name: "backing", AudioMode: "stereo", Type: config.ChannelTypePlayback, Volume: 0.8}
+	rec.cfg.Channels = append(rec.cfg.Channels, backing)
+
+	// The playback channel has no source to wait for
+	if err := rec.StartReady("backing song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	graph.AddPort("system:capture_2")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+
+	// The player appears once the take has started
+	graph.AddPort("jamcapture_backing:input_1")
+	graph.AddPort("jamcapture_backing:input_2")
+	graph.AddPort("jamcapture_backingtrack:output_FL")
+	graph.AddPort("jamcapture_backingtrack:output_FR")
+	rec.linkBackingTrack(backing, make(chan struct{}))
+
+	if !graph.IsLinked("jamcapture_backingtrack:output_FL", "jamcapture_backing:input_1") ||
+		!graph.IsLinked("jamcapture_backingtrack:output_FR", "jamcapture_backing:input_2") {
+		t.Error("Expected the player to be linked to the playback channel")
+	}
+	if state := rec.GetChannelStatus()["backing"].State; state != ChannelLinked {
+		t.Errorf("Expected the playback channel to be linked, got %s", state)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+func TestLayoutArgs(t *testing.T) {
+	channels := []config.Channel{
+		{Name: "guitar", Sources: []string{"system:capture_1"}, AudioMode: "mono"},
+		{Name: "drums", Sources: []string{"kit:1", "kit:2", "kit:3"}, AudioMode: config.AudioModeGroup},
+		{Name: "room", Sources: []string{"room:1", "room:2", "room:3", "room:4"}, AudioMode: "quad"},
+	}
+
+	expected := "-filter:a:2 channelmap=channel_layout=quad"
+	if args := strings.Join(layoutArgs(channels), " "); args != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, args)
+	}
+}
+
+func TestPipeWireRecorder_DuplicateWhileReadyReturnsToStandby(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	if err := rec.StartReady("dup while ready"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+
+	// A second Chrome instance appears before all sources are present
+	graph.AddPort("Chrome:output_FL")
+	waitForStatusWithin(t, rec, StatusStandby, 3*time.Second)
+	if err := rec.GetLastError(); err != errDuplicatesWhileReady {
+		t.Errorf("Expected the return to STANDBY to be reported, got %v", err)
+	}
+}
+
+func TestPipeWireRecorder_DuplicateAtReadyRecovers(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL", "Chrome:output_FL", "Chrome:output_FR")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	err := rec.StartReady("dup at ready")
+	if err == nil || !strings.Contains(err.Error(), "duplicate audio sources detected") {
+		t.Fatalf("Expected duplicate sources error, got: %v", err)
+	}
+	if channelStatus := rec.GetChannelStatus(); channelStatus["chrome"].State != ChannelDuplicate {
+		t.Errorf("Expected chrome channel to be 'duplicate', got %v", channelStatus)
+	}
+
+	graph.RemovePort("Chrome:output_FL")
+	waitForStatusWithin(t, rec, StatusStandby, 3*time.Second)
+}
+
+func TestPipeWireRecorder_ReadyTimeoutIsReported(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Ready = &config.ReadyConfig{Timeout: "150ms", PollInterval: "50ms"}
+
+	if err := rec.StartReady("tuning"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	waitForStatusWithin(t, rec, StatusStandby, 3*time.Second)
+
+	var timeoutErr *ReadyTimeoutError
+	if !errors.As(rec.GetLastError(), &timeoutErr) {
+		t.Fatalf("Expected a READY timeout error, got %v", rec.GetLastError())
+	}
+	if len(timeoutErr.Waiting) != 1 || timeoutErr.Waiting[0] != "chrome" {
+		t.Errorf("Expected the timeout to name the chrome channel, got %v", timeoutErr.Waiting)
+	}
+
+	// A new session starts with a clean slate
+	if err := rec.StartReady("tuning again"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	if err := rec.GetLastError(); err != nil {
+		t.Errorf("Expected StartReady to clear the last error, got %v", err)
+	}
+}
+
+func TestPipeWireRecorder_ReadyNeverTimesOut(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Ready = &config.ReadyConfig{Timeout: config.ReadyTimeoutNever}
+
+	if err := rec.StartReady("long tuning"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if status, _ := rec.GetStatus(); status != StatusReady {
+		t.Fatalf("Expected READY to wait without a timeout, got %s", status)
+	}
+
+	graph.AddPort("Chrome:output_FR")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+	rec.Stop()
+}
+
+func TestPipeWireRecorder_DuplicatePolicyLinksNewest(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL", "Chrome:output_FL", "Chrome:output_FR")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Ready = &config.ReadyConfig{Duplicates: config.DuplicatesNewest}
+
+	ids, _ := graph.ListPortIDs("Chrome:output_FL")
+	newest := ids[len(ids)-1]
+
+	if err := rec.StartReady("two tabs"); err != nil {
+		t.Fatalf("Expected duplicates not to block StartReady, got: %v", err)
+	}
+	graph.AddPort("Chrome:output_FR") // Wakes the event-driven monitor
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+	waitForLink(t, graph, "Chrome:output_FL", "jamcapture_chrome:input_1")
+
+	if id := graph.LinkedPortID("Chrome:output_FL", "jamcapture_chrome:input_1"); id != newest {
+		t.Errorf("Expected the newest instance %s to be linked, got %q", newest, id)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+func TestPipeWireRecorder_ChannelMatchesDuplicateByProperty(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FR")
+	graph.AddPortWithProperties("Chrome:output_FL", map[string]string{"media.name": "Meet"})
+	graph.AddPortWithProperties("Chrome:output_FL", map[string]string{"media.name": "Meet"})
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Channels[1].Duplicates = &config.DuplicateRule{
+		Policy: config.DuplicatesMatch,
+		Match:  []config.PropertyMatch{{Property: "media.name", Value: "YouTube"}},
+	}
+
+	if err := rec.StartReady("second tab"); err != nil {
+		t.Fatalf("Expected the channel rule to accept duplicates, got: %v", err)
+	}
+	graph.AddPort("system:capture_2")
+
+	// Both windows are open but none plays YouTube yet
+	time.Sleep(300 * time.Millisecond)
+	if status, _ := rec.GetStatus(); status != StatusReady {
+		t.Fatalf("Expected READY while no instance matches, got %s", status)
+	}
+	youtube := graph.AddPortWithProperties("Chrome:output_FL", map[string]string{"media.name": "YouTube"})
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+	waitForLink(t, graph, "Chrome:output_FL", "jamcapture_chrome:input_1")
+
+	if id := graph.LinkedPortID("Chrome:output_FL", "jamcapture_chrome:input_1"); id != youtube {
+		t.Errorf("Expected the YouTube instance %s to be linked, got %q", youtube, id)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+func TestPipeWireRecorder_ClockCheck(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1")
+	graph.SetClock(GraphClock{Rate: 44100})
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Audio.Quantum = 512
+
+	if env := strings.Join(rec.pipewireEnv(), " "); env != "PIPEWIRE_QUANTUM=512/48000 PIPEWIRE_LATENCY=512/48000" {
+		t.Errorf("Expected the quantum and rate in the environment, got %s", env)
+	}
+
+	rec.cfg.Audio.ClockCheck = config.ClockCheckRefuse
+	if err := rec.StartReady("clock song"); err == nil || !strings.Contains(err.Error(), "44100 Hz") {
+		t.Fatalf("Expected the 44100 Hz graph to be refused, got: %v", err)
+	}
+	if status, _ := rec.GetStatus(); status != StatusStandby {
+		t.Errorf("Expected STANDBY after a refused clock, got %s", status)
+	}
+
+	// A graph allowed to switch to the configured rate is accepted
+	graph.SetClock(GraphClock{Rate: 44100, AllowedRates: []int{44100, 48000}})
+	if err := rec.StartReady("clock song"); err != nil {
+		t.Fatalf("Expected the switchable graph to be accepted, got: %v", err)
+	}
+	rec.CancelReady()
+
+	// The default policy only warns
+	graph.SetClock(GraphClock{Rate: 44100})
+	rec.cfg.Audio.ClockCheck = ""
+	if err := rec.StartReady("clock song"); err != nil {
+		t.Fatalf("Expected a warning only, got: %v", err)
+	}
+	rec.CancelReady()
+}
+
+// waitForStatusWithin polls the recorder until it reaches the expected status
+func waitForStatusWithin(t *testing.T, rec Recorder, expected Status, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if status, _ := rec.GetStatus(); status == expected {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	status, _ := rec.GetStatus()
+	t.Fatalf("Expected status %s, got %s", expected, status)
+}
+
+func TestPipeWireRecorder_ReconnectsDroppedSource(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	if err := rec.StartReady("dropout"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	graph.AddPort("Chrome:output_FR")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+	waitForLink(t, graph, "Chrome:output_FL", "jamcapture_chrome:input_1")
+
+	// The browser tab reloads: its ports and links disappear, then come back
+	graph.RemovePort("Chrome:output_FL")
+	waitForConnectionEvents(t, rec, 1)
+	if state := rec.GetChannelStatus()["chrome"]; state.State != ChannelDropped || len(state.LinkedPorts) != 1 {
+		t.Errorf("Expected chrome dropped with one linked port, got %+v", state)
+	}
+	graph.AddPort("Chrome:output_FL")
+	waitForLink(t, graph, "Chrome:output_FL", "jamcapture_chrome:input_1")
+	waitForConnectionEvents(t, rec, 2)
+
+	channelStatus := rec.GetChannelStatus()
+	if state := channelStatus["chrome"]; state.State != ChannelReconnected || len(state.LinkedPorts) != 2 {
+		t.Errorf("Expected chrome reconnected with two linked ports, got %+v", state)
+	}
+	if state := channelStatus["guitar"]; state.State != ChannelLinked || state.LinkedPorts[0] != "system:capture_1" {
+		t.Errorf("Expected guitar linked to system:capture_1, got %+v", state)
+	}
+
+	_, session := rec.GetStatus()
+	events := session.ConnectionEvents
+	if events[0].Type != ConnectionDropped || events[1].Type != ConnectionReconnected {
+		t.Errorf("Expected dropped then reconnected, got %v", events)
+	}
+	for _, event := range events {
+		if event.Channel != "chrome" || event.Source != "Chrome:output_FL" || event.Time.IsZero() {
+			t.Errorf("Unexpected connection event: %+v", event)
+		}
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
+
+// waitForLink polls the graph until two ports are linked
+func waitForLink(t *testing.T, graph *MemoryPortGraph, source, dest string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !graph.IsLinked(source, dest) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be linked to %s", source, dest)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitForConnectionEvents polls the session until it holds the expected number of connection events
+func waitForConnectionEvents(t *testing.T, rec Recorder, expected int) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, session := rec.GetStatus()
+		if session != nil && len(session.ConnectionEvents) >= expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d connection events, got %v", expected, session)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestChannelTracker_LastChangeOnlyOnTransition(t *testing.T) {
+	var tracker channelTracker
+	tracker.set("guitar", ChannelWaiting, nil, "")
+	first := tracker.all()["guitar"].LastChange
+
+	time.Sleep(2 * time.Millisecond)
+	tracker.set("guitar", ChannelWaiting, nil, "")
+	if changed := tracker.all()["guitar"].LastChange; !changed.Equal(first) {
+		t.Errorf("Expected LastChange to stay at %v, got %v", first, changed)
+	}
+
+	tracker.set("guitar", ChannelFound, nil, "")
+	if changed := tracker.all()["guitar"].LastChange; !changed.After(first) {
+		t.Errorf("Expected LastChange to move after a transition, got %v", changed)
+	}
+
+	tracker.link("guitar", []string{"system:capture_1"}, false)
+	tracker.link("guitar", []string{"system:capture_1"}, true)
+	if state := tracker.state("guitar"); state != ChannelReconnected {
+		t.Errorf("Expected reconnected after a dropout, got %s", state)
+	}
+}
+
+func TestPipeWireRecorder_SignalTriggerKeepsPreRoll(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Trigger = &config.TriggerConfig{Mode: config.TriggerSignal, Channel: "guitar", Threshold: -20, PreRoll: 1}
+
+	var inputs []io.WriteCloser
+	startEncoder := rec.startEncoder
+	rec.startEncoder = func(channels []config.Channel, outputFile string) ([]io.WriteCloser, error) {
+		var err error
+		inputs, err = startEncoder(channels, outputFile)
+		return inputs, err
+	}
+
+	if err := rec.StartReady("count in"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	graph.AddPort("Chrome:output_FR")
+	waitForArmed(t, rec)
+	waitForLink(t, graph, "system:capture_1", "jamcapture_guitar:input_1")
+
+	rec.mutex.RLock()
+	tap, meter := rec.taps["guitar"], rec.meter
+	rec.mutex.RUnlock()
+
+	// Playing below the threshold is only kept as pre-roll
+	tap.Write([]byte{1, 2, 3, 4})
+	meter.Update("guitar", []float32{0.01})
+	time.Sleep(100 * time.Millisecond)
+	if status, _ := rec.GetStatus(); status != StatusReady {
+		t.Fatalf("Expected READY below the threshold, got %s", status)
+	}
+
+	meter.Update("guitar", []float32{0.5})
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+
+	guitar := inputs[0].(*bufferCloser)
+	if !bytes.Equal(guitar.Bytes(), []byte{1, 2, 3, 4}) {
+		t.Errorf("Expected the pre-roll to be flushed to the encoder, got %v", guitar.Bytes())
+	}
+	if _, session := rec.GetStatus(); session.Armed || session.PreRoll <= 0 || session.Trigger != config.TriggerSignal {
+		t.Errorf("Expected a triggered signal session with pre-roll, got %+v", session)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if !guitar.closed {
+		t.Error("Expected the encoder input to be closed on stop")
+	}
+}
+
+func TestPipeWireRecorder_AutoSplitOnSilence(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.AutoSplit = &config.AutoSplitConfig{Silence: 0.2, Threshold: -50}
+
+	var mutex sync.Mutex
+	var takes [][]io.WriteCloser
+	startEncoder := rec.startEncoder
+	rec.startEncoder = func(channels []config.Channel, outputFile string) ([]io.WriteCloser, error) {
+		inputs, err := startEncoder(channels, outputFile)
+		mutex.Lock()
+		takes = append(takes, inputs)
+		mutex.Unlock()
+		return inputs, err
+	}
+
+	if err := rec.StartReady("long jam"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	graph.AddPort("Chrome:output_FR")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+
+	rec.mutex.RLock()
+	tap, meter := rec.taps["guitar"], rec.meter
+	rec.mutex.RUnlock()
+
+	tap.Write([]byte{1, 2, 3, 4})
+	meter.Update("guitar", []float32{0.5})
+	time.Sleep(100 * time.Millisecond)
+
+	// The backing track keeps playing while the guitar stops
+	meter.Update("chrome", []float32{0.8, 0.8})
+	meter.Update("guitar", []float32{0})
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if _, session := rec.GetStatus(); len(session.Takes) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a second take after the silence")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	tap.Write([]byte{5, 6, 7, 8})
+
+	_, session := rec.GetStatus()
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if !strings.HasSuffix(session.Takes[1].File, "_take02.mkv") || session.OutputFile != session.Takes[1].File {
+		t.Errorf("Expected the second take to be the current file, got %+v", session)
+	}
+	if session.Takes[0].Duration <= 0 {
+		t.Errorf("Expected the first take to have a duration, got %+v", session.Takes[0])
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	first, second := takes[0][0].(*bufferCloser), takes[1][0].(*bufferCloser)
+	if !bytes.Equal(first.Bytes(), []byte{1, 2, 3, 4}) || !first.closed {
+		t.Errorf("Expected the first take to hold the audio before the split, got %v", first.Bytes())
+	}
+	if !bytes.Equal(second.Bytes(), []byte{5, 6, 7, 8}) || !second.closed {
+		t.Errorf("Expected the second take to hold the audio after the split, got %v", second.Bytes())
+	}
+}
+
+// waitForArmed polls the recorder until its session waits for the trigger
+func waitForArmed(t *testing.T, rec Recorder) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		status, session := rec.GetStatus()
+		if status == StatusReady && session != nil && session.Armed {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected an armed READY session, got %s %+v", status, session)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -1,45 +1,22 @@
 package audio
 
 import (
-	"fmt"
 	"strings"
 	"testing"
-)
-
-// TestableValidatePort tests ValidatePort logic without external commands
-func TestableValidatePort(portName string, allPorts []string) error {
-	if portName == "" || portName == "disabled" {
-		return nil
-	}
-
-	// Check if port exists
-	portExists := false
-	for _, port := range allPorts {
-		if port == portName {
-			portExists = true
-			break
-		}
-	}
+	"time"
 
-	if !portExists {
-		return fmt.Errorf("port not found: %s", portName)
-	}
-
-	// Check for duplicates using the same logic as PipeWire.findPortDuplicates
-	pw := &PipeWire{}
-	duplicates := pw.findPortDuplicatesInList(portName, allPorts)
-
-	if len(duplicates) > 1 {
-		return fmt.Errorf("duplicate sources detected for '%s': %v. Please close conflicting applications", portName, duplicates)
-	}
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
 
-	return nil
+// validatePortInGraph runs the real ValidatePort against an in-memory port graph
+func validatePortInGraph(portName string, allPorts []string) error {
+	return NewPipeWireWithGraph(NewMemoryPortGraph(allPorts...)).ValidatePort(portName)
 }
 
 func TestValidatePort_Success(t *testing.T) {
 	mockPorts := []string{"Chrome:output_FL", "system:capture_1"}
 
-	err := TestableValidatePort("system:capture_1", mockPorts)
+	err := validatePortInGraph("system:capture_1", mockPorts)
 	if err != nil {
 		t.Errorf("Expected no error for valid single port, got: %v", err)
 	}
@@ -48,7 +25,7 @@ func TestValidatePort_Success(t *testing.T) {
 func TestValidatePort_NotFound(t *testing.T) {
 	mockPorts := []string{"Chrome:output_FL"}
 
-	err := TestableValidatePort("nonexistent:port", mockPorts)
+	err := validatePortInGraph("nonexistent:port", mockPorts)
 	if err == nil {
 		t.Error("Expected error for nonexistent port")
 	}
@@ -64,7 +41,7 @@ func TestValidatePort_DuplicateDetection(t *testing.T) {
 		"Chrome-2:output_FL", // Different instance - NOT a duplicate
 	}
 
-	err := TestableValidatePort("Chrome:output_FL", mockPorts)
+	err := validatePortInGraph("Chrome:output_FL", mockPorts)
 	if err == nil {
 		t.Error("Expected error for duplicate sources")
 	}
@@ -75,13 +52,13 @@ func TestValidatePort_DuplicateDetection(t *testing.T) {
 
 func TestValidatePort_EmptyAndDisabled(t *testing.T) {
 	// Test empty string
-	err := TestableValidatePort("", []string{})
+	err := validatePortInGraph("", []string{})
 	if err != nil {
 		t.Errorf("Expected no error for empty string, got: %v", err)
 	}
 
 	// Test "disabled"
-	err = TestableValidatePort("disabled", []string{})
+	err = validatePortInGraph("disabled", []string{})
 	if err != nil {
 		t.Errorf("Expected no error for 'disabled', got: %v", err)
 	}
@@ -158,15 +135,237 @@ func TestValidatePort_DifferentChromeInstances_NotDuplicates(t *testing.T) {
 	}
 
 	// Chrome:output_FL should validate successfully (no duplicates)
-	err := TestableValidatePort("Chrome:output_FL", mockPorts)
+	err := validatePortInGraph("Chrome:output_FL", mockPorts)
 	if err != nil {
 		t.Errorf("Expected no error for Chrome:output_FL with different Chrome instances, got: %v", err)
 	}
 
 	// Chrome-2:output_FL should also validate successfully
-	err = TestableValidatePort("Chrome-2:output_FL", mockPorts)
+	err = validatePortInGraph("Chrome-2:output_FL", mockPorts)
 	if err != nil {
 		t.Errorf("Expected no error for Chrome-2:output_FL with different Chrome instances, got: %v", err)
 	}
 }
 
+func TestConnectPortsWithRetry_PortAppearsLater(t *testing.T) {
+	graph := NewMemoryPortGraph("jamcapture_guitar:input_1")
+	pw := NewPipeWireWithGraph(graph)
+	pw.hardwareRetry = retryPolicy{maxRetries: 10, delay: 10 * time.Millisecond}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		graph.AddPort("system:capture_1")
+	}()
+
+	if err := pw.ConnectPortsWithRetry("system:capture_1", "jamcapture_guitar:input_1"); err != nil {
+		t.Fatalf("Expected connection after port appeared, got: %v", err)
+	}
+	if !graph.IsLinked("system:capture_1", "jamcapture_guitar:input_1") {
+		t.Error("Expected ports to be linked in the graph")
+	}
+}
+
+func TestConnectPortsWithRetry_GivesUp(t *testing.T) {
+	graph := NewMemoryPortGraph("jamcapture_chrome:input_1")
+	pw := NewPipeWireWithGraph(graph)
+	pw.ephemeralRetry = retryPolicy{maxRetries: 3, delay: time.Millisecond}
+	pw.hardwareRetry = retryPolicy{maxRetries: 1, delay: time.Millisecond}
+
+	// Chrome is ephemeral and uses the ephemeral retry budget
+	err := pw.ConnectPortsWithRetry("Chrome:output_FL", "jamcapture_chrome:input_1")
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Expected failure after 3 attempts, got: %v", err)
+	}
+}
+
+func TestMemoryPortGraph_LinkByInstanceID(t *testing.T) {
+	graph := NewMemoryPortGraph("Chrome:output_FL", "Chrome:output_FL", "jamcapture_chrome:input_1")
+	ids, _ := graph.ListPortIDs("Chrome:output_FL")
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 instances, got %v", ids)
+	}
+
+	if err := graph.Connect(ids[1], "jamcapture_chrome:input_1"); err != nil {
+		t.Fatalf("Connect by ID failed: %v", err)
+	}
+	if !graph.IsLinked("Chrome:output_FL", "jamcapture_chrome:input_1") || graph.LinkedPortID("Chrome:output_FL", "jamcapture_chrome:input_1") != ids[1] {
+		t.Fatal("Expected the second instance to be linked")
+	}
+
+	// Removing the oldest instance keeps the link to the newest
+	graph.RemovePort("Chrome:output_FL")
+	if !graph.IsLinked("Chrome:output_FL", "jamcapture_chrome:input_1") {
+		t.Error("Expected the link to survive the other instance leaving")
+	}
+	graph.RemovePort("Chrome:output_FL")
+	if graph.IsLinked("Chrome:output_FL", "jamcapture_chrome:input_1") {
+		t.Error("Expected the link to drop with its instance")
+	}
+}
+
+func TestPipeWire_PickInstances(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1")
+	first := graph.AddPortWithProperties("Chrome:output_FL", map[string]string{"media.name": "Metronome"})
+	second := graph.AddPortWithProperties("Chrome:output_FL", map[string]string{"media.name": "YouTube"})
+	pw := NewPipeWireWithGraph(graph)
+
+	youtube := []config.PropertyMatch{{Property: "media.name", Value: "YouTube"}}
+	tests := []struct {
+		name     string
+		source   string
+		rule     config.DuplicateRule
+		expected []string
+	}{
+		{"oldest", "Chrome:output_FL", config.DuplicateRule{Policy: config.DuplicatesOldest}, []string{first}},
+		{"newest", "Chrome:output_FL", config.DuplicateRule{Policy: config.DuplicatesNewest}, []string{second}},
+		{"all", "Chrome:output_FL", config.DuplicateRule{Policy: config.DuplicatesAll}, []string{first, second}},
+		{"match", "Chrome:output_FL", config.DuplicateRule{Policy: config.DuplicatesMatch, Match: youtube}, []string{second}},
+		{"block", "Chrome:output_FL", config.DuplicateRule{Policy: config.DuplicatesBlock}, []string{"Chrome:output_FL"}},
+		{"unique port", "system:capture_1", config.DuplicateRule{Policy: config.DuplicatesNewest}, []string{"system:capture_1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ports, err := pw.pickInstances(tt.source, tt.rule)
+			if err != nil {
+				t.Fatalf("pickInstances failed: %v", err)
+			}
+			if strings.Join(ports, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, ports)
+			}
+		})
+	}
+
+	// No window matches until the right one opens
+	spotify := config.DuplicateRule{Policy: config.DuplicatesMatch, Match: []config.PropertyMatch{{Property: "media.name", Value: "Spotify"}}}
+	if _, err := pw.pickInstances("Chrome:output_FL", spotify); err == nil {
+		t.Error("Expected an error when no instance matches")
+	}
+}
+
+func TestParsePortProperties(t *testing.T) {
+	dump := []byte(`[
+		{"id": 45, "type": "PipeWire:Interface:Node", "info": {"props": {"node.name": "Chrome", "media.name": "YouTube", "application.process.id": 4242}}},
+		{"id": 57, "type": "PipeWire:Interface:Port", "info": {"props": {"node.id": 45, "port.name": "output_FL"}}}
+	]`)
+
+	props, err := parsePortProperties(dump, "57")
+	if err != nil {
+		t.Fatalf("parsePortProperties failed: %v", err)
+	}
+	if props["media.name"] != "YouTube" || props["application.process.id"] != "4242" || props["port.name"] != "output_FL" {
+		t.Errorf("Expected node and port properties, got %v", props)
+	}
+
+	if _, err := parsePortProperties(dump, "45"); err == nil {
+		t.Error("Expected a node ID not to be accepted as a port")
+	}
+}
+
+func TestMemoryPortGraph_RemoveLastInstanceDropsLinks(t *testing.T) {
+	graph := NewMemoryPortGraph("Chrome:output_FL", "Chrome:output_FL", "jamcapture_chrome:input_1")
+	if err := graph.Connect("Chrome:output_FL", "jamcapture_chrome:input_1"); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	graph.RemovePort("Chrome:output_FL")
+	if !graph.IsLinked("Chrome:output_FL", "jamcapture_chrome:input_1") {
+		t.Error("Expected link to survive while a duplicate client remains")
+	}
+
+	graph.RemovePort("Chrome:output_FL")
+	if graph.IsLinked("Chrome:output_FL", "jamcapture_chrome:input_1") {
+		t.Error("Expected link to be dropped with the last client")
+	}
+}
+
+// countingGraph counts how many times the port graph is listed
+type countingGraph struct {
+	*MemoryPortGraph
+	listings int
+}
+
+func (g *countingGraph) ListPorts() ([]string, error) {
+	g.listings++
+	return g.MemoryPortGraph.ListPorts()
+}
+
+func TestPortSnapshot_ValidatePort(t *testing.T) {
+	snapshot := NewPortSnapshot([]string{"Chrome:output_FL", "Chrome:output_FL", "system:capture_1"})
+
+	if err := snapshot.ValidatePort("system:capture_1"); err != nil {
+		t.Errorf("Expected system:capture_1 to be valid, got: %v", err)
+	}
+	if err := snapshot.ValidatePort("Chrome:output_FL"); err == nil || !strings.Contains(err.Error(), "duplicate sources detected") {
+		t.Errorf("Expected 'duplicate sources detected' error, got: %v", err)
+	}
+	if err := snapshot.ValidatePort("Firefox:output_FL"); err == nil || !strings.Contains(err.Error(), "port not found") {
+		t.Errorf("Expected 'port not found' error, got: %v", err)
+	}
+	if snapshot.Count("Chrome:output_FL") != 2 || !snapshot.HasDuplicates("Chrome:output_FL") {
+		t.Errorf("Expected two Chrome:output_FL clients, got %d", snapshot.Count("Chrome:output_FL"))
+	}
+}
+
+func TestPipeWire_SnapshotSharedWithinTTL(t *testing.T) {
+	graph := &countingGraph{MemoryPortGraph: NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")}
+	pw := NewPipeWireWithGraph(graph)
+	pw.snapshotTTL = time.Hour
+
+	for _, port := range []string{"system:capture_1", "Chrome:output_FL", "system:capture_1"} {
+		if err := pw.ValidatePort(port); err != nil {
+			t.Errorf("Expected %s to be valid, got: %v", port, err)
+		}
+	}
+	if graph.listings != 1 {
+		t.Errorf("Expected one listing for all validations, got %d", graph.listings)
+	}
+
+	// A refreshed snapshot sees new ports and is reused by later validations
+	graph.AddPort("Chrome:output_FL")
+	if _, err := pw.RefreshSnapshot(); err != nil {
+		t.Fatalf("RefreshSnapshot failed: %v", err)
+	}
+	if err := pw.ValidatePort("Chrome:output_FL"); err == nil {
+		t.Error("Expected duplicate error after refresh")
+	}
+	if graph.listings != 2 {
+		t.Errorf("Expected two listings after refresh, got %d", graph.listings)
+	}
+
+	pw.snapshotTTL = 0
+	time.Sleep(time.Millisecond)
+	pw.ValidatePort("system:capture_1")
+	if graph.listings != 3 {
+		t.Errorf("Expected expired snapshot to be listed again, got %d listings", graph.listings)
+	}
+}
+
+func TestParseClockSettings(t *testing.T) {
+	output := `Found "settings" metadata 32
+update: id:0 key:'log.level' value:'2' type:''
+update: id:0 key:'clock.rate' value:'48000' type:''
+update: id:0 key:'clock.allowed-rates' value:'[ 44100, 48000, 96000 ]' type:''
+update: id:0 key:'clock.quantum' value:'1024' type:''
+update: id:0 key:'clock.force-rate' value:'0' type:''
+`
+	clock, err := parseClockSettings(output)
+	if err != nil {
+		t.Fatalf("parseClockSettings failed: %v", err)
+	}
+	if clock.Rate != 48000 || !clock.Supports(96000) || clock.Supports(88200) {
+		t.Errorf("Expected 48000 Hz switching to 44100 or 96000, got %+v", clock)
+	}
+
+	forced, err := parseClockSettings(strings.Replace(output, "key:'clock.force-rate' value:'0'", "key:'clock.force-rate' value:'44100'", 1))
+	if err != nil {
+		t.Fatalf("parseClockSettings failed: %v", err)
+	}
+	if forced.Rate != 44100 || forced.Supports(48000) {
+		t.Errorf("Expected a forced 44100 Hz clock, got %+v", forced)
+	}
+
+	if _, err := parseClockSettings("Found \"settings\" metadata 32\n"); err == nil {
+		t.Error("Expected an error without clock.rate")
+	}
+}
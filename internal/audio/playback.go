@@ -0,0 +1,187 @@
+package audio
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// backingLeadIn is the silence played before the backing track, long enough
+// for the capture to link the player before the music starts
+const backingLeadIn = 2 * time.Second
+
+// backingTrack is the backing track a recorder plays when a take starts
+type backingTrack struct {
+	source       string // File selected by the service, none when empty
+	rendered     string // WAV file played, decoded at the graph rate
+	renderedFrom string // Source and modification time of the rendered file
+	cmd          *exec.Cmd
+}
+
+// playerCommand returns the pw-cat command playing a file ("-" for stdin)
+// through a PipeWire node with a known name, so that its output ports can be
+// linked to a capture input. PipeWire connects it to target, or to the default
+// output when empty, for the musicians to hear.
+func (r *PipeWireRecorder) playerCommand(file, node, mediaName, target string) *exec.Cmd {
+	args := []string{"--playback",
+		"--properties", fmt.Sprintf(`{ node.name = "%s" media.name = "%s" }`, node, mediaName)}
+	if target != "" {
+		args = append(args, "--target", target)
+	}
+	cmd := exec.Command("pw-cat", append(args, file)...)
+	cmd.Env = append(os.Environ(), r.pipewireEnv()...)
+	return cmd
+}
+
+// startPlayback plays a file to the default output through a PipeWire node
+// with a known name
+func (r *PipeWireRecorder) startPlayback(file, node, mediaName string) (*exec.Cmd, error) {
+	cmd := r.playerCommand(file, node, mediaName, "")
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start playback: %w", err)
+	}
+	return cmd, nil
+}
+
+// playbackChannel returns the channel recording the backing track, if any
+func playbackChannel(channels []config.Channel) (config.Channel, bool) {
+	for _, channel := range channels {
+		if channel.Type == config.ChannelTypePlayback {
+			return channel, true
+		}
+	}
+	return config.Channel{}, false
+}
+
+// backingNode returns the name of the node playing the backing track
+func (r *PipeWireRecorder) backingNode() string {
+	return r.clientName + "_backingtrack"
+}
+
+// SetBackingTrack sets the file played when the next take starts
+func (r *PipeWireRecorder) SetBackingTrack(file string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.backing.source = file
+}
+
+// prepareBackingTrack decodes the backing track of a session with a playback
+// channel, so that it starts playing as soon as the take does. A track
+// decoded for an earlier session is reused. Must hold r.mutex.
+func (r *PipeWireRecorder) prepareBackingTrack() error {
+	if _, exists := playbackChannel(r.cfg.Channels); !exists {
+		return nil
+	}
+	if r.backing.source == "" {
+		slog.Warn("No backing track selected, the playback channel will record silence")
+		return nil
+	}
+
+	info, err := os.Stat(r.backing.source)
+	if err != nil {
+		return fmt.Errorf("backing track not found: %s", r.backing.source)
+	}
+	renderedFrom := fmt.Sprintf("%s@%d", r.backing.source, info.ModTime().UnixNano())
+	if renderedFrom == r.backing.renderedFrom {
+		if _, err := os.Stat(r.backing.rendered); err == nil {
+			return nil
+		}
+	}
+
+	rendered := filepath.Join(os.TempDir(), r.backingNode()+".wav")
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", r.backing.source,
+		"-af", fmt.Sprintf("adelay=%d:all=1", backingLeadIn.Milliseconds()),
+		"-ar", fmt.Sprintf("%d", r.sampleRate()),
+		"-ac", "2",
+		"-c:a", "pcm_f32le",
+		"-y", rendered,
+	}
+	slog.Debug("Decoding backing track", "command", "ffmpeg "+strings.Join(args, " "))
+
+	if output, err := exec.Command("ffmpeg", args...).CombinedOutput(); err != nil {
+		r.backing.rendered, r.backing.renderedFrom = "", ""
+		return fmt.Errorf("failed to decode backing track %s: %w (output: %s)", filepath.Base(r.backing.source), err, strings.TrimSpace(string(output)))
+	}
+
+	r.backing.rendered, r.backing.renderedFrom = rendered, renderedFrom
+	slog.Info("Backing track ready", "file", r.backing.source)
+	return nil
+}
+
+// startBackingTrack plays the backing track into the playback channel of a
+// take that just started. Must hold r.mutex.
+func (r *PipeWireRecorder) startBackingTrack(channels []config.Channel, stop <-chan struct{}) error {
+	channel, exists := playbackChannel(channels)
+	if !exists || r.backing.source == "" || r.backing.rendered == "" {
+		return nil
+	}
+
+	cmd, err := r.startPlayback(r.backing.rendered, r.backingNode(), "jamcapture backing track")
+	if err != nil {
+		return err
+	}
+	r.backing.cmd = cmd
+	r.session.BackingTrack = filepath.Base(r.backing.source)
+
+	slog.Info("Backing track playing", "file", r.backing.source, "node", r.backingNode())
+	go r.linkBackingTrack(channel, stop)
+	return nil
+}
+
+// linkBackingTrack links the player to the capture of the playback channel.
+// Both run in the same graph as the other channels, so the backing track is
+// recorded at the sample it was played, with no latency to compensate.
+func (r *PipeWireRecorder) linkBackingTrack(channel config.Channel, stop <-chan struct{}) {
+	r.linkPlayer(channel, r.backingNode(), []string{"FL", "FR"}, stop)
+}
+
+// linkPlayer links the outputs of a player node, in the order of their
+// channel positions, to input_1, input_2... of the capture of a channel
+func (r *PipeWireRecorder) linkPlayer(channel config.Channel, node string, positions []string, stop <-chan struct{}) {
+	var linked []string
+	for i, position := range positions {
+		source := fmt.Sprintf("%s:output_%s", node, position)
+		dest := fmt.Sprintf("%s:input_%d", r.captureClient(channel.Name), i+1)
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		err := r.waitForSpecificPort(dest, 5*time.Second)
+		if err == nil {
+			err = r.waitForSpecificPort(source, 5*time.Second)
+		}
+		if err == nil {
+			err = r.pipewire.ConnectPortsWithRetry(source, dest)
+		}
+		if err != nil {
+			slog.Error("Failed to link player", "channel", channel.Name, "source", source, "dest", dest, "error", err)
+			r.channels.set(channel.Name, ChannelDropped, linked, err.Error())
+			return
+		}
+		linked = append(linked, source)
+	}
+
+	slog.Info("Player linked", "channel", channel.Name, "node", node)
+	r.channels.link(channel.Name, linked, true)
+}
+
+// stopBackingTrack ends the playback of the backing track, if it is playing
+func (r *PipeWireRecorder) stopBackingTrack() {
+	if r.backing.cmd != nil && r.backing.cmd.Process != nil {
+		r.backing.cmd.Process.Kill()
+		r.backing.cmd.Wait()
+	}
+	r.backing.cmd = nil
+}
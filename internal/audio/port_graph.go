@@ -0,0 +1,536 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PortGraph abstracts the JACK/PipeWire port graph so that port discovery
+// and linking can be backed by pw-link or by an in-memory model
+type PortGraph interface {
+	// ListPorts returns every port name, once per client exposing it
+	ListPorts() ([]string, error)
+
+	// Connect links an output port to an input port
+	Connect(sourcePort, destPort string) error
+
+	// Disconnect removes a link between two ports
+	Disconnect(sourcePort, destPort string) error
+}
+
+// PortInstanceLister is implemented by port graphs that can tell apart the
+// clients exposing the same port name, such as two Chrome instances
+type PortInstanceLister interface {
+	// ListPortIDs returns the graph ID of every port with this name, oldest
+	// first. An ID may be passed to Connect in place of the port name.
+	ListPortIDs(portName string) ([]string, error)
+}
+
+// PortPropertiesLister is implemented by port graphs that can describe the
+// node owning a port instance, so duplicates can be told apart by the
+// application, stream or device they come from
+type PortPropertiesLister interface {
+	// PortProperties returns the properties of a port instance and of its
+	// node, such as node.name, media.name and application.process.id
+	PortProperties(id string) (map[string]string, error)
+}
+
+// ClockReader is implemented by port graphs that can report the clock of
+// the audio graph, so that a capture at another rate can be caught early
+type ClockReader interface {
+	// Clock returns the rate the graph runs at and the rates it may switch to
+	Clock() (GraphClock, error)
+}
+
+// GraphClock is the sample rate configuration of the audio graph
+type GraphClock struct {
+	Rate         int   // Current rate, or the forced rate when one is set
+	AllowedRates []int // Rates the graph may switch to when a client asks
+}
+
+// Supports reports whether the graph runs at a rate or may switch to it
+func (c GraphClock) Supports(rate int) bool {
+	if c.Rate == rate {
+		return true
+	}
+	for _, allowed := range c.AllowedRates {
+		if allowed == rate {
+			return true
+		}
+	}
+	return false
+}
+
+// PortEventType identifies a change in the port graph
+type PortEventType string
+
+const (
+	PortAdded   PortEventType = "added"
+	PortRemoved PortEventType = "removed"
+)
+
+// PortEvent describes a port appearing in or disappearing from the graph
+type PortEvent struct {
+	Type PortEventType
+	Port string
+}
+
+// PortEventSource is implemented by port graphs that push changes as they
+// happen, letting the recorder react without polling
+type PortEventSource interface {
+	// Subscribe returns a channel of port events and a function cancelling the subscription
+	Subscribe() (<-chan PortEvent, func())
+}
+
+// PwLinkGraph implements PortGraph by running the pw-link command
+type PwLinkGraph struct{}
+
+// ListPorts returns all available JACK ports via pw-link
+func (g *PwLinkGraph) ListPorts() ([]string, error) {
+	cmd := exec.Command("pw-link", "-io")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PipeWire ports: %w", err)
+	}
+
+	lines := strings.Split(string(output), "\n")
+	var ports []string
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "Input ports:") && !strings.HasPrefix(line, "Output ports:") {
+			ports = append(ports, line)
+		}
+	}
+
+	return ports, nil
+}
+
+// ListPortIDs returns the PipeWire IDs of the output ports with this name via
+// pw-link -I. PipeWire hands out increasing IDs, so the lowest is the oldest.
+func (g *PwLinkGraph) ListPortIDs(portName string) ([]string, error) {
+	cmd := exec.Command("pw-link", "-I", "-o")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PipeWire port IDs: %w", err)
+	}
+
+	var ids []int
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		// Port names may contain spaces, so take everything after the ID
+		name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+		if name == portName {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = strconv.Itoa(id)
+	}
+	return result, nil
+}
+
+// PortProperties returns the properties of a port and its node from pw-dump
+func (g *PwLinkGraph) PortProperties(id string) (map[string]string, error) {
+	cmd := exec.Command("pw-dump")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump PipeWire objects: %w", err)
+	}
+	return parsePortProperties(output, id)
+}
+
+// pwDumpObject is the part of a pw-dump object needed to read its properties
+type pwDumpObject struct {
+	ID   json.Number `json:"id"`
+	Type string      `json:"type"`
+	Info struct {
+		Props map[string]interface{} `json:"props"`
+	} `json:"info"`
+}
+
+// parsePortProperties finds a port in pw-dump output and merges its
+// properties over those of its node
+func parsePortProperties(dump []byte, id string) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(dump))
+	decoder.UseNumber()
+
+	var objects []pwDumpObject
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, fmt.Errorf("failed to parse pw-dump output: %w", err)
+	}
+
+	byID := make(map[string]pwDumpObject, len(objects))
+	for _, object := range objects {
+		byID[object.ID.String()] = object
+	}
+
+	port, exists := byID[id]
+	if !exists || port.Type != "PipeWire:Interface:Port" {
+		return nil, fmt.Errorf("port %s not found", id)
+	}
+
+	props := make(map[string]string)
+	if node, exists := byID[fmt.Sprint(port.Info.Props["node.id"])]; exists {
+		for key, value := range node.Info.Props {
+			props[key] = fmt.Sprint(value)
+		}
+	}
+	for key, value := range port.Info.Props {
+		props[key] = fmt.Sprint(value)
+	}
+	return props, nil
+}
+
+// Clock reads the clock settings of the PipeWire graph with pw-metadata
+func (g *PwLinkGraph) Clock() (GraphClock, error) {
+	cmd := exec.Command("pw-metadata", "-n", "settings", "0")
+	output, err := cmd.Output()
+	if err != nil {
+		return GraphClock{}, fmt.Errorf("failed to read PipeWire settings: %w", err)
+	}
+	return parseClockSettings(string(output))
+}
+
+// parseClockSettings reads clock.rate, clock.force-rate and
+// clock.allowed-rates from pw-metadata output lines such as:
+//
+//	update: id:0 key:'clock.rate' value:'48000' type:''
+func parseClockSettings(output string) (GraphClock, error) {
+	settings := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, hasKey := quotedField(line, "key:'")
+		value, hasValue := quotedField(line, "value:'")
+		if hasKey && hasValue {
+			settings[key] = value
+		}
+	}
+
+	var clock GraphClock
+	rate, err := strconv.Atoi(settings["clock.rate"])
+	if err != nil {
+		return clock, fmt.Errorf("no clock.rate in PipeWire settings")
+	}
+	clock.Rate = rate
+	if forced, err := strconv.Atoi(settings["clock.force-rate"]); err == nil && forced > 0 {
+		clock.Rate = forced
+	}
+
+	for _, field := range strings.Fields(strings.Trim(settings["clock.allowed-rates"], "[]")) {
+		if allowed, err := strconv.Atoi(strings.Trim(field, ",")); err == nil {
+			clock.AllowedRates = append(clock.AllowedRates, allowed)
+		}
+	}
+	// A forced rate is the only rate the graph runs at
+	if clock.Rate != rate {
+		clock.AllowedRates = nil
+	}
+	return clock, nil
+}
+
+// quotedField returns the text between a prefix ending in a quote and the next quote
+func quotedField(line, prefix string) (string, bool) {
+	start := strings.Index(line, prefix)
+	if start < 0 {
+		return "", false
+	}
+	rest := line[start+len(prefix):]
+	end := strings.Index(rest, "'")
+	if end < 0 {
+		return "", false
+	}
+	return rest[:end], true
+}
+
+// Connect links two ports with pw-link
+func (g *PwLinkGraph) Connect(sourcePort, destPort string) error {
+	cmd := exec.Command("pw-link", sourcePort, destPort)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to connect ports: %w (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+// Disconnect unlinks two ports with pw-link -d
+func (g *PwLinkGraph) Disconnect(sourcePort, destPort string) error {
+	cmd := exec.Command("pw-link", "-d", sourcePort, destPort)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to disconnect ports: %w (output: %s)", err, string(output))
+	}
+	return nil
+}
+
+// portLink identifies a link between two ports
+type portLink struct {
+	source string
+	dest   string
+}
+
+// memoryPort is one client's instance of a port
+type memoryPort struct {
+	id    int
+	name  string
+	props map[string]string
+}
+
+// MemoryPortGraph implements PortGraph in memory. The same port name may be
+// added several times to model duplicate clients such as two Chrome instances.
+type MemoryPortGraph struct {
+	mutex  sync.RWMutex
+	ports  []memoryPort
+	nextID int
+	links  map[portLink]int // Linked source instance, 0 when linked by name
+
+	// Event subscribers
+	subscribers      map[int]chan PortEvent
+	nextSubscriberID int
+
+	clock GraphClock
+}
+
+// NewMemoryPortGraph creates an in-memory graph with the given ports
+func NewMemoryPortGraph(ports ...string) *MemoryPortGraph {
+	g := &MemoryPortGraph{
+		links:       make(map[portLink]int),
+		subscribers: make(map[int]chan PortEvent),
+	}
+	for _, name := range ports {
+		g.nextID++
+		g.ports = append(g.ports, memoryPort{id: g.nextID, name: name})
+	}
+	return g
+}
+
+// ListPorts returns a copy of the ports currently in the graph
+func (g *MemoryPortGraph) ListPorts() ([]string, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	ports := make([]string, len(g.ports))
+	for i, port := range g.ports {
+		ports[i] = port.name
+	}
+	return ports, nil
+}
+
+// ListPortIDs returns the IDs of every instance of a port, oldest first
+func (g *MemoryPortGraph) ListPortIDs(portName string) ([]string, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	var ids []string
+	for _, port := range g.ports {
+		if port.name == portName {
+			ids = append(ids, strconv.Itoa(port.id))
+		}
+	}
+	return ids, nil
+}
+
+// Connect links two ports that both exist in the graph. The source may
+// be given by name or by the ID of one of its instances.
+func (g *MemoryPortGraph) Connect(sourcePort, destPort string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	name, id := g.resolve(sourcePort)
+	if !g.hasPort(name) {
+		return fmt.Errorf("failed to connect ports: source port not found: %s", sourcePort)
+	}
+	if !g.hasPort(destPort) {
+		return fmt.Errorf("failed to connect ports: destination port not found: %s", destPort)
+	}
+
+	g.links[portLink{source: name, dest: destPort}] = id
+	return nil
+}
+
+// Disconnect removes an existing link
+func (g *MemoryPortGraph) Disconnect(sourcePort, destPort string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	name, _ := g.resolve(sourcePort)
+	link := portLink{source: name, dest: destPort}
+	if _, exists := g.links[link]; !exists {
+		return fmt.Errorf("failed to disconnect ports: no link from %s to %s", sourcePort, destPort)
+	}
+
+	delete(g.links, link)
+	return nil
+}
+
+// AddPort adds a port to the graph. Adding an existing name again
+// models a duplicate client.
+func (g *MemoryPortGraph) AddPort(name string) {
+	g.AddPortWithProperties(name, nil)
+}
+
+// AddPortWithProperties adds a port whose node has the given properties
+// and returns the ID of the new instance
+func (g *MemoryPortGraph) AddPortWithProperties(name string, props map[string]string) string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.nextID++
+	g.ports = append(g.ports, memoryPort{id: g.nextID, name: name, props: props})
+	g.notify(PortEvent{Type: PortAdded, Port: name})
+	return strconv.Itoa(g.nextID)
+}
+
+// SetClock sets the clock reported by the graph
+func (g *MemoryPortGraph) SetClock(clock GraphClock) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.clock = clock
+}
+
+// Clock returns the clock set with SetClock, a zero rate meaning unknown
+func (g *MemoryPortGraph) Clock() (GraphClock, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.clock, nil
+}
+
+// PortProperties returns the properties a port instance was added with
+func (g *MemoryPortGraph) PortProperties(id string) (map[string]string, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	for _, port := range g.ports {
+		if strconv.Itoa(port.id) == id {
+			props := make(map[string]string, len(port.props))
+			for key, value := range port.props {
+				props[key] = value
+			}
+			return props, nil
+		}
+	}
+	return nil, fmt.Errorf("port %s not found", id)
+}
+
+// RemovePort removes the oldest instance of a port. Links are dropped
+// once the instance they use or the last instance disappears, as
+// PipeWire does when a client exits.
+func (g *MemoryPortGraph) RemovePort(name string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for i, port := range g.ports {
+		if port.name != name {
+			continue
+		}
+
+		g.ports = append(g.ports[:i], g.ports[i+1:]...)
+		last := !g.hasPort(name)
+		for link, id := range g.links {
+			if (last && (link.source == name || link.dest == name)) || (id != 0 && id == port.id) {
+				delete(g.links, link)
+			}
+		}
+		g.notify(PortEvent{Type: PortRemoved, Port: name})
+		return true
+	}
+
+	return false
+}
+
+// IsLinked reports whether a link exists between two ports
+func (g *MemoryPortGraph) IsLinked(sourcePort, destPort string) bool {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	_, exists := g.links[portLink{source: sourcePort, dest: destPort}]
+	return exists
+}
+
+// LinkedPortID returns the ID of the source instance linked to destPort,
+// or "" if the link was made by name or does not exist
+func (g *MemoryPortGraph) LinkedPortID(sourcePort, destPort string) string {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	if id := g.links[portLink{source: sourcePort, dest: destPort}]; id != 0 {
+		return strconv.Itoa(id)
+	}
+	return ""
+}
+
+// Subscribe returns a channel receiving every port added or removed
+func (g *MemoryPortGraph) Subscribe() (<-chan PortEvent, func()) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	id := g.nextSubscriberID
+	g.nextSubscriberID++
+	events := make(chan PortEvent, 64)
+	g.subscribers[id] = events
+
+	unsubscribe := func() {
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		if ch, exists := g.subscribers[id]; exists {
+			delete(g.subscribers, id)
+			close(ch)
+		}
+	}
+
+	return events, unsubscribe
+}
+
+// notify delivers an event to all subscribers without blocking. A full
+// subscriber misses the event, which is harmless because consumers rescan
+// the whole graph on every event. Callers must hold g.mutex.
+func (g *MemoryPortGraph) notify(event PortEvent) {
+	for _, ch := range g.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// hasPort reports whether at least one instance of a port exists.
+// Callers must hold g.mutex.
+func (g *MemoryPortGraph) hasPort(name string) bool {
+	for _, port := range g.ports {
+		if port.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the port name and instance ID a port reference stands
+// for. A reference that is not the ID of an instance is a port name.
+// Callers must hold g.mutex.
+func (g *MemoryPortGraph) resolve(ref string) (string, int) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		for _, port := range g.ports {
+			if port.id == id {
+				return port.name, id
+			}
+		}
+	}
+	return ref, 0
+}
@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultSnapshotTTL is how long a cached port snapshot answers validations
+// before the graph is listed again
+const defaultSnapshotTTL = 250 * time.Millisecond
+
+// PortSnapshot is a consistent view of the port graph taken at one point in
+// time. A monitoring pass takes one snapshot and shares it between all of its
+// validations, so the graph is listed once per pass instead of once per source.
+type PortSnapshot struct {
+	TakenAt time.Time
+
+	ports  []string
+	counts map[string]int
+}
+
+// NewPortSnapshot creates a snapshot from a port listing
+func NewPortSnapshot(ports []string) *PortSnapshot {
+	counts := make(map[string]int, len(ports))
+	for _, port := range ports {
+		counts[port]++
+	}
+
+	return &PortSnapshot{
+		TakenAt: time.Now(),
+		ports:   append([]string(nil), ports...),
+		counts:  counts,
+	}
+}
+
+// TakePortSnapshot lists the graph once and returns the resulting snapshot
+func TakePortSnapshot(graph PortGraph) (*PortSnapshot, error) {
+	ports, err := graph.ListPorts()
+	if err != nil {
+		return nil, err
+	}
+	return NewPortSnapshot(ports), nil
+}
+
+// Ports returns every port in the snapshot, once per client exposing it
+func (s *PortSnapshot) Ports() []string {
+	return append([]string(nil), s.ports...)
+}
+
+// Exists reports whether at least one client exposes the port
+func (s *PortSnapshot) Exists(portName string) bool {
+	return s.counts[portName] > 0
+}
+
+// Count returns how many clients expose a port with exactly this name
+func (s *PortSnapshot) Count(portName string) int {
+	return s.counts[portName]
+}
+
+// HasDuplicates reports whether several clients expose the same port name
+func (s *PortSnapshot) HasDuplicates(portName string) bool {
+	return s.counts[portName] > 1
+}
+
+// ValidatePort checks that a port exists exactly once in the snapshot
+func (s *PortSnapshot) ValidatePort(portName string) error {
+	if portName == "" || portName == "disabled" {
+		return nil
+	}
+
+	count := s.counts[portName]
+	if count == 0 {
+		return fmt.Errorf("port not found: %s", portName)
+	}
+
+	if count > 1 {
+		duplicates := make([]string, count)
+		for i := range duplicates {
+			duplicates[i] = portName
+		}
+		return fmt.Errorf("duplicate sources detected for '%s': %v. Please close conflicting applications", portName, duplicates)
+	}
+
+	return nil
+}
+
+// expired reports whether the snapshot is older than ttl
+func (s *PortSnapshot) expired(ttl time.Duration) bool {
+	return time.Since(s.TakenAt) > ttl
+}
@@ -0,0 +1,257 @@
+package audio
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// watcherSettleDelay is the quiet period after which the initial
+	// pw-link --monitor listing is considered complete
+	watcherSettleDelay = 150 * time.Millisecond
+
+	// watcherStartTimeout bounds how long ListPorts waits for the initial listing
+	watcherStartTimeout = 2 * time.Second
+)
+
+// WatchedPortGraph implements PortGraph and PortEventSource on top of a
+// streaming "pw-link --monitor" process. Ports are kept in memory and updated
+// as PipeWire reports them, so listing ports never spawns a process and
+// subscribers learn about new sources the moment they appear. Links are
+// still created with pw-link. When the monitor cannot be started or exits,
+// the graph falls back to one-shot pw-link listing.
+type WatchedPortGraph struct {
+	pwLink PwLinkGraph
+	state  *MemoryPortGraph
+
+	mutex       sync.Mutex
+	cmd         *exec.Cmd
+	running     bool
+	unsupported bool // monitor exited before its initial listing
+	closed      bool
+	ready       chan struct{}
+}
+
+// NewWatchedPortGraph creates a port graph fed by pw-link --monitor.
+// The monitor process is started lazily on first use.
+func NewWatchedPortGraph() *WatchedPortGraph {
+	return &WatchedPortGraph{
+		state: NewMemoryPortGraph(),
+	}
+}
+
+// ListPorts returns the ports currently known to the monitor
+func (g *WatchedPortGraph) ListPorts() ([]string, error) {
+	if !g.ensureStarted() {
+		return g.pwLink.ListPorts()
+	}
+	return g.state.ListPorts()
+}
+
+// ListPortIDs returns the PipeWire IDs of a port with pw-link, since the
+// monitor only reports port names
+func (g *WatchedPortGraph) ListPortIDs(portName string) ([]string, error) {
+	return g.pwLink.ListPortIDs(portName)
+}
+
+// PortProperties returns the properties of a port instance with pw-dump
+func (g *WatchedPortGraph) PortProperties(id string) (map[string]string, error) {
+	return g.pwLink.PortProperties(id)
+}
+
+// Clock reads the clock settings of the graph with pw-metadata
+func (g *WatchedPortGraph) Clock() (GraphClock, error) {
+	return g.pwLink.Clock()
+}
+
+// Connect links two ports with pw-link and records the link
+func (g *WatchedPortGraph) Connect(sourcePort, destPort string) error {
+	if err := g.pwLink.Connect(sourcePort, destPort); err != nil {
+		return err
+	}
+	// The monitor may not have reported the ports yet; the link exists regardless
+	g.state.Connect(sourcePort, destPort)
+	return nil
+}
+
+// Disconnect unlinks two ports with pw-link
+func (g *WatchedPortGraph) Disconnect(sourcePort, destPort string) error {
+	if err := g.pwLink.Disconnect(sourcePort, destPort); err != nil {
+		return err
+	}
+	g.state.Disconnect(sourcePort, destPort)
+	return nil
+}
+
+// Subscribe returns a channel of port events reported by the monitor
+func (g *WatchedPortGraph) Subscribe() (<-chan PortEvent, func()) {
+	g.ensureStarted()
+	return g.state.Subscribe()
+}
+
+// Close stops the monitor process
+func (g *WatchedPortGraph) Close() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.closed = true
+	if g.cmd != nil && g.cmd.Process != nil {
+		g.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// ensureStarted starts the monitor if needed and waits for its initial
+// listing. It reports whether the in-memory state can be trusted.
+func (g *WatchedPortGraph) ensureStarted() bool {
+	g.mutex.Lock()
+	if g.closed || g.unsupported {
+		g.mutex.Unlock()
+		return false
+	}
+	if !g.running {
+		if err := g.start(); err != nil {
+			g.mutex.Unlock()
+			slog.Debug("PipeWire port monitor unavailable, falling back to polling", "error", err)
+			return false
+		}
+	}
+	ready := g.ready
+	g.mutex.Unlock()
+
+	select {
+	case <-ready:
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		return g.running
+	case <-time.After(watcherStartTimeout):
+		return false
+	}
+}
+
+// start launches pw-link --monitor. Callers must hold g.mutex.
+func (g *WatchedPortGraph) start() error {
+	cmd := exec.Command("pw-link", "--monitor", "--input", "--output")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Start from an empty graph, the monitor lists every existing port first
+	g.resetState()
+	g.cmd = cmd
+	g.running = true
+	g.ready = make(chan struct{})
+
+	go g.consume(cmd, stdout, g.ready)
+	slog.Debug("PipeWire port monitor started")
+	return nil
+}
+
+// resetState removes all known ports while keeping subscribers attached
+func (g *WatchedPortGraph) resetState() {
+	ports, _ := g.state.ListPorts()
+	for _, port := range ports {
+		g.state.RemovePort(port)
+	}
+}
+
+// consume applies monitor output to the in-memory graph until the process exits
+func (g *WatchedPortGraph) consume(cmd *exec.Cmd, stdout io.Reader, ready chan struct{}) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	settled := false
+	settle := time.NewTimer(watcherSettleDelay)
+	defer settle.Stop()
+
+	markReady := func() {
+		if !settled {
+			settled = true
+			close(ready)
+		}
+	}
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				cmd.Wait()
+				g.mutex.Lock()
+				g.running = false
+				if !settled {
+					g.unsupported = true
+				}
+				g.mutex.Unlock()
+				markReady()
+				slog.Debug("PipeWire port monitor exited")
+				return
+			}
+
+			event, ok := parseMonitorLine(line)
+			if !ok {
+				continue
+			}
+			if event.Type == PortAdded {
+				g.state.AddPort(event.Port)
+			} else {
+				g.state.RemovePort(event.Port)
+			}
+
+			if !settled {
+				settle.Reset(watcherSettleDelay)
+			}
+
+		case <-settle.C:
+			markReady()
+		}
+	}
+}
+
+// parseMonitorLine parses one line of pw-link --monitor output. Ports present
+// at startup are prefixed with "=", later additions with "+" and removals
+// with "-", a bare port name is an addition. Link lines ("|->", "|<-"),
+// headers and lines with any other marker, such as "*" for a change, are ignored.
+func parseMonitorLine(line string) (PortEvent, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" {
+		return PortEvent{}, false
+	}
+
+	eventType := PortAdded
+	switch line[0] {
+	case '=', '+':
+		line = line[1:]
+	case '-':
+		eventType = PortRemoved
+		line = line[1:]
+	default:
+		// Port names start with the client name, never with punctuation
+		if r, _ := utf8.DecodeRuneInString(line); !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return PortEvent{}, false
+		}
+	}
+
+	port := strings.TrimSpace(line)
+	if port == "" || strings.HasPrefix(port, "|") || strings.HasSuffix(port, "ports:") {
+		return PortEvent{}, false
+	}
+
+	return PortEvent{Type: eventType, Port: port}, true
+}
@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMonitorLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected PortEvent
+		ok       bool
+	}{
+		{"= alsa_input.usb:capture_FL", PortEvent{Type: PortAdded, Port: "alsa_input.usb:capture_FL"}, true},
+		{"+ Chrome:output_FL", PortEvent{Type: PortAdded, Port: "Chrome:output_FL"}, true},
+		{"- Chrome:output_FL", PortEvent{Type: PortRemoved, Port: "Chrome:output_FL"}, true},
+		{"Firefox (1):output_FR", PortEvent{Type: PortAdded, Port: "Firefox (1):output_FR"}, true},
+		{"=   |-> jamcapture_chrome:input_1", PortEvent{}, false},
+		{"Output ports:", PortEvent{}, false},
+		{"* Chrome:output_FL", PortEvent{}, false},
+		{"~ Chrome:output_FL", PortEvent{}, false},
+		{"", PortEvent{}, false},
+	}
+
+	for _, tt := range tests {
+		event, ok := parseMonitorLine(tt.line)
+		if ok != tt.ok || event != tt.expected {
+			t.Errorf("parseMonitorLine(%q) = %v, %v; expected %v, %v", tt.line, event, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestMemoryPortGraph_Subscribe(t *testing.T) {
+	graph := NewMemoryPortGraph()
+	events, unsubscribe := graph.Subscribe()
+
+	graph.AddPort("Chrome:output_FL")
+	graph.RemovePort("Chrome:output_FL")
+
+	for _, expected := range []PortEvent{
+		{Type: PortAdded, Port: "Chrome:output_FL"},
+		{Type: PortRemoved, Port: "Chrome:output_FL"},
+	} {
+		select {
+		case event := <-events:
+			if event != expected {
+				t.Errorf("Expected %v, got %v", expected, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %v", expected)
+		}
+	}
+
+	unsubscribe()
+	if _, open := <-events; open {
+		t.Error("Expected events channel to be closed after unsubscribe")
+	}
+}
+
+func TestPipeWireRecorder_StartsOnPortEvent(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+
+	if err := rec.StartReady("event song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+
+	// Well below the fallback poll interval: only the port event can trigger recording
+	graph.AddPort("Chrome:output_FR")
+	waitForStatusWithin(t, rec, StatusRecording, sourceFallbackInterval/5)
+}
@@ -1,7 +1,12 @@
 package audio
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
 )
 
 // Status represents the current state of the recorder
@@ -14,13 +19,114 @@ const (
 	StatusError     Status = "ERROR"
 )
 
+// ConnectionEventType identifies a change in a source link during recording
+type ConnectionEventType string
+
+const (
+	ConnectionDropped     ConnectionEventType = "dropped"
+	ConnectionReconnected ConnectionEventType = "reconnected"
+)
+
+// ConnectionEvent records a source link dropping out or being restored while
+// recording, so that damaged parts of a take can be located
+type ConnectionEvent struct {
+	Time    time.Time           `json:"time"`
+	Offset  float64             `json:"offset"`         // Seconds into the take
+	Take    int                 `json:"take,omitempty"` // Take the event happened in
+	Type    ConnectionEventType `json:"type"`
+	Channel string              `json:"channel"`
+	Source  string              `json:"source"`
+}
+
+// TakeInfo describes one file of a recording session. Sessions with an
+// auto-split policy start a new take after each long silence.
+type TakeInfo struct {
+	Number    int       `json:"number"`
+	File      string    `json:"file"`
+	StartTime time.Time `json:"start_time"`
+	Duration  float64   `json:"duration"` // Seconds, 0 while the take is recording
+}
+
 // SessionInfo contains information about the current recording session
 type SessionInfo struct {
-	SongName     string    `json:"song_name"`
-	StartTime    time.Time `json:"start_time"`
-	OutputFile   string    `json:"output_file"`
-	ChannelCount int       `json:"channel_count"`
-	ChannelNames []string  `json:"channel_names"`
+	SongName         string            `json:"song_name"`
+	StartTime        time.Time         `json:"start_time"`
+	OutputFile       string            `json:"output_file"`
+	ChannelCount     int               `json:"channel_count"`
+	ChannelNames     []string          `json:"channel_names"`
+	ConnectionEvents []ConnectionEvent `json:"connection_events,omitempty"`
+	Trigger          string            `json:"trigger"`                 // "sources", "signal" or "manual"
+	Armed            bool              `json:"armed,omitempty"`         // Capturing while READY, waiting for the trigger
+	PreRoll          float64           `json:"pre_roll,omitempty"`      // Seconds of audio recorded before the trigger
+	Takes            []TakeInfo        `json:"takes,omitempty"`         // Files recorded so far, OutputFile is the last one
+	Overdub          string            `json:"overdub,omitempty"`       // Take being overdubbed onto OutputFile
+	BackingTrack     string            `json:"backing_track,omitempty"` // Backing track played into the playback channel
+	BPM              float64           `json:"bpm,omitempty"`           // Tempo of the metronome, 0 without click
+	CountingIn       bool              `json:"counting_in,omitempty"`   // READY, the take starts on the downbeat ending the count-in
+}
+
+// clone returns a copy of the session that shares no slice with it
+func (s *SessionInfo) clone() *SessionInfo {
+	session := *s
+	session.ChannelNames = make([]string, len(s.ChannelNames))
+	copy(session.ChannelNames, s.ChannelNames)
+	session.ConnectionEvents = append([]ConnectionEvent(nil), s.ConnectionEvents...)
+	session.Takes = append([]TakeInfo(nil), s.Takes...)
+	return &session
+}
+
+// DefaultClientName prefixes the JACK client capturing each channel: the
+// guitar channel is captured by jamcapture_guitar
+const DefaultClientName = "jamcapture"
+
+// ClientNamer is implemented by recorders that create JACK clients in the
+// graph. Recorders running side by side need distinct names, otherwise they
+// would link their sources to each other's inputs.
+type ClientNamer interface {
+	// SetClientName sets the prefix of the capture clients, used from the next session
+	SetClientName(name string)
+}
+
+// BackingTrackPlayer is implemented by recorders that play the backing track
+// themselves when a take starts, and record it through a playback channel
+type BackingTrackPlayer interface {
+	// SetBackingTrack sets the file played from the next session, none when empty
+	SetBackingTrack(file string)
+}
+
+// MetronomePlayer is implemented by recorders that play the click of the
+// profile's metronome and hold the take for its count-in
+type MetronomePlayer interface {
+	// SetMetronome overrides the tempo and count-in from the next session.
+	// A bpm of 0 and a nil countIn keep the profile's.
+	SetMetronome(bpm float64, countIn *int)
+}
+
+// errDuplicatesWhileReady is reported when duplicate sources end a READY session
+var errDuplicatesWhileReady = errors.New("duplicate audio sources appeared while READY - returned to STANDBY, close conflicting applications or set a ready duplicates policy")
+
+// ReadyTimeoutError reports a READY session that gave up waiting for its sources
+type ReadyTimeoutError struct {
+	Timeout time.Duration
+	Waiting []string // Channels whose sources were still missing or duplicated
+}
+
+func (e *ReadyTimeoutError) Error() string {
+	if len(e.Waiting) == 0 {
+		return fmt.Sprintf("READY timed out after %s - returned to STANDBY", e.Timeout)
+	}
+	return fmt.Sprintf("READY timed out after %s waiting for %s - returned to STANDBY", e.Timeout, strings.Join(e.Waiting, ", "))
+}
+
+// newReadyTimeoutError lists the channels, in configuration order, that were not ready
+func newReadyTimeoutError(timeout time.Duration, channels []config.Channel, statuses map[string]ChannelStatus) *ReadyTimeoutError {
+	err := &ReadyTimeoutError{Timeout: timeout}
+	for _, channel := range channels {
+		if state := statuses[channel.Name].State; state == ChannelWaiting || state == ChannelDuplicate {
+			err.Waiting = append(err.Waiting, channel.Name)
+		}
+	}
+	return err
 }
 
 // Recorder defines the interface that all audio recorders must implement
@@ -32,7 +138,11 @@ type Recorder interface {
 
 	// Status and information
 	GetStatus() (Status, *SessionInfo)
-	GetChannelStatus() map[string]string
+	GetChannelStatus() map[string]ChannelStatus
+
+	// GetLastError returns why the recorder last ended a session on its own,
+	// such as a READY timeout. It is cleared by StartReady.
+	GetLastError() error
 
 	// Cleanup
 	Cleanup() error
@@ -0,0 +1,162 @@
+package audio
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// segmentDirSuffix names the directory holding the segments of a recording:
+	// song.mkv is written to song.segments/ until it stops
+	segmentDirSuffix = ".segments"
+
+	// segmentOwnerFile holds the PID of the process writing the segments, so
+	// recovery never touches a recording that is still running
+	segmentOwnerFile = "owner.pid"
+
+	// segmentListFile is the FFmpeg concat list used to join the segments
+	segmentListFile = "segments.txt"
+)
+
+// segmentDir returns the directory holding the segments of an output file
+func segmentDir(outputFile string) string {
+	return strings.TrimSuffix(outputFile, ".mkv") + segmentDirSuffix
+}
+
+// prepareSegments creates an empty segment directory owned by this process
+// and returns the FFmpeg arguments writing segments of the given length into it
+func prepareSegments(outputFile string, duration float64) ([]string, error) {
+	dir := segmentDir(outputFile)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to clear segment directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create segment directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, segmentOwnerFile), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write segment owner: %w", err)
+	}
+
+	return []string{
+		"-f", "segment",
+		"-segment_time", strconv.FormatFloat(duration, 'f', -1, 64),
+		"-segment_format", "matroska",
+		"-reset_timestamps", "0",
+		filepath.Join(dir, "%05d.mkv"),
+	}, nil
+}
+
+// listSegments returns the segment files of a directory in recording order
+func listSegments(dir string) ([]string, error) {
+	segments, err := filepath.Glob(filepath.Join(dir, "*.mkv"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// stitchSegments joins the segments of a recording into its output file
+// without re-encoding, then removes the segment directory. A last segment
+// cut short by a crash is kept up to its last complete block.
+func stitchSegments(outputFile string) error {
+	dir := segmentDir(outputFile)
+	segments, err := listSegments(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list segments: %w", err)
+	}
+	if len(segments) == 0 {
+		return fmt.Errorf("no segments found in %s", dir)
+	}
+
+	var list strings.Builder
+	for _, segment := range segments {
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(filepath.Base(segment), "'", `'\''`))
+	}
+	listFile := filepath.Join(dir, segmentListFile)
+	if err := os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write segment list: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-err_detect", "ignore_err",
+		"-f", "concat", "-safe", "0", "-i", listFile,
+		"-map", "0", "-c", "copy",
+		"-y", outputFile,
+	}
+	slog.Debug("Stitching segments", "command", "ffmpeg "+strings.Join(args, " "))
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to join %d segments: %w (output: %s)", len(segments), err, strings.TrimSpace(string(output)))
+	}
+
+	slog.Info("Segments joined", "output", outputFile, "segments", len(segments))
+	return os.RemoveAll(dir)
+}
+
+// findOrphanedSegments returns the output files whose segments were left
+// behind by a process that is no longer running
+func findOrphanedSegments(directory string) []string {
+	dirs, err := filepath.Glob(filepath.Join(directory, "*"+segmentDirSuffix))
+	if err != nil {
+		return nil
+	}
+
+	var orphaned []string
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if segmentOwnerRunning(dir) {
+			continue
+		}
+		orphaned = append(orphaned, strings.TrimSuffix(dir, segmentDirSuffix)+".mkv")
+	}
+	return orphaned
+}
+
+// segmentOwnerRunning reports whether the process that wrote a segment
+// directory is still alive
+func segmentOwnerRunning(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, segmentOwnerFile))
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	err = syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// RecoverSegments rebuilds a playable file from every recording in directory
+// whose process died before joining its segments, and returns the recovered files
+func RecoverSegments(directory string) []string {
+	var recovered []string
+	for _, outputFile := range findOrphanedSegments(directory) {
+		// A process killed before its first segment leaves nothing to recover
+		if segments, err := listSegments(segmentDir(outputFile)); err == nil && len(segments) == 0 {
+			slog.Warn("Removing empty segment directory", "directory", segmentDir(outputFile))
+			os.RemoveAll(segmentDir(outputFile))
+			continue
+		}
+
+		slog.Warn("Recovering interrupted recording", "output", outputFile)
+		if err := stitchSegments(outputFile); err != nil {
+			slog.Error("Failed to recover interrupted recording", "output", outputFile, "error", err)
+			continue
+		}
+		recovered = append(recovered, outputFile)
+	}
+	return recovered
+}
@@ -0,0 +1,112 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+func TestPrepareSegments(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "song.mkv")
+
+	// Segments left from an earlier attempt are cleared
+	os.MkdirAll(segmentDir(outputFile), 0755)
+	os.WriteFile(filepath.Join(segmentDir(outputFile), "00000.mkv"), []byte("old"), 0644)
+
+	args, err := prepareSegments(outputFile, 5)
+	if err != nil {
+		t.Fatalf("prepareSegments failed: %v", err)
+	}
+
+	expected := "-f segment -segment_time 5 -segment_format matroska -reset_timestamps 0 " + filepath.Join(segmentDir(outputFile), "%05d.mkv")
+	if strings.Join(args, " ") != expected {
+		t.Errorf("Expected %q, got %q", expected, strings.Join(args, " "))
+	}
+	if segments, _ := listSegments(segmentDir(outputFile)); len(segments) != 0 {
+		t.Errorf("Expected old segments to be cleared, got %v", segments)
+	}
+	if !segmentOwnerRunning(segmentDir(outputFile)) {
+		t.Error("Expected the segments to be owned by the running process")
+	}
+}
+
+func TestFindOrphanedSegments(t *testing.T) {
+	dir := t.TempDir()
+	writeOwner := func(name, pid string) {
+		os.MkdirAll(filepath.Join(dir, name), 0755)
+		if pid != "" {
+			os.WriteFile(filepath.Join(dir, name, segmentOwnerFile), []byte(pid), 0644)
+		}
+	}
+
+	writeOwner("running.segments", strconv.Itoa(os.Getpid()))
+	writeOwner("crashed.segments", "999999999")
+	writeOwner("unowned.segments", "")
+	os.WriteFile(filepath.Join(dir, "file.segments"), nil, 0644)
+
+	orphaned := findOrphanedSegments(dir)
+	expected := []string{filepath.Join(dir, "crashed.mkv"), filepath.Join(dir, "unowned.mkv")}
+	if strings.Join(orphaned, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, orphaned)
+	}
+}
+
+func TestRecoverSegments_RemovesEmptyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "song.segments"), 0755)
+
+	if recovered := RecoverSegments(dir); len(recovered) != 0 {
+		t.Errorf("Expected nothing to recover, got %v", recovered)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "song.segments")); !os.IsNotExist(err) {
+		t.Error("Expected the empty segment directory to be removed")
+	}
+}
+
+func TestPipeWireRecorder_JoinsSegmentsOnStop(t *testing.T) {
+	graph := NewMemoryPortGraph("system:capture_1", "Chrome:output_FL", "Chrome:output_FR")
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Segments = &config.SegmentsConfig{Duration: 2}
+
+	capture := rec.startCapture
+	rec.startCapture = func(channels []config.Channel, outputFile string) error {
+		if _, err := rec.outputArgs(outputFile); err != nil {
+			return err
+		}
+		for i := 0; i < 2; i++ {
+			os.WriteFile(filepath.Join(segmentDir(outputFile), "0000"+strconv.Itoa(i)+".mkv"), make([]byte, 1024), 0644)
+		}
+		return capture(channels, "")
+	}
+	var joined []string
+	rec.joinSegments = func(outputFile string) error {
+		joined = append(joined, outputFile)
+		segments, _ := listSegments(segmentDir(outputFile))
+		os.WriteFile(outputFile, make([]byte, 1024*len(segments)), 0644)
+		return os.RemoveAll(segmentDir(outputFile))
+	}
+
+	if err := rec.StartReady("segmented song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	graph.AddPort("system:capture_2")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+
+	_, session := rec.GetStatus()
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if len(joined) != 1 || joined[0] != session.OutputFile {
+		t.Errorf("Expected the segments of %s to be joined once, got %v", session.OutputFile, joined)
+	}
+	if info, err := os.Stat(session.OutputFile); err != nil || info.Size() != 2048 {
+		t.Errorf("Expected the joined recording, got %v (%v)", info, err)
+	}
+}
@@ -0,0 +1,150 @@
+package audio
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// sourceResolver turns the glob, regex and selector sources of a session into
+// the concrete ports present in the graph. A resolved port is kept while it
+// exists, so a second matching device appearing later does not move the channel.
+type sourceResolver struct {
+	mutex    sync.Mutex
+	resolved map[string]string // Source pattern → port it resolved to
+	problems map[string]string // Source pattern → last reported reason it stays unresolved
+}
+
+// reset forgets every resolution, so that a new session starts from the graph
+func (s *sourceResolver) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.resolved = nil
+	s.problems = nil
+}
+
+// resolve matches every pattern source of the channels against a snapshot.
+// A pattern that matches no port, or several, stays unresolved and its
+// channel keeps waiting.
+func (s *sourceResolver) resolve(channels []config.Channel, snapshot *PortSnapshot, graph PortGraph) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.resolved == nil {
+		s.resolved = make(map[string]string)
+		s.problems = make(map[string]string)
+	}
+
+	for _, channel := range channels {
+		for _, source := range channel.Sources {
+			pattern, err := config.ParseSource(source)
+			if err != nil || !pattern.IsPattern() {
+				continue
+			}
+			if port, exists := s.resolved[source]; exists && snapshot.Exists(port) {
+				continue
+			}
+			delete(s.resolved, source)
+
+			port, err := matchSource(pattern, snapshot, graph)
+			if err != nil {
+				if s.problems[source] != err.Error() {
+					slog.Warn("Source pattern unresolved", "channel", channel.Name, "source", source, "error", err)
+					s.problems[source] = err.Error()
+				}
+				continue
+			}
+
+			slog.Info("Source pattern resolved", "channel", channel.Name, "source", source, "port", port)
+			s.resolved[source] = port
+			delete(s.problems, source)
+		}
+	}
+}
+
+// apply returns a copy of the channels with resolved ports in place of their
+// patterns. Unresolved patterns are left as written: no port has that name,
+// so the channel reports waiting.
+func (s *sourceResolver) apply(channels []config.Channel) []config.Channel {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]config.Channel, len(channels))
+	for i, channel := range channels {
+		result[i] = channel
+		if len(s.resolved) == 0 {
+			continue
+		}
+		result[i].Sources = make([]string, len(channel.Sources))
+		for j, source := range channel.Sources {
+			if port, exists := s.resolved[source]; exists {
+				source = port
+			}
+			result[i].Sources[j] = source
+		}
+	}
+	return result
+}
+
+// matchSource returns the single port name of the snapshot matching a pattern.
+// Several clients exposing that same name still count as one match; the
+// channel's duplicate rule picks between them when linking.
+func matchSource(pattern config.SourcePattern, snapshot *PortSnapshot, graph PortGraph) (string, error) {
+	var matches []string
+	seen := make(map[string]bool)
+	for _, port := range snapshot.Ports() {
+		if seen[port] || !pattern.MatchName(port) {
+			continue
+		}
+		seen[port] = true
+
+		if pattern.Kind == config.SourceSelector {
+			selected, err := selectorMatches(pattern, port, graph)
+			if err != nil {
+				return "", err
+			}
+			if !selected {
+				continue
+			}
+		}
+		matches = append(matches, port)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no port matches %s", pattern.Source)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%d ports match %s: %v", len(matches), pattern.Source, matches)
+	}
+}
+
+// selectorMatches reports whether one of the instances of a port has every
+// property required by a selector
+func selectorMatches(pattern config.SourcePattern, port string, graph PortGraph) (bool, error) {
+	instances, canList := graph.(PortInstanceLister)
+	properties, canDescribe := graph.(PortPropertiesLister)
+	if !canList || !canDescribe {
+		return false, fmt.Errorf("source selector %s needs a port graph exposing node properties", pattern.Source)
+	}
+
+	ids, err := instances.ListPortIDs(port)
+	if err != nil {
+		return false, fmt.Errorf("failed to list instances of %s: %w", port, err)
+	}
+	for _, id := range ids {
+		props, err := properties.PortProperties(id)
+		if err != nil {
+			slog.Debug("Failed to read port properties", "port", port, "port_id", id, "error", err)
+			continue
+		}
+		if propertiesMatch(props, pattern.Properties) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
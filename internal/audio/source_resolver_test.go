@@ -0,0 +1,96 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+func TestSourceResolver_ResolvesPatterns(t *testing.T) {
+	graph := NewMemoryPortGraph(
+		"alsa_input.usb-Focusrite_Scarlett_2i2_USB_A123-00.analog-stereo:capture_FR",
+		"alsa_input.usb-Focusrite_Scarlett_2i2_USB_A123-00.analog-stereo:capture_FL",
+		"Chrome:output_FL",
+	)
+	graph.AddPortWithProperties("alsa_input.usb-Behringer_UMC204HD-00.analog-stereo:capture_FR", map[string]string{"device.product.name": "UMC204HD"})
+	channels := []config.Channel{
+		{Name: "guitar", Sources: []string{"alsa_input.usb-Focusrite_Scarlett_2i2_*:capture_FR"}},
+		{Name: "bass", Sources: []string{`{"device.product.name":"UMC204HD","port":"capture_FR"}`}},
+		{Name: "chrome", Sources: []string{"re:^Chrome( \\(\\d+\\))?:output_FL$"}},
+		{Name: "any", Sources: []string{"alsa_input.*:capture_FR"}},
+		{Name: "system", Sources: []string{"system:capture_1"}},
+	}
+
+	var resolver sourceResolver
+	snapshot, _ := TakePortSnapshot(graph)
+	resolver.resolve(channels, snapshot, graph)
+	resolved := resolver.apply(channels)
+
+	expected := []string{
+		"alsa_input.usb-Focusrite_Scarlett_2i2_USB_A123-00.analog-stereo:capture_FR",
+		"alsa_input.usb-Behringer_UMC204HD-00.analog-stereo:capture_FR",
+		"Chrome:output_FL",
+		"alsa_input.*:capture_FR", // Matches two interfaces, so it waits
+		"system:capture_1",
+	}
+	for i, source := range expected {
+		if resolved[i].Sources[0] != source {
+			t.Errorf("Channel %s: expected %s, got %s", channels[i].Name, source, resolved[i].Sources[0])
+		}
+	}
+	if channels[0].Sources[0] != "alsa_input.usb-Focusrite_Scarlett_2i2_*:capture_FR" {
+		t.Errorf("Expected the configured channels to keep their patterns, got %v", channels[0].Sources)
+	}
+}
+
+func TestSourceResolver_KeepsPortWhileItExists(t *testing.T) {
+	graph := NewMemoryPortGraph("Chrome:output_FL")
+	channels := []config.Channel{{Name: "chrome", Sources: []string{"Chrom*:output_FL"}}}
+
+	var resolver sourceResolver
+	snapshot, _ := TakePortSnapshot(graph)
+	resolver.resolve(channels, snapshot, graph)
+
+	// A second match does not move the channel away from its port
+	graph.AddPort("Chromium:output_FL")
+	snapshot, _ = TakePortSnapshot(graph)
+	resolver.resolve(channels, snapshot, graph)
+	if source := resolver.apply(channels)[0].Sources[0]; source != "Chrome:output_FL" {
+		t.Errorf("Expected Chrome:output_FL to stay resolved, got %s", source)
+	}
+
+	// Once it is gone, the remaining match takes over
+	graph.RemovePort("Chrome:output_FL")
+	snapshot, _ = TakePortSnapshot(graph)
+	resolver.resolve(channels, snapshot, graph)
+	if source := resolver.apply(channels)[0].Sources[0]; source != "Chromium:output_FL" {
+		t.Errorf("Expected Chromium:output_FL after Chrome left, got %s", source)
+	}
+}
+
+func TestPipeWireRecorder_RecordsPatternSources(t *testing.T) {
+	graph := NewMemoryPortGraph()
+	rec := newGraphRecorder(t, graph)
+	defer rec.Cleanup()
+	rec.cfg.Channels[0].Sources = []string{"alsa_input.usb-Focusrite_Scarlett_2i2_*:capture_FR"}
+	rec.cfg.Channels[1].Sources = []string{"re:^Chrome.*:output_FL$", "re:^Chrome.*:output_FR$"}
+
+	if err := rec.StartReady("pattern song"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	if states := rec.GetChannelStatus(); states["guitar"].State != ChannelWaiting {
+		t.Fatalf("Expected guitar to wait for its interface, got %s", states["guitar"].State)
+	}
+
+	graph.AddPort("alsa_input.usb-Focusrite_Scarlett_2i2_USB_B999-00.analog-stereo:capture_FR")
+	graph.AddPort("Chrome (2):output_FL")
+	graph.AddPort("Chrome (2):output_FR")
+	waitForStatusWithin(t, rec, StatusRecording, 3*time.Second)
+	waitForLink(t, graph, "alsa_input.usb-Focusrite_Scarlett_2i2_USB_B999-00.analog-stereo:capture_FR", "jamcapture_guitar:input_1")
+	waitForLink(t, graph, "Chrome (2):output_FR", "jamcapture_chrome:input_2")
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+}
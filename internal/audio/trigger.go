@@ -0,0 +1,199 @@
+package audio
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// preRollBuffer is a fixed-size ring keeping the most recent audio of one channel
+type preRollBuffer struct {
+	data  []byte
+	start int
+	size  int
+}
+
+// newPreRollBuffer creates a ring holding seconds of raw float32 audio
+func newPreRollBuffer(seconds float64, channels, sampleRate int) *preRollBuffer {
+	frameSize := channels * 4
+	frames := int(seconds * float64(sampleRate))
+	return &preRollBuffer{data: make([]byte, frames*frameSize)}
+}
+
+// Write appends audio, overwriting the oldest bytes once the ring is full
+func (b *preRollBuffer) Write(p []byte) (int, error) {
+	written := len(p)
+	capacity := len(b.data)
+	if capacity == 0 {
+		return written, nil
+	}
+
+	// Only the tail of a block larger than the ring can survive
+	if len(p) >= capacity {
+		copy(b.data, p[len(p)-capacity:])
+		b.start, b.size = 0, capacity
+		return written, nil
+	}
+
+	end := (b.start + b.size) % capacity
+	n := copy(b.data[end:], p)
+	copy(b.data, p[n:])
+
+	b.size += len(p)
+	if b.size > capacity {
+		b.start = (b.start + b.size - capacity) % capacity
+		b.size = capacity
+	}
+	return written, nil
+}
+
+// Bytes returns the buffered audio, oldest first
+func (b *preRollBuffer) Bytes() []byte {
+	result := make([]byte, 0, b.size)
+	if b.size == 0 {
+		return result
+	}
+
+	end := b.start + b.size
+	if end <= len(b.data) {
+		return append(result, b.data[b.start:end]...)
+	}
+	result = append(result, b.data[b.start:]...)
+	return append(result, b.data[:end-len(b.data)]...)
+}
+
+// triggerTap receives the raw samples of one channel of an armed session.
+// Until the trigger fires they are kept in the pre-roll ring; when it fires
+// the ring is flushed to the encoder, followed by the live audio.
+type triggerTap struct {
+	mutex     sync.Mutex
+	name      string
+	frameSize int
+	preRoll   *preRollBuffer
+	out       io.WriteCloser
+	failed    bool
+
+	// frames counts the frames received since arming. A scheduled split
+	// moves the output to next once frames reaches splitAt.
+	frames  int64
+	next    io.WriteCloser
+	splitAt int64
+}
+
+// newTriggerTap creates a tap keeping seconds of pre-roll
+func newTriggerTap(name string, seconds float64, channels, sampleRate int) *triggerTap {
+	return &triggerTap{
+		name:      name,
+		frameSize: channels * 4,
+		preRoll:   newPreRollBuffer(seconds, channels, sampleRate),
+	}
+}
+
+// Write never fails, so that a broken encoder cannot stall the capture
+func (t *triggerTap) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	written := len(p)
+	if t.next != nil {
+		cut := max((t.splitAt-t.frames)*int64(t.frameSize), 0)
+		if cut <= int64(len(p)) {
+			t.forward(p[:cut])
+			t.frames += cut / int64(t.frameSize)
+			t.switchOutput()
+			p = p[cut:]
+		}
+	}
+
+	t.forward(p)
+	t.frames += int64(len(p) / t.frameSize)
+	return written, nil
+}
+
+// forward writes audio to the pre-roll ring or, once fired, to the encoder
+func (t *triggerTap) forward(p []byte) {
+	if t.out == nil {
+		t.preRoll.Write(p)
+		return
+	}
+
+	if !t.failed {
+		if _, err := t.out.Write(p); err != nil {
+			slog.Error("Failed to write audio to encoder", "channel", t.name, "error", err)
+			t.failed = true
+		}
+	}
+}
+
+// switchOutput closes the current output and continues on the scheduled one
+func (t *triggerTap) switchOutput() {
+	if t.out != nil {
+		t.out.Close()
+	}
+	t.out, t.next = t.next, nil
+	t.failed = false
+}
+
+// split schedules the output to move to next at a frame position. Every
+// channel of a session splits at the same frame, so the tracks of the new
+// take stay sample-aligned.
+func (t *triggerTap) split(next io.WriteCloser, at int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.next = next
+	t.splitAt = at
+}
+
+// position returns the number of frames received since arming
+func (t *triggerTap) position() int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.frames
+}
+
+// fire flushes the pre-roll to out and routes the following audio to it
+func (t *triggerTap) fire(out io.WriteCloser) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.out = out
+	buffered := t.preRoll.Bytes()
+	t.preRoll = nil
+
+	if _, err := out.Write(buffered); err != nil {
+		t.failed = true
+		return err
+	}
+	return nil
+}
+
+// close ends the encoder inputs, if the trigger fired
+func (t *triggerTap) close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// A split that was never reached still holds the next take's input
+	if t.next != nil {
+		t.next.Close()
+		t.next = nil
+	}
+
+	if t.out == nil {
+		return nil
+	}
+	return t.out.Close()
+}
+
+// signalDetected reports whether the trigger channel reached the threshold
+func signalDetected(levels []ChannelLevel, trigger config.TriggerConfig) bool {
+	for _, level := range levels {
+		if level.Name == trigger.Channel {
+			return level.Peak >= trigger.Threshold
+		}
+	}
+	return false
+}
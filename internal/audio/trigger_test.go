@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// bufferCloser collects what an encoder input receives
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestPreRollBuffer_KeepsMostRecentAudio(t *testing.T) {
+	// 2 mono frames of float32 = 8 bytes
+	ring := newPreRollBuffer(2, 1, 1)
+
+	ring.Write([]byte{1, 2, 3})
+	if got := ring.Bytes(); !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Errorf("Expected partial fill, got %v", got)
+	}
+
+	ring.Write([]byte{4, 5, 6, 7, 8, 9, 10})
+	if got := ring.Bytes(); !bytes.Equal(got, []byte{3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Errorf("Expected the last 8 bytes after wrapping, got %v", got)
+	}
+
+	ring.Write([]byte{11, 12, 13, 14, 15, 16, 17, 18, 19})
+	if got := ring.Bytes(); !bytes.Equal(got, []byte{12, 13, 14, 15, 16, 17, 18, 19}) {
+		t.Errorf("Expected the tail of an oversized write, got %v", got)
+	}
+
+	empty := newPreRollBuffer(0, 1, 48000)
+	empty.Write([]byte{1, 2, 3, 4})
+	if got := empty.Bytes(); len(got) != 0 {
+		t.Errorf("Expected no pre-roll without a length, got %v", got)
+	}
+}
+
+func TestTriggerTap_FlushesPreRollThenLiveAudio(t *testing.T) {
+	tap := newTriggerTap("guitar", 1, 1, 2) // 8 bytes of pre-roll
+	tap.Write([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+
+	out := &bufferCloser{}
+	if err := tap.fire(out); err != nil {
+		t.Fatalf("fire failed: %v", err)
+	}
+	tap.Write([]byte{11, 12})
+	tap.close()
+
+	if got := out.Bytes(); !bytes.Equal(got, []byte{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}) {
+		t.Errorf("Expected pre-roll followed by live audio, got %v", got)
+	}
+	if !out.closed {
+		t.Error("Expected the encoder input to be closed")
+	}
+}
+
+func TestTriggerTap_SplitSwitchesOutputAtFrame(t *testing.T) {
+	tap := newTriggerTap("guitar", 0, 1, 48000) // 4-byte frames
+	first, second := &bufferCloser{}, &bufferCloser{}
+	tap.fire(first)
+
+	tap.Write([]byte{1, 2, 3, 4})
+	tap.split(second, 2)
+	tap.Write([]byte{5, 6, 7, 8, 9, 10, 11, 12})
+
+	if got := first.Bytes(); !bytes.Equal(got, []byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Errorf("Expected the frames before the split in the first take, got %v", got)
+	}
+	if !first.closed {
+		t.Error("Expected the first take input to be closed at the split")
+	}
+	if got := second.Bytes(); !bytes.Equal(got, []byte{9, 10, 11, 12}) {
+		t.Errorf("Expected the frames from the split in the second take, got %v", got)
+	}
+	if tap.position() != 3 {
+		t.Errorf("Expected 3 frames received, got %d", tap.position())
+	}
+}
+
+func TestSignalDetected(t *testing.T) {
+	trigger := config.TriggerConfig{Mode: config.TriggerSignal, Channel: "guitar", Threshold: -30}
+	levels := []ChannelLevel{
+		{Name: "chrome", Peak: -6},
+		{Name: "guitar", Peak: -45},
+	}
+	if signalDetected(levels, trigger) {
+		t.Error("Expected a loud backing track not to trigger the guitar channel")
+	}
+
+	levels[1].Peak = -12
+	if !signalDetected(levels, trigger) {
+		t.Error("Expected guitar above the threshold to trigger")
+	}
+}
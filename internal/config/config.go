@@ -1,12 +1,20 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
@@ -20,14 +28,23 @@ type ChannelDefinition struct {
 	AudioMode string   `mapstructure:"audioMode" yaml:"audioMode"`
 	Type      string   `mapstructure:"type" yaml:"type"`
 	Volume    float64  `mapstructure:"volume" yaml:"volume"`
-	Delay     int      `mapstructure:"delay" yaml:"delay"`
+	Delay     float64  `mapstructure:"delay" yaml:"delay"`                                     // Milliseconds, fractional and signed: a negative delay trims the start
+	DelaySamples int   `mapstructure:"delay_samples,omitempty" yaml:"delay_samples,omitempty"` // Exact delay in samples, instead of 'delay'
+	Pan       float64  `mapstructure:"pan,omitempty" yaml:"pan,omitempty"`                     // -1 (left) to 1 (right), centred when 0
+	Width     *float64 `mapstructure:"width,omitempty" yaml:"width,omitempty"`                 // Stereo width of stereo and surround sources: 0 (mono) to 2, unchanged when 1
+	Effects   []Effect `mapstructure:"effects,omitempty" yaml:"effects,omitempty"`             // Applied in order before the volume
+	Duplicates *DuplicateRule `mapstructure:"duplicates,omitempty" yaml:"duplicates,omitempty"`
 }
 
 type ChannelReference struct {
 	Ref    string   `mapstructure:"ref" yaml:"ref"`
 	Name   string   `mapstructure:"name,omitempty" yaml:"name,omitempty"`     // Optional name override
 	Volume *float64 `mapstructure:"volume,omitempty" yaml:"volume,omitempty"` // Surcharge autorisée
-	Delay  *int     `mapstructure:"delay,omitempty" yaml:"delay,omitempty"`   // Surcharge autorisée
+	Delay  *float64 `mapstructure:"delay,omitempty" yaml:"delay,omitempty"`   // Surcharge autorisée
+	DelaySamples *int `mapstructure:"delay_samples,omitempty" yaml:"delay_samples,omitempty"` // Surcharge autorisée, replaces 'delay'
+	Pan    *float64 `mapstructure:"pan,omitempty" yaml:"pan,omitempty"`       // Surcharge autorisée
+	Width  *float64 `mapstructure:"width,omitempty" yaml:"width,omitempty"`   // Surcharge autorisée
+	Effects []Effect `mapstructure:"effects,omitempty" yaml:"effects,omitempty"` // Surcharge autorisée, replaces the whole chain
 }
 
 type GlobalsConfig struct {
@@ -53,6 +70,13 @@ type Config struct {
 	Channels []Channel   `mapstructure:"channels" yaml:"channels"`
 	Output   OutputConfig `mapstructure:"output" yaml:"output"`
 	AutoMix  bool         `mapstructure:"auto_mix" yaml:"auto_mix"`
+	Trigger  *TriggerConfig `mapstructure:"trigger,omitempty" yaml:"trigger,omitempty"`
+	AutoSplit *AutoSplitConfig `mapstructure:"auto_split,omitempty" yaml:"auto_split,omitempty"`
+	Ready    *ReadyConfig   `mapstructure:"ready,omitempty" yaml:"ready,omitempty"`
+	Segments *SegmentsConfig `mapstructure:"segments,omitempty" yaml:"segments,omitempty"`
+	Capture  *CaptureConfig  `mapstructure:"capture,omitempty" yaml:"capture,omitempty"`
+	Metronome *MetronomeConfig `mapstructure:"metronome,omitempty" yaml:"metronome,omitempty"`
+	Align    *AlignConfig    `mapstructure:"align,omitempty" yaml:"align,omitempty"`
 
 	// Internal field to track inheritance information for info command
 	Inheritance *InheritanceInfo `mapstructure:"-" yaml:"-"`
@@ -63,6 +87,13 @@ type ConfigProfile struct {
 	Channels []ChannelReference `mapstructure:"channels" yaml:"channels"`
 	Output   OutputConfig       `mapstructure:"output" yaml:"output"`
 	AutoMix  bool               `mapstructure:"auto_mix" yaml:"auto_mix"`
+	Trigger  *TriggerConfig     `mapstructure:"trigger,omitempty" yaml:"trigger,omitempty"`
+	AutoSplit *AutoSplitConfig  `mapstructure:"auto_split,omitempty" yaml:"auto_split,omitempty"`
+	Ready    *ReadyConfig       `mapstructure:"ready,omitempty" yaml:"ready,omitempty"`
+	Segments *SegmentsConfig    `mapstructure:"segments,omitempty" yaml:"segments,omitempty"`
+	Capture  *CaptureConfig     `mapstructure:"capture,omitempty" yaml:"capture,omitempty"`
+	Metronome *MetronomeConfig  `mapstructure:"metronome,omitempty" yaml:"metronome,omitempty"`
+	Align    *AlignConfig       `mapstructure:"align,omitempty" yaml:"align,omitempty"`
 
 	// Internal field to track inheritance information for info command
 	Inheritance *InheritanceInfo `mapstructure:"-" yaml:"-"`
@@ -87,18 +118,306 @@ type InheritanceInfo struct {
 }
 
 type AudioConfig struct {
-	SampleRate int    `mapstructure:"sample_rate" yaml:"sample_rate"`
-	Interface  string `mapstructure:"interface" yaml:"interface"` // "jack" interface (deprecated, use Backend)
-	Backend    string `mapstructure:"backend" yaml:"backend"`     // "pipewire", "auto"
+	SampleRate int         `mapstructure:"sample_rate" yaml:"sample_rate"`
+	Interface  string      `mapstructure:"interface" yaml:"interface"` // "jack" interface (deprecated, use Backend)
+	Backend    string      `mapstructure:"backend" yaml:"backend"`     // "pipewire", "fake", "auto"
+	Fake       *FakeConfig `mapstructure:"fake,omitempty" yaml:"fake,omitempty"`
+	Quantum    int         `mapstructure:"quantum,omitempty" yaml:"quantum,omitempty"`         // Frames per PipeWire cycle (default 256)
+	ClockCheck string      `mapstructure:"clock_check,omitempty" yaml:"clock_check,omitempty"` // "warn" (default), "refuse" or "off" when the graph runs at another rate
+}
+
+// Clock check policies, applied when the PipeWire graph cannot run at the configured sample rate
+const (
+	ClockCheckWarn   = "warn"   // Log a warning and record anyway
+	ClockCheckRefuse = "refuse" // Refuse to start the session
+	ClockCheckOff    = "off"    // Do not query the graph
+)
+
+const (
+	// DefaultSampleRate is the capture sample rate when none is configured
+	DefaultSampleRate = 48000
+
+	// DefaultQuantum is the PipeWire buffer size in frames when none is configured
+	DefaultQuantum = 256
+
+	// MinQuantum and MaxQuantum bound the buffer sizes PipeWire accepts
+	MinQuantum = 16
+	MaxQuantum = 8192
+)
+
+// FakeConfig configures the hardware-free "fake" backend used for testing
+type FakeConfig struct {
+	Ports   []string     `mapstructure:"ports" yaml:"ports"`     // Ports present at startup (default: all configured sources)
+	Signals []FakeSignal `mapstructure:"signals" yaml:"signals"` // Signal fed into each port
+}
+
+// FakeSignal describes the audio a fake port produces: a WAV file or a generated tone
+type FakeSignal struct {
+	Port      string  `mapstructure:"port" yaml:"port"`
+	File      string  `mapstructure:"file,omitempty" yaml:"file,omitempty"`           // WAV file (looped)
+	Frequency float64 `mapstructure:"frequency,omitempty" yaml:"frequency,omitempty"` // Sine tone in Hz (default 440)
+}
+
+// Recording trigger modes
+const (
+	TriggerSources = "sources" // Start as soon as every source is present (default)
+	TriggerSignal  = "signal"  // Start once a channel crosses a level threshold
+	TriggerManual  = "manual"  // Start on an explicit request
+)
+
+const (
+	// DefaultTriggerThreshold is the signal trigger level used when none is configured
+	DefaultTriggerThreshold = -40.0
+
+	// MaxPreRoll bounds the audio buffered per channel while waiting for the trigger
+	MaxPreRoll = 30.0
+
+	// DefaultSilenceThreshold is the auto-split silence level used when none is configured
+	DefaultSilenceThreshold = -50.0
+)
+
+// Duplicate source policies of a READY session
+const (
+	DuplicatesBlock  = "block"  // Refuse to record until only one client exposes the source (default)
+	DuplicatesNewest = "newest" // Link the most recently created port
+	DuplicatesOldest = "oldest" // Link the port that was created first
+
+	// Channel-only policies
+	DuplicatesMatch = "match" // Link the port whose node has the configured properties
+	DuplicatesAll   = "all"   // Link every port, mixed into the same capture input
+)
+
+const (
+	// ReadyTimeoutNever keeps a READY session waiting for its sources indefinitely
+	ReadyTimeoutNever = "never"
+
+	// DefaultReadyTimeout is how long a READY session waits for its sources by default
+	DefaultReadyTimeout = 30 * time.Second
+
+	// DefaultReadyPollInterval is how often sources are checked by default when
+	// the port graph cannot push events
+	DefaultReadyPollInterval = 500 * time.Millisecond
+
+	// MinReadyPollInterval keeps polling from listing the port graph in a busy loop
+	MinReadyPollInterval = 50 * time.Millisecond
+)
+
+// AudioModeGroup records any number of sources as one track of discrete
+// channels, such as the microphones of a drum kit
+const AudioModeGroup = "group"
+
+// MaxChannelGroupSize is the most sources a group can hold, the most channels FLAC stores
+const MaxChannelGroupSize = 8
+
+// channelLayouts maps the named audioMode layouts to their number of channels.
+// Names are FFmpeg channel layouts; their sources are listed in FFmpeg's
+// channel order, e.g. FL, FR, FC, LFE, BL, BR for 5.1.
+var channelLayouts = map[string]int{
+	"mono":   1,
+	"stereo": 2,
+	"2.1":    3,
+	"quad":   4,
+	"5.0":    5,
+	"5.1":    6,
+	"7.1":    8,
+}
+
+// audioModes lists the accepted audioMode values, as shown in errors
+const audioModes = "mono, stereo, 2.1, quad, 5.0, 5.1, 7.1 or group"
+
+// audioModeSources returns how many sources an audioMode takes, and false if
+// the audioMode is unknown
+func audioModeSources(mode string) (min, max int, valid bool) {
+	if mode == AudioModeGroup {
+		return 1, MaxChannelGroupSize, true
+	}
+	count, valid := channelLayouts[mode]
+	return count, count, valid
+}
+
+// sourceCountText describes the number of sources an audioMode takes
+func sourceCountText(min, max int) string {
+	if min == max {
+		return fmt.Sprintf("exactly %d source(s)", min)
+	}
+	return fmt.Sprintf("between %d and %d sources", min, max)
+}
+
+// ChannelCount returns the number of audio channels in the channel's track
+func (c Channel) ChannelCount() int {
+	if count, named := channelLayouts[c.AudioMode]; named {
+		return count
+	}
+	if len(c.Sources) == 0 {
+		return 1
+	}
+	return len(c.Sources)
+}
+
+// ChannelLayout returns the FFmpeg channel layout of the channel's track, or
+// "" for a group of discrete channels
+func (c Channel) ChannelLayout() string {
+	if _, named := channelLayouts[c.AudioMode]; named {
+		return c.AudioMode
+	}
+	if c.AudioMode == "" && len(c.Sources) <= 2 {
+		if len(c.Sources) == 2 {
+			return "stereo"
+		}
+		return "mono"
+	}
+	return ""
+}
+
+// ChannelTypePlayback is the type of the channel recording the backing track
+// jamcapture plays itself. It has no sources: the recorder links its player.
+const ChannelTypePlayback = "playback"
+
+const (
+	// MaxChannelDelay bounds a channel's delay either way, in milliseconds
+	MaxChannelDelay = 10000
+
+	// MaxChannelDelaySamples bounds 'delay_samples', 10 seconds at 192 kHz
+	MaxChannelDelaySamples = 1920000
+
+	// MaxChannelWidth doubles the side of a stereo source
+	MaxChannelWidth = 2.0
+)
+
+// DefaultCaptureCodec keeps the multitrack recording lossless
+const DefaultCaptureCodec = "flac"
+
+// MaxFLACCompressionLevel is the highest compression level of FFmpeg's FLAC encoder
+const MaxFLACCompressionLevel = 12
+
+// captureCodecs lists the codecs FFmpeg can write losslessly to Matroska and
+// the bit depths it encodes each of them at
+var captureCodecs = map[string][]int{
+	"flac":      {16, 24},
+	"pcm_s16le": {16},
+	"pcm_s24le": {24},
+	"pcm_s32le": {32},
+	"pcm_f32le": {32},
+}
+
+const (
+	// DefaultSegmentDuration is the length of a segment in seconds by default
+	DefaultSegmentDuration = 10.0
+
+	// MinSegmentDuration keeps FFmpeg from opening a new file several times a second
+	MinSegmentDuration = 1.0
+)
+
+// MetronomeConfig plays a click while recording, and optionally counts the
+// take in and records the click as its own track
+type MetronomeConfig struct {
+	BPM           float64 `mapstructure:"bpm" yaml:"bpm"`                                           // Beats per minute, counted in beats of the time signature
+	TimeSignature string  `mapstructure:"time_signature,omitempty" yaml:"time_signature,omitempty"` // e.g. "4/4" (default), "3/4", "7/8"
+	CountIn       int     `mapstructure:"count_in,omitempty" yaml:"count_in,omitempty"`             // Bars clicked before the take starts on the downbeat
+	Volume        float64 `mapstructure:"volume,omitempty" yaml:"volume,omitempty"`                 // Click level from 0 to 1 (default 0.5)
+	Target        string  `mapstructure:"target,omitempty" yaml:"target,omitempty"`                 // PipeWire node the click plays to, e.g. the headphones (default output when empty)
+	Record        bool    `mapstructure:"record,omitempty" yaml:"record,omitempty"`                 // Record the click as a "metronome" track, left out of the mix
+}
+
+const (
+	// DefaultTimeSignature is the time signature of a metronome that sets none
+	DefaultTimeSignature = "4/4"
+
+	// DefaultMetronomeVolume keeps the click below a typical input level
+	DefaultMetronomeVolume = 0.5
+
+	// MetronomeChannel names the track recording the click
+	MetronomeChannel = "metronome"
+
+	// MinBPM and MaxBPM bound the tempo of the metronome
+	MinBPM = 20.0
+	MaxBPM = 400.0
+
+	// MaxCountIn bounds the bars counted before a take
+	MaxCountIn = 8
+
+	// DefaultAlignMaxOffset covers Bluetooth and browser latencies
+	DefaultAlignMaxOffset = 500
+
+	// MaxAlignOffset bounds the search, the correlation grows with it
+	MaxAlignOffset = 5000
+
+	// DefaultAlignMinConfidence rejects offsets found in unrelated tracks
+	DefaultAlignMinConfidence = 0.1
+)
+
+// AlignConfig estimates, at mix time, how late each monitor track is on a
+// reference input track and delays it by what was measured instead of its
+// configured delay
+type AlignConfig struct {
+	Reference     string  `mapstructure:"reference,omitempty" yaml:"reference,omitempty"`           // Input channel the monitors are aligned to (first input when empty)
+	MaxOffset     int     `mapstructure:"max_offset,omitempty" yaml:"max_offset,omitempty"`         // Largest offset searched either way, in milliseconds (default 500)
+	MinConfidence float64 `mapstructure:"min_confidence,omitempty" yaml:"min_confidence,omitempty"` // Below this match from 0 to 1 the configured delay is kept (default 0.1)
+}
+
+// TriggerConfig controls when a READY session starts recording
+type TriggerConfig struct {
+	Mode      string  `mapstructure:"mode" yaml:"mode"`                               // "sources" (default), "signal", "manual"
+	Channel   string  `mapstructure:"channel,omitempty" yaml:"channel,omitempty"`     // Channel watched in "signal" mode
+	Threshold float64 `mapstructure:"threshold,omitempty" yaml:"threshold,omitempty"` // dBFS level starting a "signal" take (default -40)
+	PreRoll   float64 `mapstructure:"pre_roll,omitempty" yaml:"pre_roll,omitempty"`   // Seconds kept from before the trigger
+}
+
+// AutoSplitConfig closes takes, or the whole recording, once every input
+// channel has been silent for long enough
+type AutoSplitConfig struct {
+	Silence   float64 `mapstructure:"silence,omitempty" yaml:"silence,omitempty"`       // Seconds of silence that close the take and start the next one (0 = never split)
+	Threshold float64 `mapstructure:"threshold,omitempty" yaml:"threshold,omitempty"`   // dBFS below which an input channel is silent (default -50)
+	StopAfter float64 `mapstructure:"stop_after,omitempty" yaml:"stop_after,omitempty"` // Minutes of silence that stop the recording (0 = never stop)
+}
+
+// ReadyConfig controls how a READY session waits for its sources
+type ReadyConfig struct {
+	Timeout      string `mapstructure:"timeout,omitempty" yaml:"timeout,omitempty"`             // Duration such as "30s" or "10m", or "never" (default 30s)
+	PollInterval string `mapstructure:"poll_interval,omitempty" yaml:"poll_interval,omitempty"` // Duration between source checks without port events (default 500ms)
+	Duplicates   string `mapstructure:"duplicates,omitempty" yaml:"duplicates,omitempty"`       // "block" (default), "newest", "oldest"
+}
+
+// CaptureConfig selects how the tracks of the multitrack MKV are encoded,
+// independently of the mixdown format set in output
+type CaptureConfig struct {
+	Codec            string `mapstructure:"codec,omitempty" yaml:"codec,omitempty"`                         // "flac" (default), "pcm_s16le", "pcm_s24le", "pcm_s32le", "pcm_f32le"
+	BitDepth         int    `mapstructure:"bit_depth,omitempty" yaml:"bit_depth,omitempty"`                 // 16 or 24 for flac (default: FFmpeg's choice), implied by PCM codecs
+	CompressionLevel *int   `mapstructure:"compression_level,omitempty" yaml:"compression_level,omitempty"` // FLAC compression from 0 (fastest) to 12 (smallest)
+}
+
+// SegmentsConfig writes a recording as short Matroska segments that are
+// joined when it stops, so a crash loses at most the segment being written
+type SegmentsConfig struct {
+	Duration float64 `mapstructure:"duration,omitempty" yaml:"duration,omitempty"` // Seconds per segment (default 10)
+}
+
+// DuplicateRule picks which client to record when several expose a
+// channel's source, such as two browser windows playing at once
+type DuplicateRule struct {
+	Policy string          `mapstructure:"policy" yaml:"policy"`                   // "block", "newest", "oldest", "match", "all"
+	Match  []PropertyMatch `mapstructure:"match,omitempty" yaml:"match,omitempty"` // Node properties the linked port must have ("match")
+}
+
+// PropertyMatch requires a PipeWire node property, such as media.name or
+// application.process.id, to have a value
+type PropertyMatch struct {
+	Property string `mapstructure:"property" yaml:"property"`
+	Value    string `mapstructure:"value" yaml:"value"`
 }
 
 type Channel struct {
 	Name      string   `mapstructure:"name" yaml:"name"`
-	Sources   []string `mapstructure:"sources" yaml:"sources"`   // Ordered list: mono=[source], stereo=[left,right]
-	AudioMode string   `mapstructure:"audioMode" yaml:"audioMode"` // "mono" (default), "stereo"
-	Type      string   `mapstructure:"type" yaml:"type"`         // "input", "monitor"
+	Sources   []string `mapstructure:"sources" yaml:"sources"`   // Ordered list: mono=[source], stereo=[left,right], layouts in FFmpeg channel order
+	AudioMode string   `mapstructure:"audioMode" yaml:"audioMode"` // "mono" (default), "stereo", "2.1", "quad", "5.0", "5.1", "7.1", "group"
+	Type      string   `mapstructure:"type" yaml:"type"`         // "input", "monitor", "playback"
 	Volume    float64  `mapstructure:"volume" yaml:"volume"`
-	Delay     int      `mapstructure:"delay" yaml:"delay"`
+	Delay     float64  `mapstructure:"delay" yaml:"delay"`                                     // Milliseconds, fractional and signed: a negative delay trims the start
+	DelaySamples int   `mapstructure:"delay_samples,omitempty" yaml:"delay_samples,omitempty"` // Exact delay in samples, replaces 'delay' when set
+	Pan       float64  `mapstructure:"pan,omitempty" yaml:"pan,omitempty"`                     // -1 (left) to 1 (right), centred when 0
+	Width     *float64 `mapstructure:"width,omitempty" yaml:"width,omitempty"`                 // Stereo width from 0 (mono) to 2, unchanged when nil
+	Effects   []Effect `mapstructure:"effects,omitempty" yaml:"effects,omitempty"`             // EQ and dynamics applied in order before the volume
+	Duplicates *DuplicateRule `mapstructure:"duplicates,omitempty" yaml:"duplicates,omitempty"` // Which client to record when several expose a source
 }
 
 
@@ -173,6 +492,15 @@ func LoadWithProfile(configFile, profile string) (*Config, error) {
 		if selectedConfig.Audio.Interface == "" {
 			selectedConfig.Audio.Interface = rootConfig.Audio.Interface
 		}
+		if selectedConfig.Audio.Fake == nil {
+			selectedConfig.Audio.Fake = rootConfig.Audio.Fake
+		}
+		if selectedConfig.Audio.Quantum == 0 {
+			selectedConfig.Audio.Quantum = rootConfig.Audio.Quantum
+		}
+		if selectedConfig.Audio.ClockCheck == "" {
+			selectedConfig.Audio.ClockCheck = rootConfig.Audio.ClockCheck
+		}
 	}
 
 	// Apply global output settings as base if they exist
@@ -215,6 +543,37 @@ func LoadWithProfile(configFile, profile string) (*Config, error) {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
 
+	// The trigger may be inherited from the default profile, so check it
+	// against the channels that are actually recorded
+	channelNames := make([]string, len(selectedConfig.Channels))
+	for i, channel := range selectedConfig.Channels {
+		channelNames[i] = channel.Name
+	}
+	if err := validateTrigger(selectedConfig.Trigger, channelNames); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	// Silence is only measured on input channels
+	if selectedConfig.AutoSplit != nil {
+		hasInput := false
+		for _, channel := range selectedConfig.Channels {
+			if channel.Type == "input" {
+				hasInput = true
+				break
+			}
+		}
+		if !hasInput {
+			return nil, fmt.Errorf("config validation failed: auto_split: requires at least one input channel")
+		}
+	}
+
+	if err := validateMetronomeSession(selectedConfig); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	if err := validateAlignSession(selectedConfig); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
 	return selectedConfig, nil
 }
 
@@ -249,6 +608,84 @@ func UpdateActiveConfig(configFile, newActiveConfig string) error {
 	return nil
 }
 
+// SaveChannelDelay writes the delay of a profile's channel to the config
+// file: into the channel reference when it already overrides the delay,
+// otherwise into the channel definition it refers to. It returns the
+// definition ID or profile that was updated.
+func SaveChannelDelay(configFile, profile, channel string, delay float64) (string, error) {
+	if configFile == "" {
+		return "", fmt.Errorf("no config file specified")
+	}
+	if math.Abs(delay) > MaxChannelDelay {
+		return "", fmt.Errorf("delay must be between -%d and %d ms, got: %g", MaxChannelDelay, MaxChannelDelay, delay)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	if err := v.ReadInConfig(); err != nil {
+		return "", fmt.Errorf("error reading config file %s: %w", configFile, err)
+	}
+
+	if profile == "" {
+		profile = v.GetString("active_config")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	// A profile without channels records those of the default profile
+	referencesKey := "configs." + profile + ".channels"
+	references, _ := v.Get(referencesKey).([]interface{})
+	if len(references) == 0 {
+		referencesKey = "configs.default.channels"
+		references, _ = v.Get(referencesKey).([]interface{})
+	}
+
+	updated := ""
+	for _, item := range references {
+		reference, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref, _ := reference["ref"].(string)
+		name, _ := reference["name"].(string)
+		if name == "" {
+			name = ref
+		}
+		if name != channel {
+			continue
+		}
+
+		// The delay in milliseconds replaces one in samples
+		_, overridden := reference["delay"]
+		if _, inSamples := reference["delay_samples"]; overridden || inSamples {
+			reference["delay"] = delay
+			delete(reference, "delay_samples")
+			v.Set(referencesKey, references)
+			updated = "profile '" + profile + "'"
+		} else {
+			definitions, _ := v.Get("definitions.channels").([]interface{})
+			for _, item := range definitions {
+				if definition, ok := item.(map[string]interface{}); ok && definition["id"] == ref {
+					definition["delay"] = delay
+					delete(definition, "delay_samples")
+					v.Set("definitions.channels", definitions)
+					updated = "definition '" + ref + "'"
+				}
+			}
+		}
+		break
+	}
+	if updated == "" {
+		return "", fmt.Errorf("channel '%s' not found in profile '%s'", channel, profile)
+	}
+
+	if err := v.WriteConfig(); err != nil {
+		return "", fmt.Errorf("error writing config file %s: %w", configFile, err)
+	}
+	return updated, nil
+}
+
 // convertProfileToConfig converts a ConfigProfile to Config by resolving channel references
 func convertProfileToConfig(profile *ConfigProfile, definitions *DefinitionsConfig) (*Config, error) {
 	if profile == nil {
@@ -259,6 +696,13 @@ func convertProfileToConfig(profile *ConfigProfile, definitions *DefinitionsConf
 		Audio:   profile.Audio,
 		Output:  profile.Output,
 		AutoMix: profile.AutoMix,
+		Trigger: profile.Trigger,
+		AutoSplit: profile.AutoSplit,
+		Ready:   profile.Ready,
+		Segments: profile.Segments,
+		Capture: profile.Capture,
+		Metronome: profile.Metronome,
+		Align:   profile.Align,
 		Inheritance: &InheritanceInfo{
 			Channels: make(map[string]struct {
 				Source string
@@ -304,6 +748,11 @@ func convertProfileToConfig(profile *ConfigProfile, definitions *DefinitionsConf
 			Type:      definition.Type,
 			Volume:    definition.Volume,
 			Delay:     definition.Delay,
+			DelaySamples: definition.DelaySamples,
+			Pan:       definition.Pan,
+			Width:     definition.Width,
+			Effects:   definition.Effects,
+			Duplicates: definition.Duplicates,
 		}
 
 		// Track inheritance for this channel
@@ -326,8 +775,23 @@ func convertProfileToConfig(profile *ConfigProfile, definitions *DefinitionsConf
 		}
 		if chRef.Delay != nil {
 			channel.Delay = *chRef.Delay
+			channel.DelaySamples = 0
+			channelInheritance.Delay = "reference-override"
+		}
+		if chRef.DelaySamples != nil {
+			channel.Delay = 0
+			channel.DelaySamples = *chRef.DelaySamples
 			channelInheritance.Delay = "reference-override"
 		}
+		if chRef.Pan != nil {
+			channel.Pan = *chRef.Pan
+		}
+		if chRef.Width != nil {
+			channel.Width = chRef.Width
+		}
+		if chRef.Effects != nil {
+			channel.Effects = chRef.Effects
+		}
 
 		config.Inheritance.Channels[channel.Name] = channelInheritance
 		config.Channels = append(config.Channels, channel)
@@ -367,6 +831,13 @@ func mergeConfigs(base, profile *Config) *Config {
 		result.Audio = base.Audio
 		result.Output = base.Output
 		result.AutoMix = base.AutoMix
+		result.Trigger = base.Trigger
+		result.AutoSplit = base.AutoSplit
+		result.Ready = base.Ready
+		result.Segments = base.Segments
+		result.Capture = base.Capture
+		result.Metronome = base.Metronome
+		result.Align = base.Align
 
 		// Mark as inherited by default
 		result.Inheritance.Audio.SampleRate = "inherited"
@@ -393,6 +864,15 @@ func mergeConfigs(base, profile *Config) *Config {
 		result.Audio.Backend = profile.Audio.Backend
 		result.Inheritance.Audio.Backend = "profile-specific"
 	}
+	if profile.Audio.Fake != nil {
+		result.Audio.Fake = profile.Audio.Fake
+	}
+	if profile.Audio.Quantum != 0 {
+		result.Audio.Quantum = profile.Audio.Quantum
+	}
+	if profile.Audio.ClockCheck != "" {
+		result.Audio.ClockCheck = profile.Audio.ClockCheck
+	}
 
 
 	if profile.Output.Directory != "" {
@@ -410,6 +890,28 @@ func mergeConfigs(base, profile *Config) *Config {
 	// AutoMix: profile value always takes precedence if the profile is loaded
 	result.AutoMix = profile.AutoMix
 
+	if profile.Trigger != nil {
+		result.Trigger = profile.Trigger
+	}
+	if profile.AutoSplit != nil {
+		result.AutoSplit = profile.AutoSplit
+	}
+	if profile.Ready != nil {
+		result.Ready = profile.Ready
+	}
+	if profile.Segments != nil {
+		result.Segments = profile.Segments
+	}
+	if profile.Capture != nil {
+		result.Capture = profile.Capture
+	}
+	if profile.Metronome != nil {
+		result.Metronome = profile.Metronome
+	}
+	if profile.Align != nil {
+		result.Align = profile.Align
+	}
+
 	// CHANNELS: Selection & Fallback Model
 	// Only use channels explicitly listed in profile, with inheritance for missing fields
 	result.Channels = make([]Channel, 0, len(profile.Channels))
@@ -422,6 +924,11 @@ func mergeConfigs(base, profile *Config) *Config {
 			Type:      profileChannel.Type,
 			Volume:    profileChannel.Volume,
 			Delay:     profileChannel.Delay,
+			DelaySamples: profileChannel.DelaySamples,
+			Pan:       profileChannel.Pan,
+			Width:     profileChannel.Width,
+			Effects:   profileChannel.Effects,
+			Duplicates: profileChannel.Duplicates,
 		}
 
 		// Track inheritance for this channel
@@ -454,6 +961,9 @@ func mergeConfigs(base, profile *Config) *Config {
 						resolvedChannel.Type = baseChannel.Type
 						channelInheritance.Type = "inherited"
 					}
+					if resolvedChannel.Duplicates == nil {
+						resolvedChannel.Duplicates = baseChannel.Duplicates
+					}
 					// For volume and delay: if they are present in profile channel (even if 0),
 					// they are considered profile-specific. Only inherit if completely missing.
 					// Since the test explicitly sets Volume: 0, Delay: 0, these are profile-specific.
@@ -495,6 +1005,11 @@ func isValidAudioSource(source string) bool {
 		return true
 	}
 
+	// Patterns and selectors are valid when they parse
+	if pattern, err := ParseSource(source); err != nil || pattern.Kind != SourceExact {
+		return err == nil
+	}
+
 	// Check if it contains colon
 	if strings.Contains(source, ":") {
 		// For JACK/PipeWire devices that may contain colons in their names,
@@ -531,6 +1046,156 @@ func isValidAudioSource(source string) bool {
 	return len(source) > 0
 }
 
+// Source kinds
+const (
+	SourceExact    = "exact"    // Port name as listed by pw-link
+	SourceGlob     = "glob"     // Port name with * and ? wildcards
+	SourceRegex    = "regex"    // "re:" followed by a regular expression matching the whole port name
+	SourceSelector = "selector" // Node properties and a port name, written as a map
+)
+
+// regexSourcePrefix starts a source given as a regular expression
+const regexSourcePrefix = "re:"
+
+// selectorPortKey is the selector key naming the port within the matched node
+const selectorPortKey = "port"
+
+// SourcePattern is a parsed channel source. Glob, regex and selector sources
+// are resolved to a concrete port by the recorder when READY is entered.
+type SourcePattern struct {
+	Kind       string
+	Source     string          // Source as written in the configuration
+	Name       *regexp.Regexp  // Matches port names (glob, regex) or the port part after the client (selector)
+	Properties []PropertyMatch // Node and port properties required by a selector, sorted by property
+}
+
+// ParseSource parses a channel source: an exact port name, a glob such as
+// "alsa_input.usb-Focusrite_Scarlett_2i2_*:capture_FR", a regex such as
+// "re:^alsa_input\..*Scarlett.*:capture_FR$", or a selector such as
+// {"device.product.name": "Scarlett 2i2", "port": "capture_FR"}
+func ParseSource(source string) (SourcePattern, error) {
+	source = strings.TrimSpace(source)
+	pattern := SourcePattern{Kind: SourceExact, Source: source}
+
+	switch {
+	case strings.HasPrefix(source, "{"):
+		var selector map[string]string
+		if err := json.Unmarshal([]byte(source), &selector); err != nil {
+			return pattern, fmt.Errorf("invalid source selector %s: %w", source, err)
+		}
+		port, exists := selector[selectorPortKey]
+		if !exists || port == "" {
+			return pattern, fmt.Errorf("source selector %s requires a '%s'", source, selectorPortKey)
+		}
+		if len(selector) < 2 {
+			return pattern, fmt.Errorf("source selector %s requires at least one property besides '%s'", source, selectorPortKey)
+		}
+
+		pattern.Kind = SourceSelector
+		pattern.Name = globToRegexp(port)
+		for property, value := range selector {
+			if property != selectorPortKey {
+				pattern.Properties = append(pattern.Properties, PropertyMatch{Property: property, Value: value})
+			}
+		}
+		sort.Slice(pattern.Properties, func(i, j int) bool {
+			return pattern.Properties[i].Property < pattern.Properties[j].Property
+		})
+
+	case strings.HasPrefix(source, regexSourcePrefix):
+		// Anchored, so that "re:Chrome" does not match "Chromium:output_FL"
+		expression, err := regexp.Compile("^(?:" + strings.TrimPrefix(source, regexSourcePrefix) + ")$")
+		if err != nil {
+			return pattern, fmt.Errorf("invalid source regex %s: %w", source, err)
+		}
+		pattern.Kind = SourceRegex
+		pattern.Name = expression
+
+	case strings.ContainsAny(source, "*?"):
+		pattern.Kind = SourceGlob
+		pattern.Name = globToRegexp(source)
+	}
+
+	return pattern, nil
+}
+
+// IsPattern reports whether the source must be resolved against the port graph
+func (p SourcePattern) IsPattern() bool {
+	return p.Kind != SourceExact
+}
+
+// MatchName reports whether a port name matches a glob or regex source, or
+// whether the port part of a name matches a selector
+func (p SourcePattern) MatchName(portName string) bool {
+	switch p.Kind {
+	case SourceExact:
+		return portName == p.Source
+	case SourceSelector:
+		return p.Name.MatchString(portName[strings.LastIndex(portName, ":")+1:])
+	default:
+		return p.Name.MatchString(portName)
+	}
+}
+
+// globToRegexp compiles a glob where * matches any run of characters and ?
+// matches one character
+func globToRegexp(glob string) *regexp.Regexp {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for _, char := range glob {
+		switch char {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
+
+// sourceSelectorHook lets a source list hold selectors written as YAML maps,
+// such as {device.product.name: "Scarlett 2i2", port: capture_FR}. They are
+// kept in their JSON form so that channels keep plain string sources.
+func sourceSelectorHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.Slice || to != reflect.TypeOf([]string(nil)) {
+		return data, nil
+	}
+
+	items, ok := data.([]interface{})
+	if !ok {
+		return data, nil
+	}
+
+	converted := make([]interface{}, len(items))
+	for i, item := range items {
+		converted[i] = item
+		if selector, isMap := item.(map[string]interface{}); isMap {
+			values := make(map[string]string, len(selector))
+			for key, value := range selector {
+				values[key] = fmt.Sprint(value)
+			}
+			encoded, err := json.Marshal(values)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = string(encoded)
+		}
+	}
+	return converted, nil
+}
+
+// decodeHook keeps viper's default conversions and adds source selectors
+func decodeHook() viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		sourceSelectorHook,
+	))
+}
+
 // isNumeric checks if a string contains only digits
 func isNumeric(s string) bool {
 	if len(s) == 0 {
@@ -547,6 +1212,7 @@ func isNumeric(s string) bool {
 // validateAudioSources ensures all channel sources specify valid audio source names
 // Accepts JACK port names (device:port) format
 func validateAudioSources(config *Config) error {
+	playbackChannels := 0
 	for i, channel := range config.Channels {
 		// Validate channel name
 		if channel.Name == "" {
@@ -555,15 +1221,27 @@ func validateAudioSources(config *Config) error {
 
 		// Validate channel type
 		if channel.Type == "" {
-			return fmt.Errorf("channel[%d] '%s' must have a type (input, monitor)", i, channel.Name)
+			return fmt.Errorf("channel[%d] '%s' must have a type (input, monitor, playback)", i, channel.Name)
+		}
+		if channel.Type != "input" && channel.Type != "monitor" && channel.Type != ChannelTypePlayback {
+			return fmt.Errorf("channel[%d] '%s' type must be 'input', 'monitor' or 'playback', got: %s", i, channel.Name, channel.Type)
 		}
-		if channel.Type != "input" && channel.Type != "monitor" {
-			return fmt.Errorf("channel[%d] '%s' type must be 'input' or 'monitor', got: %s", i, channel.Name, channel.Type)
+
+		// The recorder links its own player to a playback channel
+		if channel.Type == ChannelTypePlayback {
+			if err := validatePlaybackChannel(channel.AudioMode, channel.Sources); err != nil {
+				return fmt.Errorf("channel[%d] '%s' %w", i, channel.Name, err)
+			}
+			if playbackChannels++; playbackChannels > 1 {
+				return fmt.Errorf("channel[%d] '%s': only one playback channel can record the backing track", i, channel.Name)
+			}
+			config.Channels[i].AudioMode = "stereo"
+			continue
 		}
 
 		// Validate audioMode
-		if channel.AudioMode != "" && channel.AudioMode != "mono" && channel.AudioMode != "stereo" {
-			return fmt.Errorf("channel[%d] '%s' audioMode must be 'mono' or 'stereo', got: %s", i, channel.Name, channel.AudioMode)
+		if _, _, valid := audioModeSources(channel.AudioMode); channel.AudioMode != "" && !valid {
+			return fmt.Errorf("channel[%d] '%s' audioMode must be %s, got: %s", i, channel.Name, audioModes, channel.AudioMode)
 		}
 
 		// Set default audioMode if not specified
@@ -572,24 +1250,29 @@ func validateAudioSources(config *Config) error {
 		}
 		channel = config.Channels[i] // Update reference after modification
 
+		// Only a stereo image has a width
+		if channel.Width != nil && (channel.AudioMode == "mono" || channel.AudioMode == "group") {
+			return fmt.Errorf("channel[%d] '%s': 'width' needs a stereo or surround source, not %s", i, channel.Name, channel.AudioMode)
+		}
+
 		// Validate sources
 		if len(channel.Sources) == 0 {
 			return fmt.Errorf("channel[%d] '%s' must have at least one source", i, channel.Name)
 		}
 
 		// Validate sources count matches audioMode
-		expectedSources := 1
-		if channel.AudioMode == "stereo" {
-			expectedSources = 2
-		}
-		if len(channel.Sources) != expectedSources {
-			return fmt.Errorf("channel[%d] '%s' with audioMode '%s' must have exactly %d source(s), got %d",
-				i, channel.Name, channel.AudioMode, expectedSources, len(channel.Sources))
+		minSources, maxSources, _ := audioModeSources(channel.AudioMode)
+		if len(channel.Sources) < minSources || len(channel.Sources) > maxSources {
+			return fmt.Errorf("channel[%d] '%s' with audioMode '%s' must have %s, got %d",
+				i, channel.Name, channel.AudioMode, sourceCountText(minSources, maxSources), len(channel.Sources))
 		}
 
 		// Validate each source has proper format
 		for j, source := range channel.Sources {
 			if source != "" && source != "disabled" {
+				if _, err := ParseSource(source); err != nil {
+					return fmt.Errorf("channel[%d] '%s' source[%d]: %w", i, channel.Name, j, err)
+				}
 				// Accept JACK format (device:port)
 				if !isValidAudioSource(source) {
 					return fmt.Errorf("channel[%d] '%s' source[%d] must be a valid audio source (JACK port), got: %s",
@@ -616,7 +1299,7 @@ func GetSupportedAudioExtensions(configFile string) []string {
 	}
 
 	var rootConfig RootConfig
-	if err := viper.Unmarshal(&rootConfig); err != nil {
+	if err := viper.Unmarshal(&rootConfig, decodeHook()); err != nil {
 		return defaultExtensions
 	}
 
@@ -630,8 +1313,8 @@ func GetSupportedAudioExtensions(configFile string) []string {
 // BuildMixFilter creates FFmpeg filter based on recorded stream structure and channel configuration
 func (c *Config) BuildMixFilter() (filter string, outputChannels int) {
 	// The recorded file structure is now:
-	// Stream 0:0 - First channel (mono=1ch, stereo=2ch with metadata title=channel_name or channel_name_stereo)
-	// Stream 0:1 - Second channel (mono=1ch, stereo=2ch)
+	// Stream 0:0 - First channel (mono=1ch, stereo=2ch, layouts and groups=Nch, with metadata title=channel_name)
+	// Stream 0:1 - Second channel (mono=1ch, stereo=2ch, layouts and groups=Nch)
 	// Stream 0:N - Nth channel
 	// Each channel is a separate track in the same order as configuration
 
@@ -647,25 +1330,8 @@ func (c *Config) BuildMixFilter() (filter string, outputChannels int) {
 	for i, channel := range enabledChannels {
 		channelRef := fmt.Sprintf("[ch_%s]", channel.Name)
 
-		// For stereo channels, the input stream already contains 2 channels
-		// For mono channels, the input stream contains 1 channel
-		var baseFilter string
-		if channel.AudioMode == "stereo" || len(channel.Sources) > 1 {
-			// Stereo channel: apply volume and delay to both channels
-			if channel.Delay > 0 {
-				// For stereo channels, apply delay to both left and right channels: adelay=delay|delay
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f,adelay=%d|%d", i, channel.Volume, channel.Delay, channel.Delay)
-			} else {
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f", i, channel.Volume)
-			}
-		} else {
-			// Mono channel: apply volume and delay, then convert to stereo for mixing
-			if channel.Delay > 0 {
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f,adelay=%d,aformat=channel_layouts=stereo", i, channel.Volume, channel.Delay)
-			} else {
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f,aformat=channel_layouts=stereo", i, channel.Volume)
-			}
-		}
+		// The input stream holds the channel's own layout, brought to stereo for mixing
+		baseFilter := trackFilter(i, channel, channel.ChannelCount(), channel.ChannelLayout(), c.GetSampleRate())
 
 		filterParts = append(filterParts, baseFilter+channelRef)
 		inputChannels = append(inputChannels, channelRef)
@@ -692,6 +1358,127 @@ func (c *Config) BuildMixFilter() (filter string, outputChannels int) {
 	return filter, outputChannels
 }
 
+// DelayFrames returns the channel's delay in samples at a sample rate,
+// negative when the channel is pulled earlier
+func (ch Channel) DelayFrames(sampleRate int) int {
+	if ch.DelaySamples != 0 {
+		return ch.DelaySamples
+	}
+	return int(math.Round(ch.Delay * float64(sampleRate) / 1000))
+}
+
+// DelayMs returns the channel's delay in milliseconds at a sample rate
+func (ch Channel) DelayMs(sampleRate int) float64 {
+	if ch.DelaySamples != 0 {
+		return float64(ch.DelaySamples) * 1000 / float64(sampleRate)
+	}
+	return ch.Delay
+}
+
+// delayFilter shifts a track by a channel's delay. Whole milliseconds keep
+// FFmpeg's millisecond syntax, other delays are counted in samples. A late
+// track is padded with silence, an early one loses its start.
+func delayFilter(channel Channel, channels, sampleRate int) string {
+	frames := channel.DelayFrames(sampleRate)
+	exact := channel.DelaySamples == 0 && channel.Delay == math.Trunc(channel.Delay)
+
+	switch {
+	case frames < 0 && exact:
+		return fmt.Sprintf(",atrim=start=%.3f,asetpts=PTS-STARTPTS", -channel.Delay/1000)
+	case frames < 0:
+		return fmt.Sprintf(",atrim=start_sample=%d,asetpts=PTS-STARTPTS", -frames)
+	case frames > 0:
+		// Every channel of the track is delayed: adelay=delay|delay|...
+		delay := fmt.Sprintf("%dS", frames)
+		if exact {
+			delay = fmt.Sprintf("%.0f", channel.Delay)
+		}
+		delays := make([]string, channels)
+		for i := range delays {
+			delays[i] = delay
+		}
+		return ",adelay=" + strings.Join(delays, "|")
+	}
+	return ""
+}
+
+// StereoWidth returns the width the channel's stereo image is mixed at
+func (ch Channel) StereoWidth() float64 {
+	if ch.Width == nil {
+		return 1
+	}
+	return *ch.Width
+}
+
+// panGains returns the gains placing a mono signal in the stereo field at
+// constant power: -3 dB on both sides when centred
+func panGains(pan float64) (left, right float64) {
+	angle := (pan + 1) * math.Pi / 4
+	return math.Cos(angle), math.Sin(angle)
+}
+
+// panTerms writes the gains of a pan filter output channel, e.g.
+// 0.750*c0-0.250*c1
+func panTerms(gains ...float64) string {
+	terms := ""
+	for i, gain := range gains {
+		if i > 0 && gain >= 0 {
+			terms += "+"
+		}
+		terms += fmt.Sprintf("%.3f*c%d", gain, i)
+	}
+	return terms
+}
+
+// stereoPlacement sets the width and balance of a stereo track. The width
+// scales the difference of the sides around their sum, the balance turns
+// down the side the track is panned away from. Centred at full width the
+// track is left alone.
+func stereoPlacement(channel Channel) string {
+	width, pan := channel.StereoWidth(), channel.Pan
+	if width == 1 && pan == 0 {
+		return ""
+	}
+
+	direct, cross := (1+width)/2, (1-width)/2
+	left, right := 1-math.Max(pan, 0), 1+math.Min(pan, 0)
+	return fmt.Sprintf(",pan=stereo|c0=%s|c1=%s", panTerms(left*direct, left*cross), panTerms(right*cross, right*direct))
+}
+
+// trackFilter runs a channel's effects on its track, applies its volume and
+// delay and brings it to stereo: mono is panned, centred by default, named
+// layouts are downmixed by FFmpeg, and the discrete channels of a group are
+// each panned like a mono channel. Stereo results then take the channel's
+// width and balance.
+func trackFilter(index int, channel Channel, channels int, layout string, sampleRate int) string {
+	graph, head, filters := effectsChain(index, channel.Effects, sampleRate)
+	filters = append(filters, fmt.Sprintf("volume=%.1f", channel.Volume))
+	filter := graph + head + strings.Join(filters, ",") + delayFilter(channel, channels, sampleRate)
+
+	switch layout {
+	case "stereo":
+		return filter + stereoPlacement(channel)
+	case "mono":
+		if channel.Pan == 0 {
+			return filter + ",aformat=channel_layouts=stereo"
+		}
+		left, right := panGains(channel.Pan)
+		return fmt.Sprintf("%s,pan=stereo|c0=%s|c1=%s", filter, panTerms(left), panTerms(right))
+	case "":
+		// A mono channel is centred at -3 dB, so is every channel of a group
+		left, right := panGains(channel.Pan)
+		var leftSum, rightSum []string
+		for i := 0; i < channels; i++ {
+			leftSum = append(leftSum, fmt.Sprintf("%.3f*c%d", left, i))
+			rightSum = append(rightSum, fmt.Sprintf("%.3f*c%d", right, i))
+		}
+		return fmt.Sprintf("%s,pan=stereo|c0=%s|c1=%s", filter, strings.Join(leftSum, "+"), strings.Join(rightSum, "+"))
+	default:
+		// Name the channels first: the file may carry FFmpeg's guess for the count
+		return fmt.Sprintf("%s,channelmap=channel_layout=%s,aformat=channel_layouts=stereo", filter, layout) + stereoPlacement(channel)
+	}
+}
+
 // BuildMixFilterWithGlobalVolume creates FFmpeg filter with global volume control
 func (c *Config) BuildMixFilterWithGlobalVolume(globalVolume float64) (filter string, outputChannels int) {
 	// Start with the base filter
@@ -728,13 +1515,27 @@ type TrackInfo struct {
 	Name     string `json:"name"`
 	Title    string `json:"title"`
 	Channels int    `json:"channels"`
+	Take     string `json:"take,omitempty"` // Overdub the track was added by, empty for the original take
+}
+
+// TrackOffset is the offset measured between a monitor track and the
+// reference track of an alignment
+type TrackOffset struct {
+	Track      int     `json:"track"`
+	Channel    string  `json:"channel"`
+	Reference  string  `json:"reference"`
+	Offset     float64 `json:"offset_ms"`  // How late the reference hears the monitor, negative when it hears it early
+	Confidence float64 `json:"confidence"` // How alike the tracks are at that offset, from 0 to 1
+	Delay      float64 `json:"delay_ms"`   // Delay the mix applied, negative for a trim
+	Applied    bool    `json:"applied"`    // False when the configured delay was kept
 }
 
 // MKVAnalysis represents the analysis results of an MKV file (imported from mix package)
 type MKVAnalysis struct {
-	Filename   string      `json:"filename"`
-	TrackCount int         `json:"track_count"`
-	Tracks     []TrackInfo `json:"tracks"`
+	Filename   string        `json:"filename"`
+	TrackCount int           `json:"track_count"`
+	Tracks     []TrackInfo   `json:"tracks"`
+	Alignment  []TrackOffset `json:"alignment,omitempty"` // Offsets of the last aligned mix
 }
 
 // BuildMixFilterForFile creates FFmpeg filter based on actual file structure
@@ -771,25 +1572,16 @@ func (c *Config) BuildMixFilterForFile(analysis *MKVAnalysis) (filter string, ou
 		track := analysis.Tracks[i]
 		channelRef := fmt.Sprintf("[ch_%s]", channel.Name)
 
-		// For stereo channels, the input stream already contains 2 channels
-		// For mono channels, the input stream contains 1 channel
-		var baseFilter string
-		if track.Channels > 1 || channel.AudioMode == "stereo" {
-			// Stereo track: apply volume and delay to both channels
-			if channel.Delay > 0 {
-				// For stereo tracks, apply delay to both left and right channels: adelay=delay|delay
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f,adelay=%d|%d", i, channel.Volume, channel.Delay, channel.Delay)
-			} else {
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f", i, channel.Volume)
-			}
-		} else {
-			// Mono track: apply volume and delay, then convert to stereo for mixing
-			if channel.Delay > 0 {
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f,adelay=%d,aformat=channel_layouts=stereo", i, channel.Volume, channel.Delay)
-			} else {
-				baseFilter = fmt.Sprintf("[0:%d]volume=%.1f,aformat=channel_layouts=stereo", i, channel.Volume)
+		// The file decides how many channels the track holds; the
+		// configuration only names them when the counts agree
+		channels, layout := channel.ChannelCount(), channel.ChannelLayout()
+		if track.Channels > 0 && track.Channels != channels {
+			channels, layout = track.Channels, ""
+			if track.Channels <= 2 {
+				layout = []string{"mono", "stereo"}[track.Channels-1]
 			}
 		}
+		baseFilter := trackFilter(i, channel, channels, layout, c.GetSampleRate())
 
 		filterParts = append(filterParts, baseFilter+channelRef)
 		inputChannels = append(inputChannels, channelRef)
@@ -846,10 +1638,22 @@ func (c *Config) BuildMixFilterForFileWithGlobalVolume(analysis *MKVAnalysis, gl
 	return baseFilter, channels
 }
 
+// EnabledChannels returns the channels that are recorded, in the order of
+// their tracks in the MKV file
+func (c *Config) EnabledChannels() []Channel {
+	return c.getEnabledChannels()
+}
+
 // getEnabledChannels returns channels that are not disabled
 func (c *Config) getEnabledChannels() []Channel {
 	var enabled []Channel
 	for _, ch := range c.Channels {
+		// The backing track is always recorded, from the recorder's own player
+		if ch.Type == ChannelTypePlayback {
+			enabled = append(enabled, ch)
+			continue
+		}
+
 		// Channel is enabled if it has at least one non-empty, non-disabled source
 		if len(ch.Sources) > 0 {
 			hasValidSource := false
@@ -886,11 +1690,11 @@ func (c *Config) GetChannelVolume(channelName string) float64 {
 	}
 }
 
-// GetChannelDelay gets delay for a channel
-func (c *Config) GetChannelDelay(channelName string) int {
+// GetChannelDelay gets delay for a channel in milliseconds
+func (c *Config) GetChannelDelay(channelName string) float64 {
 	for _, channel := range c.Channels {
 		if channel.Name == channelName {
-			return channel.Delay
+			return channel.DelayMs(c.GetSampleRate())
 		}
 	}
 
@@ -898,6 +1702,231 @@ func (c *Config) GetChannelDelay(channelName string) int {
 	return 0
 }
 
+// GetTrigger returns the recording trigger with defaults applied
+func (c *Config) GetTrigger() TriggerConfig {
+	trigger := TriggerConfig{Mode: TriggerSources}
+	if c.Trigger != nil {
+		trigger = *c.Trigger
+	}
+	if trigger.Mode == "" {
+		trigger.Mode = TriggerSources
+	}
+	if trigger.Threshold == 0 {
+		trigger.Threshold = DefaultTriggerThreshold
+	}
+	return trigger
+}
+
+// GetAutoSplit returns the auto-split policy with defaults applied, or nil if takes are never split
+func (c *Config) GetAutoSplit() *AutoSplitConfig {
+	if c.AutoSplit == nil {
+		return nil
+	}
+
+	autoSplit := *c.AutoSplit
+	if autoSplit.Threshold == 0 {
+		autoSplit.Threshold = DefaultSilenceThreshold
+	}
+	return &autoSplit
+}
+
+// GetSampleRate returns the capture sample rate, 48 kHz unless configured
+func (c *Config) GetSampleRate() int {
+	if c.Audio.SampleRate == 0 {
+		return DefaultSampleRate
+	}
+	return c.Audio.SampleRate
+}
+
+// GetQuantum returns the PipeWire buffer size in frames
+func (c *Config) GetQuantum() int {
+	if c.Audio.Quantum == 0 {
+		return DefaultQuantum
+	}
+	return c.Audio.Quantum
+}
+
+// GetClockCheck returns what to do when the graph cannot run at the sample rate
+func (c *Config) GetClockCheck() string {
+	if c.Audio.ClockCheck == "" {
+		return ClockCheckWarn
+	}
+	return c.Audio.ClockCheck
+}
+
+// GetCapture returns the encoding of the multitrack recording with defaults applied
+func (c *Config) GetCapture() CaptureConfig {
+	capture := CaptureConfig{}
+	if c.Capture != nil {
+		capture = *c.Capture
+	}
+	if capture.Codec == "" {
+		capture.Codec = DefaultCaptureCodec
+	}
+	return capture
+}
+
+// EncoderArgs returns the FFmpeg output options encoding the capture
+func (c CaptureConfig) EncoderArgs() []string {
+	args := []string{"-c:a", c.Codec}
+	if c.Codec != "flac" {
+		return args
+	}
+
+	// FFmpeg's FLAC encoder stores 24-bit audio in 32-bit samples
+	switch c.BitDepth {
+	case 16:
+		args = append(args, "-sample_fmt", "s16")
+	case 24:
+		args = append(args, "-sample_fmt", "s32", "-bits_per_raw_sample", "24")
+	}
+	if c.CompressionLevel != nil {
+		args = append(args, "-compression_level", fmt.Sprintf("%d", *c.CompressionLevel))
+	}
+	return args
+}
+
+// GetMetronome returns the metronome with defaults applied, or nil if the
+// profile plays no click
+func (c *Config) GetMetronome() *MetronomeConfig {
+	if c.Metronome == nil {
+		return nil
+	}
+
+	metronome := *c.Metronome
+	if metronome.TimeSignature == "" {
+		metronome.TimeSignature = DefaultTimeSignature
+	}
+	if metronome.Volume == 0 {
+		metronome.Volume = DefaultMetronomeVolume
+	}
+	return &metronome
+}
+
+// GetAlign returns the alignment with defaults applied, or nil if the mix
+// keeps the configured delays
+func (c *Config) GetAlign() *AlignConfig {
+	if c.Align == nil {
+		return nil
+	}
+
+	align := *c.Align
+	if align.MaxOffset == 0 {
+		align.MaxOffset = DefaultAlignMaxOffset
+	}
+	if align.MinConfidence == 0 {
+		align.MinConfidence = DefaultAlignMinConfidence
+	}
+	if align.Reference == "" {
+		for _, channel := range c.getEnabledChannels() {
+			if channel.Type == "input" {
+				align.Reference = channel.Name
+				break
+			}
+		}
+	}
+	return &align
+}
+
+// BeatsPerBar returns the number of clicks in a bar, the first of them accented
+func (m MetronomeConfig) BeatsPerBar() int {
+	beats, _, err := parseTimeSignature(m.TimeSignature)
+	if err != nil {
+		return 4
+	}
+	return beats
+}
+
+// CountInDuration returns how long the count-in holds the take
+func (m MetronomeConfig) CountInDuration() time.Duration {
+	if m.BPM <= 0 {
+		return 0
+	}
+	beats := float64(m.CountIn * m.BeatsPerBar())
+	return time.Duration(beats * 60 / m.BPM * float64(time.Second))
+}
+
+// parseTimeSignature splits a time signature such as "7/8" into beats per
+// bar and beat unit
+func parseTimeSignature(signature string) (int, int, error) {
+	parts := strings.Split(signature, "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("'time_signature' must look like 4/4, got: %s", signature)
+	}
+	beats, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || beats < 1 || beats > 16 {
+		return 0, 0, fmt.Errorf("'time_signature' must have 1 to 16 beats per bar, got: %s", signature)
+	}
+	unit, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || (unit != 1 && unit != 2 && unit != 4 && unit != 8 && unit != 16) {
+		return 0, 0, fmt.Errorf("'time_signature' must have a beat unit of 1, 2, 4, 8 or 16, got: %s", signature)
+	}
+	return beats, unit, nil
+}
+
+// GetSegments returns the segmented capture settings with defaults applied,
+// or nil if recordings are written as a single file
+func (c *Config) GetSegments() *SegmentsConfig {
+	if c.Segments == nil {
+		return nil
+	}
+
+	segments := *c.Segments
+	if segments.Duration == 0 {
+		segments.Duration = DefaultSegmentDuration
+	}
+	return &segments
+}
+
+// GetReady returns the READY behaviour with defaults applied
+func (c *Config) GetReady() ReadyConfig {
+	ready := ReadyConfig{}
+	if c.Ready != nil {
+		ready = *c.Ready
+	}
+	if ready.Timeout == "" {
+		ready.Timeout = DefaultReadyTimeout.String()
+	}
+	if ready.PollInterval == "" {
+		ready.PollInterval = DefaultReadyPollInterval.String()
+	}
+	if ready.Duplicates == "" {
+		ready.Duplicates = DuplicatesBlock
+	}
+	return ready
+}
+
+// GetDuplicateRule returns the duplicate rule of a channel, falling back to
+// the ready section's policy
+func (c *Config) GetDuplicateRule(channel Channel) DuplicateRule {
+	if channel.Duplicates != nil && channel.Duplicates.Policy != "" {
+		return *channel.Duplicates
+	}
+	return DuplicateRule{Policy: c.GetReady().Duplicates}
+}
+
+// TimeoutDuration returns how long READY waits for its sources, 0 meaning forever.
+// Invalid values, which validation rejects, fall back to the default.
+func (r ReadyConfig) TimeoutDuration() time.Duration {
+	if r.Timeout == ReadyTimeoutNever {
+		return 0
+	}
+	timeout, err := time.ParseDuration(r.Timeout)
+	if err != nil || timeout <= 0 {
+		return DefaultReadyTimeout
+	}
+	return timeout
+}
+
+// PollDuration returns the interval between source checks without port events
+func (r ReadyConfig) PollDuration() time.Duration {
+	interval, err := time.ParseDuration(r.PollInterval)
+	if err != nil || interval < MinReadyPollInterval {
+		return DefaultReadyPollInterval
+	}
+	return interval
+}
+
 // ValidateConfigurationFormat validates the configuration file format and returns parsed config
 func ValidateConfigurationFormat(configFile string) (*RootConfig, error) {
 	viper.SetConfigFile(configFile)
@@ -912,7 +1941,7 @@ func ValidateConfigurationFormat(configFile string) (*RootConfig, error) {
 	}
 
 	var rootConfig RootConfig
-	if err := viper.Unmarshal(&rootConfig); err != nil {
+	if err := viper.Unmarshal(&rootConfig, decodeHook()); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
@@ -921,11 +1950,42 @@ func ValidateConfigurationFormat(configFile string) (*RootConfig, error) {
 		return nil, fmt.Errorf("invalid definitions: %w", err)
 	}
 
+	// Validate the global audio section
+	if rootConfig.Audio != nil {
+		if err := validateAudio(*rootConfig.Audio); err != nil {
+			return nil, fmt.Errorf("invalid global settings: %w", err)
+		}
+	}
+
 	// Validate that all channel references in configs are valid
 	for configName, configProfile := range rootConfig.Configs {
 		if err := validateChannelReferences(configProfile.Channels, rootConfig.Definitions, configName); err != nil {
 			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
 		}
+		if err := validateTrigger(configProfile.Trigger, profileChannelNames(configProfile)); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
+		if err := validateAutoSplit(configProfile.AutoSplit); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
+		if err := validateAudio(configProfile.Audio); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
+		if err := validateCapture(configProfile.Capture); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
+		if err := validateMetronome(configProfile.Metronome); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
+		if err := validateAlign(configProfile.Align); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
+		if err := validateSegments(configProfile.Segments); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
+		if err := validateReady(configProfile.Ready); err != nil {
+			return nil, fmt.Errorf("invalid config '%s': %w", configName, err)
+		}
 	}
 
 	return &rootConfig, nil
@@ -962,8 +2022,37 @@ func validateDefinitions(definitions *DefinitionsConfig) error {
 	return nil
 }
 
+// validatePlaybackChannel checks that a playback channel leaves its sources to
+// the recorder and records the stereo backing track
+func validatePlaybackChannel(audioMode string, sources []string) error {
+	if len(sources) > 0 {
+		return fmt.Errorf("playback channel takes no 'sources', the recorder links its own player")
+	}
+	if audioMode != "" && audioMode != "stereo" {
+		return fmt.Errorf("playback channel records the backing track in stereo, got audioMode: %s", audioMode)
+	}
+	return nil
+}
+
 // validateChannelDefinition validates a single channel definition
 func validateChannelDefinition(def ChannelDefinition, prefix string) error {
+	if err := validateChannelPlacement(def.Pan, def.Width); err != nil {
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+	if err := ValidateEffects(def.Effects); err != nil {
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+
+	if def.Type == ChannelTypePlayback {
+		if err := validatePlaybackChannel(def.AudioMode, def.Sources); err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		if def.Volume <= 0 {
+			return fmt.Errorf("%s: 'volume' must be > 0, got: %.2f", prefix, def.Volume)
+		}
+		return nil
+	}
+
 	if len(def.Sources) == 0 {
 		return fmt.Errorf("%s: 'sources' is required and cannot be empty", prefix)
 	}
@@ -972,11 +2061,11 @@ func validateChannelDefinition(def ChannelDefinition, prefix string) error {
 		return fmt.Errorf("%s: 'type' is required", prefix)
 	}
 	if def.Type != "input" && def.Type != "monitor" {
-		return fmt.Errorf("%s: 'type' must be 'input' or 'monitor', got: %s", prefix, def.Type)
+		return fmt.Errorf("%s: 'type' must be 'input', 'monitor' or 'playback', got: %s", prefix, def.Type)
 	}
 
-	if def.AudioMode != "" && def.AudioMode != "mono" && def.AudioMode != "stereo" {
-		return fmt.Errorf("%s: 'audioMode' must be 'mono' or 'stereo', got: %s", prefix, def.AudioMode)
+	if _, _, valid := audioModeSources(def.AudioMode); def.AudioMode != "" && !valid {
+		return fmt.Errorf("%s: 'audioMode' must be %s, got: %s", prefix, audioModes, def.AudioMode)
 	}
 
 	// Set default audioMode if not specified
@@ -985,18 +2074,18 @@ func validateChannelDefinition(def ChannelDefinition, prefix string) error {
 	}
 
 	// Validate sources count matches audioMode
-	expectedSources := 1
-	if def.AudioMode == "stereo" {
-		expectedSources = 2
-	}
-	if len(def.Sources) != expectedSources {
-		return fmt.Errorf("%s: audioMode '%s' requires exactly %d source(s), got %d",
-			prefix, def.AudioMode, expectedSources, len(def.Sources))
+	minSources, maxSources, _ := audioModeSources(def.AudioMode)
+	if len(def.Sources) < minSources || len(def.Sources) > maxSources {
+		return fmt.Errorf("%s: audioMode '%s' requires %s, got %d",
+			prefix, def.AudioMode, sourceCountText(minSources, maxSources), len(def.Sources))
 	}
 
 	// Validate each source has proper format
 	for j, source := range def.Sources {
 		if source != "" && source != "disabled" {
+			if _, err := ParseSource(source); err != nil {
+				return fmt.Errorf("%s: source[%d]: %w", prefix, j, err)
+			}
 			if !isValidAudioSource(source) {
 				return fmt.Errorf("%s: source[%d] must be a valid audio source (JACK port), got: %s",
 					prefix, j, source)
@@ -1008,8 +2097,40 @@ func validateChannelDefinition(def ChannelDefinition, prefix string) error {
 		return fmt.Errorf("%s: 'volume' must be > 0, got: %.2f", prefix, def.Volume)
 	}
 
-	if def.Delay < 0 {
-		return fmt.Errorf("%s: 'delay' must be >= 0, got: %d", prefix, def.Delay)
+	if err := validateChannelDelay(def.Delay, def.DelaySamples); err != nil {
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+
+	if err := validateDuplicateRule(def.Duplicates, prefix); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateDuplicateRule validates the duplicates section of a channel definition
+func validateDuplicateRule(rule *DuplicateRule, prefix string) error {
+	if rule == nil {
+		return nil
+	}
+
+	switch rule.Policy {
+	case DuplicatesBlock, DuplicatesNewest, DuplicatesOldest, DuplicatesAll:
+		if len(rule.Match) > 0 {
+			return fmt.Errorf("%s: duplicates 'match' requires policy '%s', got: %s", prefix, DuplicatesMatch, rule.Policy)
+		}
+	case DuplicatesMatch:
+		if len(rule.Match) == 0 {
+			return fmt.Errorf("%s: duplicates policy '%s' requires at least one 'match' property", prefix, DuplicatesMatch)
+		}
+		for i, match := range rule.Match {
+			if match.Property == "" {
+				return fmt.Errorf("%s: duplicates match[%d]: 'property' is required", prefix, i)
+			}
+		}
+	default:
+		return fmt.Errorf("%s: invalid duplicates policy '%s', must be '%s', '%s', '%s', '%s' or '%s'",
+			prefix, rule.Policy, DuplicatesBlock, DuplicatesNewest, DuplicatesOldest, DuplicatesMatch, DuplicatesAll)
 	}
 
 	return nil
@@ -1058,12 +2179,354 @@ func validateChannelReferences(channels []ChannelReference, definitions *Definit
 			return fmt.Errorf("%s: volume override must be > 0, got %.2f", prefix, *chRef.Volume)
 		}
 
-		if chRef.Delay != nil && *chRef.Delay < 0 {
-			return fmt.Errorf("%s: delay override must be >= 0, got %d", prefix, *chRef.Delay)
+		if chRef.Delay != nil && chRef.DelaySamples != nil {
+			return fmt.Errorf("%s: override either 'delay' or 'delay_samples', not both", prefix)
+		}
+		if chRef.Delay != nil {
+			if err := validateChannelDelay(*chRef.Delay, 0); err != nil {
+				return fmt.Errorf("%s: delay override: %w", prefix, err)
+			}
+		}
+		if chRef.DelaySamples != nil {
+			if err := validateChannelDelay(0, *chRef.DelaySamples); err != nil {
+				return fmt.Errorf("%s: delay override: %w", prefix, err)
+			}
+		}
+		pan := 0.0
+		if chRef.Pan != nil {
+			pan = *chRef.Pan
+		}
+		if err := validateChannelPlacement(pan, chRef.Width); err != nil {
+			return fmt.Errorf("%s: placement override: %w", prefix, err)
+		}
+		if err := ValidateEffects(chRef.Effects); err != nil {
+			return fmt.Errorf("%s: effects override: %w", prefix, err)
 		}
 	}
 
 	return nil
 }
 
+// validateChannelPlacement checks where a channel sits in the stereo mix
+func validateChannelPlacement(pan float64, width *float64) error {
+	if pan < -1 || pan > 1 {
+		return fmt.Errorf("'pan' must be between -1 and 1, got: %.2f", pan)
+	}
+	if width != nil && (*width < 0 || *width > MaxChannelWidth) {
+		return fmt.Errorf("'width' must be between 0 and %.0f, got: %.2f", MaxChannelWidth, *width)
+	}
+	return nil
+}
+
+// validateChannelDelay checks a delay given in milliseconds or in samples.
+// Either may be negative to pull a late track earlier.
+func validateChannelDelay(delay float64, samples int) error {
+	if delay != 0 && samples != 0 {
+		return fmt.Errorf("set either 'delay' or 'delay_samples', not both")
+	}
+	if math.Abs(delay) > MaxChannelDelay {
+		return fmt.Errorf("'delay' must be between -%d and %d ms, got: %g", MaxChannelDelay, MaxChannelDelay, delay)
+	}
+	if samples < -MaxChannelDelaySamples || samples > MaxChannelDelaySamples {
+		return fmt.Errorf("'delay_samples' must be between -%d and %d, got: %d", MaxChannelDelaySamples, MaxChannelDelaySamples, samples)
+	}
+	return nil
+}
+
+// validateTrigger validates a trigger section against the channels it may watch
+func validateTrigger(trigger *TriggerConfig, channelNames []string) error {
+	if trigger == nil {
+		return nil
+	}
+
+	switch trigger.Mode {
+	case "", TriggerSources, TriggerManual:
+	case TriggerSignal:
+		if trigger.Channel == "" {
+			return fmt.Errorf("trigger: 'channel' is required in signal mode")
+		}
+		found := false
+		for _, name := range channelNames {
+			if name == trigger.Channel {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("trigger: channel '%s' is not recorded by this config", trigger.Channel)
+		}
+		if trigger.Threshold > 0 || trigger.Threshold < -96 {
+			return fmt.Errorf("trigger: 'threshold' must be between -96 and 0 dBFS, got: %.1f", trigger.Threshold)
+		}
+	default:
+		return fmt.Errorf("trigger: 'mode' must be 'sources', 'signal' or 'manual', got: %s", trigger.Mode)
+	}
+
+	if trigger.PreRoll < 0 || trigger.PreRoll > MaxPreRoll {
+		return fmt.Errorf("trigger: 'pre_roll' must be between 0 and %.0f seconds, got: %.2f", MaxPreRoll, trigger.PreRoll)
+	}
+
+	return nil
+}
+
+// validateAutoSplit validates an auto_split section
+func validateAutoSplit(autoSplit *AutoSplitConfig) error {
+	if autoSplit == nil {
+		return nil
+	}
+
+	if autoSplit.Silence < 0 {
+		return fmt.Errorf("auto_split: 'silence' must be >= 0, got: %.2f", autoSplit.Silence)
+	}
+	if autoSplit.StopAfter < 0 {
+		return fmt.Errorf("auto_split: 'stop_after' must be >= 0, got: %.2f", autoSplit.StopAfter)
+	}
+	if autoSplit.Silence == 0 && autoSplit.StopAfter == 0 {
+		return fmt.Errorf("auto_split: 'silence' or 'stop_after' is required")
+	}
+	if autoSplit.Threshold > 0 || autoSplit.Threshold < -96 {
+		return fmt.Errorf("auto_split: 'threshold' must be between -96 and 0 dBFS, got: %.1f", autoSplit.Threshold)
+	}
+
+	return nil
+}
+
+// validateAudio validates the sample rate, quantum and clock check of an audio section
+func validateAudio(audio AudioConfig) error {
+	if audio.SampleRate != 0 && (audio.SampleRate < 8000 || audio.SampleRate > 384000) {
+		return fmt.Errorf("audio: 'sample_rate' must be between 8000 and 384000, got: %d", audio.SampleRate)
+	}
+
+	if audio.Quantum != 0 {
+		if audio.Quantum < MinQuantum || audio.Quantum > MaxQuantum || audio.Quantum&(audio.Quantum-1) != 0 {
+			return fmt.Errorf("audio: 'quantum' must be a power of two between %d and %d, got: %d", MinQuantum, MaxQuantum, audio.Quantum)
+		}
+	}
+
+	switch audio.ClockCheck {
+	case "", ClockCheckWarn, ClockCheckRefuse, ClockCheckOff:
+	default:
+		return fmt.Errorf("audio: invalid 'clock_check' '%s', must be '%s', '%s' or '%s'", audio.ClockCheck, ClockCheckWarn, ClockCheckRefuse, ClockCheckOff)
+	}
+
+	return nil
+}
+
+// validateCapture checks a capture section against the codecs and bit depths FFmpeg supports
+func validateCapture(capture *CaptureConfig) error {
+	if capture == nil {
+		return nil
+	}
+
+	codec := capture.Codec
+	if codec == "" {
+		codec = DefaultCaptureCodec
+	}
+	depths, supported := captureCodecs[codec]
+	if !supported {
+		codecs := make([]string, 0, len(captureCodecs))
+		for name := range captureCodecs {
+			codecs = append(codecs, name)
+		}
+		sort.Strings(codecs)
+		return fmt.Errorf("capture: unsupported 'codec' '%s', must be one of: %s", capture.Codec, strings.Join(codecs, ", "))
+	}
+
+	if capture.BitDepth != 0 {
+		valid := false
+		for _, depth := range depths {
+			valid = valid || depth == capture.BitDepth
+		}
+		if !valid {
+			return fmt.Errorf("capture: codec '%s' does not support a 'bit_depth' of %d, supported: %v", codec, capture.BitDepth, depths)
+		}
+	}
+
+	if capture.CompressionLevel != nil {
+		if codec != "flac" {
+			return fmt.Errorf("capture: 'compression_level' only applies to flac, got codec '%s'", codec)
+		}
+		if *capture.CompressionLevel < 0 || *capture.CompressionLevel > MaxFLACCompressionLevel {
+			return fmt.Errorf("capture: 'compression_level' must be between 0 and %d, got: %d", MaxFLACCompressionLevel, *capture.CompressionLevel)
+		}
+	}
+
+	return nil
+}
+
+// validateMetronome validates a metronome section
+func validateMetronome(metronome *MetronomeConfig) error {
+	if metronome == nil {
+		return nil
+	}
+
+	if metronome.BPM < MinBPM || metronome.BPM > MaxBPM {
+		return fmt.Errorf("metronome: 'bpm' must be between %.0f and %.0f, got: %.2f", MinBPM, MaxBPM, metronome.BPM)
+	}
+	if metronome.TimeSignature != "" {
+		if _, _, err := parseTimeSignature(metronome.TimeSignature); err != nil {
+			return fmt.Errorf("metronome: %w", err)
+		}
+	}
+	if metronome.CountIn < 0 || metronome.CountIn > MaxCountIn {
+		return fmt.Errorf("metronome: 'count_in' must be between 0 and %d bars, got: %d", MaxCountIn, metronome.CountIn)
+	}
+	if metronome.Volume < 0 || metronome.Volume > 1 {
+		return fmt.Errorf("metronome: 'volume' must be between 0 and 1, got: %.2f", metronome.Volume)
+	}
+
+	return nil
+}
+
+// validateMetronomeSession checks the metronome against the rest of a
+// resolved profile: the click track needs its name, and a count-in needs a
+// take that starts when it is told to
+func validateMetronomeSession(cfg *Config) error {
+	if cfg.Metronome == nil {
+		return nil
+	}
+
+	if cfg.Metronome.Record {
+		for _, channel := range cfg.Channels {
+			if channel.Name == MetronomeChannel {
+				return fmt.Errorf("metronome: 'record' adds a '%s' track, rename the channel of that name", MetronomeChannel)
+			}
+		}
+	}
+	if cfg.Metronome.CountIn > 0 && cfg.GetTrigger().Mode == TriggerSignal {
+		return fmt.Errorf("metronome: 'count_in' cannot hold a take started by a signal trigger")
+	}
+
+	return nil
+}
+
+// ValidateMetronomeOverride checks the tempo and count-in requested for a
+// single session. A bpm of 0 and a nil countIn keep the profile's, and a
+// tempo alone enables the metronome on a profile without one.
+func (c *Config) ValidateMetronomeOverride(bpm float64, countIn *int) error {
+	if c.Metronome == nil && bpm == 0 {
+		if countIn != nil && *countIn > 0 {
+			return fmt.Errorf("metronome: a count-in needs a 'bpm' or a metronome in the profile")
+		}
+		return nil
+	}
+
+	metronome := MetronomeConfig{}
+	if c.Metronome != nil {
+		metronome = *c.Metronome
+	}
+	if bpm != 0 {
+		metronome.BPM = bpm
+	}
+	if countIn != nil {
+		metronome.CountIn = *countIn
+	}
+
+	if err := validateMetronome(&metronome); err != nil {
+		return err
+	}
+	session := *c
+	session.Metronome = &metronome
+	return validateMetronomeSession(&session)
+}
+
+// validateAlign validates an align section
+func validateAlign(align *AlignConfig) error {
+	if align == nil {
+		return nil
+	}
+
+	if align.MaxOffset < 0 || align.MaxOffset > MaxAlignOffset {
+		return fmt.Errorf("align: 'max_offset' must be between 0 and %d ms, got: %d", MaxAlignOffset, align.MaxOffset)
+	}
+	if align.MinConfidence < 0 || align.MinConfidence > 1 {
+		return fmt.Errorf("align: 'min_confidence' must be between 0 and 1, got: %.2f", align.MinConfidence)
+	}
+
+	return nil
+}
+
+// validateAlignSession checks that the reference of an alignment is an input
+// channel of the resolved profile
+func validateAlignSession(cfg *Config) error {
+	if cfg.Align == nil {
+		return nil
+	}
+
+	reference := cfg.GetAlign().Reference
+	if reference == "" {
+		return fmt.Errorf("align: requires at least one input channel")
+	}
+	for _, channel := range cfg.Channels {
+		if channel.Name == reference {
+			if channel.Type != "input" {
+				return fmt.Errorf("align: 'reference' must be an input channel, '%s' is a %s channel", reference, channel.Type)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("align: 'reference' channel '%s' not found in the profile", reference)
+}
+
+// validateSegments validates a segments section
+func validateSegments(segments *SegmentsConfig) error {
+	if segments == nil {
+		return nil
+	}
+
+	if segments.Duration != 0 && segments.Duration < MinSegmentDuration {
+		return fmt.Errorf("segments: 'duration' must be at least %.0f second, got: %.2f", MinSegmentDuration, segments.Duration)
+	}
+
+	return nil
+}
+
+// validateReady validates a ready section
+func validateReady(ready *ReadyConfig) error {
+	if ready == nil {
+		return nil
+	}
+
+	if ready.Timeout != "" && ready.Timeout != ReadyTimeoutNever {
+		timeout, err := time.ParseDuration(ready.Timeout)
+		if err != nil {
+			return fmt.Errorf("ready: invalid 'timeout' '%s', use a duration such as 30s or 10m, or '%s'", ready.Timeout, ReadyTimeoutNever)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("ready: 'timeout' must be positive, got: %s", ready.Timeout)
+		}
+	}
+
+	if ready.PollInterval != "" {
+		interval, err := time.ParseDuration(ready.PollInterval)
+		if err != nil {
+			return fmt.Errorf("ready: invalid 'poll_interval' '%s', use a duration such as 500ms or 2s", ready.PollInterval)
+		}
+		if interval < MinReadyPollInterval {
+			return fmt.Errorf("ready: 'poll_interval' must be at least %s, got: %s", MinReadyPollInterval, ready.PollInterval)
+		}
+	}
+
+	switch ready.Duplicates {
+	case "", DuplicatesBlock, DuplicatesNewest, DuplicatesOldest:
+	default:
+		return fmt.Errorf("ready: invalid 'duplicates' policy '%s', must be '%s', '%s' or '%s'", ready.Duplicates, DuplicatesBlock, DuplicatesNewest, DuplicatesOldest)
+	}
+
+	return nil
+}
+
+// profileChannelNames returns the effective channel names of a config profile
+func profileChannelNames(profile *ConfigProfile) []string {
+	names := make([]string, 0, len(profile.Channels))
+	for _, chRef := range profile.Channels {
+		if chRef.Name != "" {
+			names = append(names, chRef.Name)
+		} else {
+			names = append(names, chRef.Ref)
+		}
+	}
+	return names
+}
+
 // ExtractDeviceAndPort splits a JACK port specification into device and port components
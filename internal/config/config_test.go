@@ -3,7 +3,9 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 	"io/ioutil"
 )
 
@@ -93,7 +95,7 @@ func TestMergeConfigs_SelectionAndFallback(t *testing.T) {
 			t.Errorf("Expected guitar volume 2.0, got %.1f", guitarChannel.Volume)
 		}
 		if guitarChannel.Delay != 200 {
-			t.Errorf("Expected guitar delay 200ms, got %d", guitarChannel.Delay)
+			t.Errorf("Expected guitar delay 200ms, got %g", guitarChannel.Delay)
 		}
 	}
 
@@ -115,7 +117,7 @@ func TestMergeConfigs_SelectionAndFallback(t *testing.T) {
 			t.Errorf("Expected monitor_left volume 0 (profile-specific), got %.1f", monitorChannel.Volume)
 		}
 		if monitorChannel.Delay != 0 {
-			t.Errorf("Expected monitor_left delay 0 (profile-specific), got %d", monitorChannel.Delay)
+			t.Errorf("Expected monitor_left delay 0 (profile-specific), got %g", monitorChannel.Delay)
 		}
 	}
 
@@ -232,7 +234,7 @@ func TestMergeConfigs_ChannelOverride(t *testing.T) {
 
 	guitarDelay := result.GetChannelDelay("guitar")
 	if guitarDelay != 0 {
-		t.Errorf("Expected guitar delay 0, got %d", guitarDelay)
+		t.Errorf("Expected guitar delay 0, got %g", guitarDelay)
 	}
 
 	// Check that source and type were inherited
@@ -352,6 +354,70 @@ func TestBuildMixFilter(t *testing.T) {
 			expectedFilter:  "[0:0]volume=2.0,aformat=channel_layouts=stereo[ch_guitar];[0:1]volume=0.8[ch_chrome];[ch_guitar][ch_chrome]amix=inputs=2:normalize=0[mixed];[mixed]alimiter=limit=0.9:attack=7:release=150",
 			expectedOutputs: 2,
 		},
+		{
+			name: "5.1 layout downmixed with delay",
+			channels: []Channel{
+				{Name: "film", Sources: []string{"a:FL", "a:FR", "a:FC", "a:LFE", "a:BL", "a:BR"}, AudioMode: "5.1", Type: "monitor", Volume: 1.0, Delay: 20},
+			},
+			expectedFilter:  "[0:0]volume=1.0,adelay=20|20|20|20|20|20,channelmap=channel_layout=5.1,aformat=channel_layouts=stereo",
+			expectedOutputs: 2,
+		},
+		{
+			name: "fractional delay in samples",
+			channels: []Channel{
+				{Name: "guitar", Sources: []string{"system:capture_1"}, AudioMode: "mono", Type: "input", Volume: 1.0, Delay: 2.5},
+			},
+			expectedFilter:  "[0:0]volume=1.0,adelay=120S,aformat=channel_layouts=stereo",
+			expectedOutputs: 2,
+		},
+		{
+			name: "late stereo channel pulled earlier by samples",
+			channels: []Channel{
+				{Name: "chrome", Sources: []string{"Chrome:output_FL", "Chrome:output_FR"}, AudioMode: "stereo", Type: "monitor", Volume: 1.0, DelaySamples: -37},
+			},
+			expectedFilter:  "[0:0]volume=1.0,atrim=start_sample=37,asetpts=PTS-STARTPTS",
+			expectedOutputs: 2,
+		},
+		{
+			name: "mono panned left",
+			channels: []Channel{
+				{Name: "guitar", Sources: []string{"system:capture_1"}, AudioMode: "mono", Type: "input", Volume: 1.0, Pan: -0.5},
+			},
+			expectedFilter:  "[0:0]volume=1.0,pan=stereo|c0=0.924*c0|c1=0.383*c0",
+			expectedOutputs: 2,
+		},
+		{
+			name: "stereo balanced right and widened",
+			channels: []Channel{
+				{Name: "chrome", Sources: []string{"Chrome:output_FL", "Chrome:output_FR"}, AudioMode: "stereo", Type: "monitor", Volume: 1.0, Pan: 0.5, Width: &[]float64{1.5}[0]},
+			},
+			expectedFilter:  "[0:0]volume=1.0,pan=stereo|c0=0.625*c0-0.125*c1|c1=-0.250*c0+1.250*c1",
+			expectedOutputs: 2,
+		},
+		{
+			name: "5.1 layout narrowed to mono",
+			channels: []Channel{
+				{Name: "film", Sources: []string{"a:FL", "a:FR", "a:FC", "a:LFE", "a:BL", "a:BR"}, AudioMode: "5.1", Type: "monitor", Volume: 1.0, Width: &[]float64{0}[0]},
+			},
+			expectedFilter:  "[0:0]volume=1.0,channelmap=channel_layout=5.1,aformat=channel_layouts=stereo,pan=stereo|c0=0.500*c0+0.500*c1|c1=0.500*c0+0.500*c1",
+			expectedOutputs: 2,
+		},
+		{
+			name: "early stereo channel trimmed",
+			channels: []Channel{
+				{Name: "chrome", Sources: []string{"Chrome:output_FL", "Chrome:output_FR"}, AudioMode: "stereo", Type: "monitor", Volume: 1.0, Delay: -35},
+			},
+			expectedFilter:  "[0:0]volume=1.0,atrim=start=0.035,asetpts=PTS-STARTPTS",
+			expectedOutputs: 2,
+		},
+		{
+			name: "drum group centred",
+			channels: []Channel{
+				{Name: "drums", Sources: []string{"system:capture_1", "system:capture_2", "system:capture_3", "system:capture_4"}, AudioMode: "group", Type: "input", Volume: 1.0},
+			},
+			expectedFilter:  "[0:0]volume=1.0,pan=stereo|c0=0.707*c0+0.707*c1+0.707*c2+0.707*c3|c1=0.707*c0+0.707*c1+0.707*c2+0.707*c3",
+			expectedOutputs: 2,
+		},
 	}
 
 	for _, tt := range tests {
@@ -370,6 +436,75 @@ func TestBuildMixFilter(t *testing.T) {
 	}
 }
 
+func TestLoadWithProfile_PanAndWidth(t *testing.T) {
+	configContent := `
+active_config: panned
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 1.0
+          pan: -0.3
+        - id: chrome
+          sources: ["Chrome:output_FL", "Chrome:output_FR"]
+          audioMode: stereo
+          type: monitor
+          volume: 1.0
+          width: 0.5
+configs:
+    panned:
+        channels:
+            - ref: guitar
+            - ref: chrome
+              pan: 0.4
+    wide_guitar:
+        channels:
+            - ref: guitar
+              width: 1.5
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "panned")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if guitar := cfg.Channels[0]; guitar.Pan != -0.3 || guitar.StereoWidth() != 1 {
+		t.Errorf("Expected the guitar's own pan at full width, got %+v", guitar)
+	}
+	if chrome := cfg.Channels[1]; chrome.Pan != 0.4 || chrome.StereoWidth() != 0.5 {
+		t.Errorf("Expected the chrome pan override at half width, got %+v", chrome)
+	}
+
+	if _, err := LoadWithProfile(configFile, "wide_guitar"); err == nil || !containsSubstring(err.Error(), "'width' needs a stereo or surround source") {
+		t.Errorf("Expected a width on a mono channel to fail, got: %v", err)
+	}
+}
+
+func TestBuildMixFilters_Pan(t *testing.T) {
+	cfg := &Config{Channels: []Channel{
+		{Name: "guitar", Sources: []string{"system:capture_1"}, AudioMode: "mono", Type: "input", Volume: 1.0, Pan: -1},
+		{Name: "mic", Sources: []string{"system:capture_2"}, AudioMode: "mono", Type: "input", Volume: 1.0, Pan: 1},
+	}}
+	analysis := &MKVAnalysis{Tracks: []TrackInfo{{Index: 0, Channels: 1}, {Index: 1, Channels: 1}}}
+
+	filters := map[string]string{}
+	filters["BuildMixFilter"], _ = cfg.BuildMixFilter()
+	filters["BuildMixFilterWithGlobalVolume"], _ = cfg.BuildMixFilterWithGlobalVolume(1.5)
+	filters["BuildMixFilterForFile"], _ = cfg.BuildMixFilterForFile(analysis)
+	filters["BuildMixFilterForFileWithGlobalVolume"], _ = cfg.BuildMixFilterForFileWithGlobalVolume(analysis, 1.5)
+
+	// Hard left and hard right, each at full level on its side
+	for name, filter := range filters {
+		if !strings.Contains(filter, "[0:0]volume=1.0,pan=stereo|c0=1.000*c0|c1=0.000*c0[") ||
+			!strings.Contains(filter, "[0:1]volume=1.0,pan=stereo|c0=0.000*c0|c1=1.000*c0[") {
+			t.Errorf("%s: expected the guitar left and the mic right, got '%s'", name, filter)
+		}
+	}
+}
+
 func TestGlobalsRecordingsDirectory(t *testing.T) {
 	// Create a temporary config file with globals section
 	configContent := `
@@ -475,3 +610,708 @@ configs:
 		t.Errorf("Expected directory '%s' from globals, got '%s'", expectedDir, cfg.Output.Directory)
 	}
 }
+
+func TestLoadWithProfile_FakeBackend(t *testing.T) {
+	configContent := `
+active_config: test
+audio:
+    backend: fake
+    sample_rate: 48000
+    fake:
+        ports: ["system:capture_1"]
+        signals:
+            - port: system:capture_1
+              frequency: 880
+            - port: alsa_input.usb-Focusrite.analog-stereo:capture_FR
+              file: /tmp/guitar.wav
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+configs:
+    test:
+        channels:
+            - ref: guitar
+        output:
+            directory: /tmp/jamcapture-fake
+            format: flac
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "test")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if cfg.Audio.Backend != "fake" {
+		t.Errorf("Expected backend 'fake', got '%s'", cfg.Audio.Backend)
+	}
+	if cfg.Audio.Fake == nil {
+		t.Fatal("Expected fake section to be inherited from global audio settings")
+	}
+	if len(cfg.Audio.Fake.Ports) != 1 || cfg.Audio.Fake.Ports[0] != "system:capture_1" {
+		t.Errorf("Unexpected fake ports: %v", cfg.Audio.Fake.Ports)
+	}
+	if len(cfg.Audio.Fake.Signals) != 2 {
+		t.Fatalf("Expected 2 fake signals, got %d", len(cfg.Audio.Fake.Signals))
+	}
+	// Port names containing dots must survive as list values
+	if cfg.Audio.Fake.Signals[1].Port != "alsa_input.usb-Focusrite.analog-stereo:capture_FR" || cfg.Audio.Fake.Signals[1].File != "/tmp/guitar.wav" {
+		t.Errorf("Unexpected second fake signal: %+v", cfg.Audio.Fake.Signals[1])
+	}
+}
+
+func TestLoadWithProfile_TriggerInheritedFromDefault(t *testing.T) {
+	configContent := `
+active_config: live
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+configs:
+    default:
+        channels:
+            - ref: guitar
+        trigger:
+            mode: signal
+            channel: guitar
+            pre_roll: 2.5
+    live:
+        channels:
+            - ref: guitar
+        output:
+            directory: /tmp/jamcapture-trigger
+            format: flac
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "live")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	trigger := cfg.GetTrigger()
+	if trigger.Mode != TriggerSignal || trigger.Channel != "guitar" || trigger.PreRoll != 2.5 {
+		t.Errorf("Expected signal trigger on guitar with 2.5s pre-roll, got %+v", trigger)
+	}
+	if trigger.Threshold != DefaultTriggerThreshold {
+		t.Errorf("Expected default threshold %.0f, got %.1f", DefaultTriggerThreshold, trigger.Threshold)
+	}
+
+	if mode := (&Config{}).GetTrigger().Mode; mode != TriggerSources {
+		t.Errorf("Expected '%s' trigger without a trigger section, got '%s'", TriggerSources, mode)
+	}
+}
+
+func TestLoadWithProfile_AutoSplitNeedsInputChannel(t *testing.T) {
+	configContent := `
+active_config: jam
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+        - id: chrome
+          sources: ["Chrome:output_FL"]
+          type: monitor
+          volume: 0.8
+configs:
+    jam:
+        channels:
+            - ref: guitar
+            - ref: chrome
+        auto_split:
+            silence: 20
+            stop_after: 10
+    backing:
+        channels:
+            - ref: chrome
+        auto_split:
+            silence: 20
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "jam")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	autoSplit := cfg.GetAutoSplit()
+	if autoSplit == nil || autoSplit.Silence != 20 || autoSplit.StopAfter != 10 || autoSplit.Threshold != DefaultSilenceThreshold {
+		t.Errorf("Expected 20s split, 10min stop and default threshold, got %+v", autoSplit)
+	}
+
+	if _, err := LoadWithProfile(configFile, "backing"); err == nil || !containsSubstring(err.Error(), "requires at least one input channel") {
+		t.Errorf("Expected auto_split without input channels to fail, got: %v", err)
+	}
+}
+
+func TestLoadWithProfile_ReadyNeverTimesOut(t *testing.T) {
+	configContent := `
+active_config: rehearsal
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+configs:
+    rehearsal:
+        channels:
+            - ref: guitar
+        ready:
+            timeout: never
+            duplicates: newest
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "rehearsal")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ready := cfg.GetReady()
+	if ready.TimeoutDuration() != 0 || ready.Duplicates != DuplicatesNewest {
+		t.Errorf("Expected no timeout and the newest duplicate, got %+v", ready)
+	}
+	if ready.PollDuration() != DefaultReadyPollInterval {
+		t.Errorf("Expected the default poll interval, got %s", ready.PollDuration())
+	}
+
+	defaults := (&Config{}).GetReady()
+	if defaults.TimeoutDuration() != DefaultReadyTimeout || defaults.Duplicates != DuplicatesBlock {
+		t.Errorf("Expected a %s timeout blocking duplicates by default, got %+v", DefaultReadyTimeout, defaults)
+	}
+}
+
+func TestLoadWithProfile_ChannelDuplicateRule(t *testing.T) {
+	configContent := `
+active_config: jam
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+        - id: youtube
+          sources: ["Chrome:output_FL"]
+          type: monitor
+          volume: 0.8
+          duplicates:
+              policy: match
+              match:
+                  - property: media.name
+                    value: YouTube
+configs:
+    jam:
+        channels:
+            - ref: guitar
+            - ref: youtube
+        ready:
+            duplicates: oldest
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "jam")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	rule := cfg.GetDuplicateRule(cfg.Channels[1])
+	if rule.Policy != DuplicatesMatch || len(rule.Match) != 1 || rule.Match[0].Property != "media.name" || rule.Match[0].Value != "YouTube" {
+		t.Errorf("Expected the youtube channel to match media.name=YouTube, got %+v", rule)
+	}
+	if rule := cfg.GetDuplicateRule(cfg.Channels[0]); rule.Policy != DuplicatesOldest {
+		t.Errorf("Expected the guitar channel to fall back to the ready policy, got %+v", rule)
+	}
+}
+
+func TestLoadWithProfile_SourcePatterns(t *testing.T) {
+	configContent := `
+active_config: jam
+definitions:
+    channels:
+        - id: guitar
+          sources: [{device.product.name: "Scarlett 2i2", port: capture_FR}]
+          type: input
+          volume: 4.0
+        - id: chrome
+          sources: ["Chrome*:output_FL", "re:^Chrome.*:output_FR$"]
+          audioMode: stereo
+          type: monitor
+          volume: 0.8
+configs:
+    jam:
+        channels:
+            - ref: guitar
+            - ref: chrome
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "jam")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	selector, err := ParseSource(cfg.Channels[0].Sources[0])
+	if err != nil {
+		t.Fatalf("Failed to parse selector %q: %v", cfg.Channels[0].Sources[0], err)
+	}
+	if selector.Kind != SourceSelector || len(selector.Properties) != 1 || selector.Properties[0].Value != "Scarlett 2i2" {
+		t.Errorf("Expected a device.product.name selector, got %+v", selector)
+	}
+
+	for i, expected := range []string{SourceGlob, SourceRegex} {
+		pattern, err := ParseSource(cfg.Channels[1].Sources[i])
+		if err != nil || pattern.Kind != expected {
+			t.Errorf("Expected source[%d] to be a %s, got %+v (%v)", i, expected, pattern, err)
+		}
+	}
+}
+
+func TestParseSource_MatchName(t *testing.T) {
+	tests := []struct {
+		source   string
+		port     string
+		expected bool
+	}{
+		{"system:capture_1", "system:capture_1", true},
+		{"system:capture_1", "system:capture_10", false},
+		{"alsa_input.usb-Focusrite_Scarlett_2i2_*:capture_FR", "alsa_input.usb-Focusrite_Scarlett_2i2_USB_Y814JK8264026F-00.analog-stereo:capture_FR", true},
+		{"alsa_input.usb-Focusrite_Scarlett_2i2_*:capture_FR", "alsa_input.usb-Focusrite_Scarlett_2i2_USB_Y814JK8264026F-00.analog-stereo:capture_FL", false},
+		{"system:capture_?", "system:capture_2", true},
+		{"re:^Chrome( \\(\\d+\\))?:output_FL$", "Chrome (2):output_FL", true},
+		{"re:^Chrome:output_FL$", "Chromium:output_FL", false},
+		{"re:Chrome.*:output_FL", "Chrome (2):output_FL", true},
+		{"re:Chrome", "Chromium:output_FL", false},
+		{"re:output_FL", "Chrome:output_FL", false},
+		{"re:Chrome:output_FL|Firefox:output_FL", "Firefox:output_FL", true},
+		{"re:Chrome:output_FL|Firefox:output_FL", "Chrome:output_FL_2", false},
+		{`{"device.product.name": "Scarlett 2i2", "port": "capture_*"}`, "alsa_input.usb-Focusrite:capture_FR", true},
+		{`{"device.product.name": "Scarlett 2i2", "port": "capture_FR"}`, "alsa_input.usb-Focusrite:capture_FL", false},
+	}
+
+	for _, tt := range tests {
+		pattern, err := ParseSource(tt.source)
+		if err != nil {
+			t.Fatalf("ParseSource(%q) failed: %v", tt.source, err)
+		}
+		if got := pattern.MatchName(tt.port); got != tt.expected {
+			t.Errorf("%q matching %q: expected %v, got %v", tt.source, tt.port, tt.expected, got)
+		}
+	}
+}
+
+func TestLoadWithProfile_SegmentsInheritedFromDefault(t *testing.T) {
+	configContent := `
+active_config: live
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+configs:
+    default:
+        channels:
+            - ref: guitar
+        segments:
+            duration: 30
+    live:
+        channels:
+            - ref: guitar
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "live")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if segments := cfg.GetSegments(); segments == nil || segments.Duration != 30 {
+		t.Errorf("Expected 30 second segments inherited from default, got %+v", segments)
+	}
+	if segments := (&Config{Segments: &SegmentsConfig{}}).GetSegments(); segments.Duration != DefaultSegmentDuration {
+		t.Errorf("Expected %.0f second segments by default, got %+v", DefaultSegmentDuration, segments)
+	}
+	if (&Config{}).GetSegments() != nil {
+		t.Error("Expected a single file when segments are not configured")
+	}
+}
+
+func TestLoadWithProfile_CaptureEncoderArgs(t *testing.T) {
+	configContent := `
+active_config: studio
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+configs:
+    default:
+        output:
+            format: mp3
+        channels:
+            - ref: guitar
+        capture:
+            codec: flac
+            bit_depth: 24
+            compression_level: 0
+    studio:
+        channels:
+            - ref: guitar
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "studio")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	expected := "-c:a flac -sample_fmt s32 -bits_per_raw_sample 24 -compression_level 0"
+	if args := strings.Join(cfg.GetCapture().EncoderArgs(), " "); args != expected {
+		t.Errorf("Expected capture args '%s', got '%s'", expected, args)
+	}
+	if cfg.Output.Format != "mp3" {
+		t.Errorf("Expected the mixdown format to stay mp3, got '%s'", cfg.Output.Format)
+	}
+
+	if args := strings.Join((&Config{}).GetCapture().EncoderArgs(), " "); args != "-c:a flac" {
+		t.Errorf("Expected lossless flac capture by default, got '%s'", args)
+	}
+	pcm := CaptureConfig{Codec: "pcm_f32le", BitDepth: 32}
+	if args := strings.Join(pcm.EncoderArgs(), " "); args != "-c:a pcm_f32le" {
+		t.Errorf("Expected PCM capture to only set the codec, got '%s'", args)
+	}
+}
+
+func TestLoadWithProfile_PlaybackChannel(t *testing.T) {
+	configContent := `
+active_config: studio
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+        - id: backing
+          type: playback
+          volume: 0.6
+configs:
+    studio:
+        channels:
+            - ref: guitar
+            - ref: backing
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "studio")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	backing := cfg.Channels[1]
+	if backing.Type != ChannelTypePlayback || len(backing.Sources) != 0 || backing.AudioMode != "stereo" {
+		t.Errorf("Expected a stereo playback channel without sources, got %+v", backing)
+	}
+
+	// The playback channel has no sources but is still recorded and mixed
+	filter, _ := cfg.BuildMixFilter()
+	if !strings.Contains(filter, "[0:1]volume=0.6[ch_backing]") {
+		t.Errorf("Expected the backing track in the mix, got '%s'", filter)
+	}
+}
+
+func TestLoadWithProfile_Metronome(t *testing.T) {
+	configContent := `
+active_config: click
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+configs:
+    default:
+        channels:
+            - ref: guitar
+        metronome:
+            bpm: 90
+            time_signature: 6/8
+    click:
+        channels:
+            - ref: guitar
+        metronome:
+            bpm: 120
+            count_in: 2
+            record: true
+    cued:
+        channels:
+            - ref: guitar
+        trigger:
+            mode: signal
+            channel: guitar
+        metronome:
+            bpm: 120
+            count_in: 1
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "click")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	metronome := cfg.GetMetronome()
+	if metronome == nil || metronome.BPM != 120 || metronome.CountIn != 2 || !metronome.Record {
+		t.Fatalf("Expected the profile's metronome, got %+v", metronome)
+	}
+	if metronome.TimeSignature != DefaultTimeSignature || metronome.Volume != DefaultMetronomeVolume {
+		t.Errorf("Expected default time signature and volume, got %+v", metronome)
+	}
+
+	// Two bars of 4/4 at 120 bpm
+	if duration := metronome.CountInDuration(); duration != 4*time.Second {
+		t.Errorf("Expected a 4s count-in, got %s", duration)
+	}
+
+	if _, err := LoadWithProfile(configFile, "cued"); err == nil || !containsSubstring(err.Error(), "signal trigger") {
+		t.Errorf("Expected a count-in with a signal trigger to fail, got: %v", err)
+	}
+	if (&Config{}).GetMetronome() != nil {
+		t.Error("Expected no metronome without a metronome section")
+	}
+}
+
+func TestLoadWithProfile_Align(t *testing.T) {
+	configContent := `
+active_config: aligned
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 1.0
+        - id: mic
+          sources: ["system:capture_2"]
+          type: input
+          volume: 1.0
+        - id: chrome
+          sources: ["Chrome:output_FL", "Chrome:output_FR"]
+          type: monitor
+          audiomode: stereo
+          volume: 1.0
+          delay: 250
+configs:
+    default:
+        channels:
+            - ref: guitar
+            - ref: chrome
+        align:
+            max_offset: 800
+    aligned:
+        channels:
+            - ref: guitar
+            - ref: mic
+            - ref: chrome
+        align:
+            reference: mic
+    on_monitor:
+        channels:
+            - ref: guitar
+            - ref: chrome
+        align:
+            reference: chrome
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "aligned")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	align := cfg.GetAlign()
+	if align == nil || align.Reference != "mic" {
+		t.Fatalf("Expected the profile's alignment on mic, got %+v", align)
+	}
+	if align.MaxOffset != DefaultAlignMaxOffset || align.MinConfidence != DefaultAlignMinConfidence {
+		t.Errorf("Expected default offset and confidence, got %+v", align)
+	}
+
+	// Without a reference the first input channel is used
+	cfg, err = LoadWithProfile(configFile, "default")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if align := cfg.GetAlign(); align.Reference != "guitar" || align.MaxOffset != 800 {
+		t.Errorf("Expected an alignment on guitar up to 800 ms, got %+v", align)
+	}
+
+	if _, err := LoadWithProfile(configFile, "on_monitor"); err == nil || !containsSubstring(err.Error(), "must be an input channel") {
+		t.Errorf("Expected a monitor reference to fail, got: %v", err)
+	}
+	if (&Config{}).GetAlign() != nil {
+		t.Error("Expected no alignment without an align section")
+	}
+}
+
+func TestConfig_ValidateMetronomeOverride(t *testing.T) {
+	bars, tooMany := 1, MaxCountIn+1
+
+	plain := &Config{}
+	if err := plain.ValidateMetronomeOverride(100, &bars); err != nil {
+		t.Errorf("Expected a tempo to enable the metronome, got: %v", err)
+	}
+	if err := plain.ValidateMetronomeOverride(0, &bars); err == nil {
+		t.Error("Expected a count-in without tempo to fail")
+	}
+
+	click := &Config{Metronome: &MetronomeConfig{BPM: 120}}
+	if err := click.ValidateMetronomeOverride(0, &tooMany); err == nil {
+		t.Error("Expected too long a count-in to fail")
+	}
+	if err := click.ValidateMetronomeOverride(10, nil); err == nil {
+		t.Error("Expected 10 bpm to fail")
+	}
+
+	cued := &Config{Metronome: &MetronomeConfig{BPM: 120}, Trigger: &TriggerConfig{Mode: TriggerSignal}}
+	if err := cued.ValidateMetronomeOverride(0, &bars); err == nil {
+		t.Error("Expected a count-in with a signal trigger to fail")
+	}
+}
+
+func TestSaveChannelDelay(t *testing.T) {
+	configContent := `
+active_config: studio
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+        - id: chrome
+          sources: ["Chrome:output_FL", "Chrome:output_FR"]
+          audioMode: stereo
+          type: monitor
+          volume: 0.8
+          delay: 250
+configs:
+    studio:
+        channels:
+            - ref: guitar
+            - ref: chrome
+    bluetooth:
+        channels:
+            - ref: guitar
+            - ref: chrome
+              name: headphones
+              delay_samples: 19200
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	bluetooth, err := LoadWithProfile(configFile, "bluetooth")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if delay := bluetooth.GetChannelDelay("headphones"); delay != 400 {
+		t.Errorf("Expected 19200 samples to be 400ms at 48 kHz, got %g", delay)
+	}
+
+	// The active profile takes the delay of the definition
+	updated, err := SaveChannelDelay(configFile, "", "chrome", 187)
+	if err != nil || updated != "definition 'chrome'" {
+		t.Fatalf("Expected the chrome definition to be updated, got '%s' (%v)", updated, err)
+	}
+
+	// A reference overriding the delay keeps overriding it, in milliseconds
+	updated, err = SaveChannelDelay(configFile, "bluetooth", "headphones", -12.5)
+	if err != nil || updated != "profile 'bluetooth'" {
+		t.Fatalf("Expected the bluetooth profile to be updated, got '%s' (%v)", updated, err)
+	}
+
+	studio, err := LoadWithProfile(configFile, "studio")
+	if err != nil {
+		t.Fatalf("Failed to reload configuration: %v", err)
+	}
+	if delay := studio.Channels[1].Delay; delay != 187 {
+		t.Errorf("Expected the studio chrome delay to be 187, got %g", delay)
+	}
+	bluetooth, err = LoadWithProfile(configFile, "bluetooth")
+	if err != nil {
+		t.Fatalf("Failed to reload configuration: %v", err)
+	}
+	if channel := bluetooth.Channels[1]; channel.Delay != -12.5 || channel.DelaySamples != 0 || channel.AudioMode != "stereo" {
+		t.Errorf("Expected the stereo headphones channel at -12.5ms, got %+v", channel)
+	}
+
+	if _, err := SaveChannelDelay(configFile, "studio", "piano", 10); err == nil {
+		t.Error("Expected an unknown channel to fail")
+	}
+}
+
+func TestLoadWithProfile_AudioClockSettings(t *testing.T) {
+	configContent := `
+active_config: studio
+audio:
+    sample_rate: 44100
+    quantum: 512
+    clock_check: refuse
+definitions:
+    channels:
+        - id: guitar
+          sources: ["system:capture_1"]
+          type: input
+          volume: 4.0
+configs:
+    studio:
+        audio:
+            sample_rate: 96000
+        channels:
+            - ref: guitar
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "studio")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if cfg.GetSampleRate() != 96000 || cfg.GetQuantum() != 512 || cfg.GetClockCheck() != ClockCheckRefuse {
+		t.Errorf("Expected 96000 Hz, 512 frames and refuse, got %d Hz, %d frames and %s", cfg.GetSampleRate(), cfg.GetQuantum(), cfg.GetClockCheck())
+	}
+
+	defaults := &Config{}
+	if defaults.GetSampleRate() != DefaultSampleRate || defaults.GetQuantum() != DefaultQuantum || defaults.GetClockCheck() != ClockCheckWarn {
+		t.Errorf("Unexpected defaults: %d Hz, %d frames, %s", defaults.GetSampleRate(), defaults.GetQuantum(), defaults.GetClockCheck())
+	}
+}
@@ -253,7 +253,7 @@ definitions:
       audiomode: mono
       volume: 2.0
 `,
-			expectedErr: "'type' must be 'input' or 'monitor', got: invalid",
+			expectedErr: "'type' must be 'input', 'monitor' or 'playback', got: invalid",
 		},
 		{
 			name: "invalid audioMode",
@@ -267,7 +267,7 @@ definitions:
       audiomode: invalid
       volume: 2.0
 `,
-			expectedErr: "'audioMode' must be 'mono' or 'stereo', got: invalid",
+			expectedErr: "'audioMode' must be mono, stereo, 2.1, quad, 5.0, 5.1, 7.1 or group, got: invalid",
 		},
 		{
 			name: "zero volume",
@@ -284,7 +284,7 @@ definitions:
 			expectedErr: "'volume' must be > 0, got: 0.00",
 		},
 		{
-			name: "negative delay",
+			name: "delay out of range",
 			config: `
 definitions:
   channels:
@@ -294,9 +294,40 @@ definitions:
         - system:capture_1
       audiomode: mono
       volume: 2.0
-      delay: -100
+      delay: -12000
 `,
-			expectedErr: "'delay' must be >= 0, got: -100",
+			expectedErr: "'delay' must be between -10000 and 10000 ms, got: -12000",
+		},
+		{
+			name: "delay in milliseconds and samples",
+			config: `
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 10
+      delay_samples: 480
+`,
+			expectedErr: "set either 'delay' or 'delay_samples', not both",
+		},
+		{
+			name: "pan out of range",
+			config: `
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      pan: -1.5
+`,
+			expectedErr: "'pan' must be between -1 and 1, got: -1.50",
 		},
 		{
 			name: "stereo with one source",
@@ -321,31 +352,448 @@ definitions:
       type: input
       sources:
         - system:capture_1
-        - system:capture_2
+        - system:capture_2
+      audiomode: mono
+      volume: 2.0
+`,
+			expectedErr: "audioMode 'mono' requires exactly 1 source(s)",
+		},
+		{
+			name: "quad with three sources",
+			config: `
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+        - system:capture_2
+        - system:capture_3
+      audiomode: quad
+      volume: 2.0
+`,
+			expectedErr: "audioMode 'quad' requires exactly 4 source(s)",
+		},
+		{
+			name: "group larger than flac allows",
+			config: `
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources: [drums:capture_1, drums:capture_2, drums:capture_3, drums:capture_4, drums:capture_5, drums:capture_6, drums:capture_7, drums:capture_8, drums:capture_9]
+      audiomode: group
+      volume: 2.0
+`,
+			expectedErr: "audioMode 'group' requires between 1 and 8 sources, got 9",
+		},
+		{
+			name: "playback channel with sources",
+			config: `
+definitions:
+  channels:
+    - id: test_guitar
+      type: playback
+      sources: [Chrome:output_FL, Chrome:output_FR]
+      volume: 0.8
+`,
+			expectedErr: "playback channel takes no 'sources'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+` + tt.config + `
+configs:
+  test:
+    channels:
+      - ref: test_guitar
+`
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidChannelReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		refConfig   string
+		expectedErr string
+	}{
+		{
+			name: "missing ref",
+			refConfig: `
+    channels:
+      - volume: 2.0
+`,
+			expectedErr: "'ref' is required",
+		},
+		{
+			name: "invalid volume override",
+			refConfig: `
+    channels:
+      - ref: test_guitar
+        volume: 0
+`,
+			expectedErr: "volume override must be > 0",
+		},
+		{
+			name: "invalid delay override",
+			refConfig: `
+    channels:
+      - ref: test_guitar
+        delay_samples: -2000000
+`,
+			expectedErr: "delay override: 'delay_samples' must be between",
+		},
+		{
+			name: "delay override in milliseconds and samples",
+			refConfig: `
+    channels:
+      - ref: test_guitar
+        delay: -2.5
+        delay_samples: -120
+`,
+			expectedErr: "override either 'delay' or 'delay_samples', not both",
+		},
+		{
+			name: "width override out of range",
+			refConfig: `
+    channels:
+      - ref: test_guitar
+        width: 3
+`,
+			expectedErr: "placement override: 'width' must be between 0 and 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 0
+
+configs:
+  test:
+` + tt.refConfig
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Error("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidTrigger(t *testing.T) {
+	tests := []struct {
+		name        string
+		trigger     string
+		expectedErr string
+	}{
+		{
+			name: "unknown mode",
+			trigger: `
+      mode: clap
+`,
+			expectedErr: "'mode' must be 'sources', 'signal' or 'manual'",
+		},
+		{
+			name: "signal without channel",
+			trigger: `
+      mode: signal
+`,
+			expectedErr: "'channel' is required in signal mode",
+		},
+		{
+			name: "signal on unknown channel",
+			trigger: `
+      mode: signal
+      channel: bass
+`,
+			expectedErr: "channel 'bass' is not recorded by this config",
+		},
+		{
+			name: "positive threshold",
+			trigger: `
+      mode: signal
+      channel: test_guitar
+      threshold: 3
+`,
+			expectedErr: "'threshold' must be between -96 and 0 dBFS",
+		},
+		{
+			name: "pre-roll too long",
+			trigger: `
+      mode: manual
+      pre_roll: 120
+`,
+			expectedErr: "'pre_roll' must be between 0 and 30 seconds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 0
+
+configs:
+  test:
+    channels:
+      - ref: test_guitar
+    trigger:` + tt.trigger
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidAutoSplit(t *testing.T) {
+	tests := []struct {
+		name        string
+		autoSplit   string
+		expectedErr string
+	}{
+		{
+			name: "no policy",
+			autoSplit: `
+      threshold: -40
+`,
+			expectedErr: "'silence' or 'stop_after' is required",
+		},
+		{
+			name: "negative silence",
+			autoSplit: `
+      silence: -5
+`,
+			expectedErr: "'silence' must be >= 0",
+		},
+		{
+			name: "positive threshold",
+			autoSplit: `
+      silence: 10
+      threshold: 6
+`,
+			expectedErr: "'threshold' must be between -96 and 0 dBFS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 0
+
+configs:
+  test:
+    channels:
+      - ref: test_guitar
+    auto_split:` + tt.autoSplit
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidReady(t *testing.T) {
+	tests := []struct {
+		name        string
+		ready       string
+		expectedErr string
+	}{
+		{
+			name: "timeout without unit",
+			ready: `
+      timeout: 30
+`,
+			expectedErr: "invalid 'timeout' '30'",
+		},
+		{
+			name: "poll interval too short",
+			ready: `
+      poll_interval: 1ms
+`,
+			expectedErr: "'poll_interval' must be at least 50ms",
+		},
+		{
+			name: "unknown duplicates policy",
+			ready: `
+      duplicates: all
+`,
+			expectedErr: "invalid 'duplicates' policy 'all'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 0
+
+configs:
+  test:
+    channels:
+      - ref: test_guitar
+    ready:` + tt.ready
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidMetronome(t *testing.T) {
+	tests := []struct {
+		name        string
+		metronome   string
+		expectedErr string
+	}{
+		{
+			name: "no tempo",
+			metronome: `
+      count_in: 1
+`,
+			expectedErr: "'bpm' must be between 20 and 400",
+		},
+		{
+			name: "unknown beat unit",
+			metronome: `
+      bpm: 120
+      time_signature: 4/3
+`,
+			expectedErr: "'time_signature' must have a beat unit",
+		},
+		{
+			name: "count-in too long",
+			metronome: `
+      bpm: 120
+      count_in: 12
+`,
+			expectedErr: "'count_in' must be between 0 and 8 bars",
+		},
+		{
+			name: "volume above unity",
+			metronome: `
+      bpm: 120
+      volume: 1.5
+`,
+			expectedErr: "'volume' must be between 0 and 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
       audiomode: mono
       volume: 2.0
-`,
-			expectedErr: "audioMode 'mono' requires exactly 1 source(s)",
-		},
-	}
+      delay: 0
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			fullConfig := `
-active_config: test
-` + tt.config + `
 configs:
   test:
     channels:
       - ref: test_guitar
-`
+    metronome:` + tt.metronome
 
 			configFile := createTempConfig(t, fullConfig)
 			defer os.Remove(configFile)
 
 			_, err := ValidateConfigurationFormat(configFile)
 			if err == nil {
-				t.Error("Expected error but got none")
+				t.Fatal("Expected error but got none")
 			}
 
 			if !containsSubstring(err.Error(), tt.expectedErr) {
@@ -355,37 +803,25 @@ configs:
 	}
 }
 
-func TestValidateConfigurationFormat_InvalidChannelReference(t *testing.T) {
+func TestValidateConfigurationFormat_InvalidAlign(t *testing.T) {
 	tests := []struct {
 		name        string
-		refConfig   string
+		align       string
 		expectedErr string
 	}{
 		{
-			name: "missing ref",
-			refConfig: `
-    channels:
-      - volume: 2.0
-`,
-			expectedErr: "'ref' is required",
-		},
-		{
-			name: "invalid volume override",
-			refConfig: `
-    channels:
-      - ref: test_guitar
-        volume: 0
+			name: "negative offset",
+			align: `
+      max_offset: -10
 `,
-			expectedErr: "volume override must be > 0",
+			expectedErr: "'max_offset' must be between 0 and 5000 ms",
 		},
 		{
-			name: "invalid delay override",
-			refConfig: `
-    channels:
-      - ref: test_guitar
-        delay: -100
+			name: "confidence above one",
+			align: `
+      min_confidence: 2
 `,
-			expectedErr: "delay override must be >= 0",
+			expectedErr: "'min_confidence' must be between 0 and 1",
 		},
 	}
 
@@ -406,14 +842,16 @@ definitions:
 
 configs:
   test:
-` + tt.refConfig
+    channels:
+      - ref: test_guitar
+    align:` + tt.align
 
 			configFile := createTempConfig(t, fullConfig)
 			defer os.Remove(configFile)
 
 			_, err := ValidateConfigurationFormat(configFile)
 			if err == nil {
-				t.Error("Expected error but got none")
+				t.Fatal("Expected error but got none")
 			}
 
 			if !containsSubstring(err.Error(), tt.expectedErr) {
@@ -460,7 +898,7 @@ func TestConvertProfileToConfig_ValidProfile(t *testing.T) {
 			{
 				Ref:   "test_monitor",
 				Name:  "monitor",
-				Delay: &[]int{200}[0], // Override delay
+				Delay: &[]float64{200}[0], // Override delay
 			},
 		},
 		Output: OutputConfig{
@@ -491,7 +929,7 @@ func TestConvertProfileToConfig_ValidProfile(t *testing.T) {
 		t.Errorf("Expected volume override 3.5, got %.1f", guitar.Volume)
 	}
 	if guitar.Delay != 0 { // Should inherit original delay
-		t.Errorf("Expected inherited delay 0, got %d", guitar.Delay)
+		t.Errorf("Expected inherited delay 0, got %g", guitar.Delay)
 	}
 
 	// Check second channel (monitor with delay override)
@@ -503,7 +941,7 @@ func TestConvertProfileToConfig_ValidProfile(t *testing.T) {
 		t.Errorf("Expected inherited volume 0.8, got %.1f", monitor.Volume)
 	}
 	if monitor.Delay != 200 {
-		t.Errorf("Expected delay override 200, got %d", monitor.Delay)
+		t.Errorf("Expected delay override 200, got %g", monitor.Delay)
 	}
 }
 
@@ -574,4 +1012,309 @@ func createTempConfig(t *testing.T, content string) string {
 	}
 
 	return tmpfile.Name()
-}
\ No newline at end of file
+}
+func TestValidateConfigurationFormat_InvalidDuplicateRule(t *testing.T) {
+	tests := []struct {
+		name        string
+		duplicates  string
+		expectedErr string
+	}{
+		{
+			name: "unknown policy",
+			duplicates: `
+        policy: first
+`,
+			expectedErr: "invalid duplicates policy 'first'",
+		},
+		{
+			name: "match without properties",
+			duplicates: `
+        policy: match
+`,
+			expectedErr: "requires at least one 'match' property",
+		},
+		{
+			name: "properties without match policy",
+			duplicates: `
+        policy: newest
+        match:
+          - property: media.name
+            value: YouTube
+`,
+			expectedErr: "duplicates 'match' requires policy 'match'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: chrome
+      type: monitor
+      sources:
+        - Chrome:output_FL
+      audiomode: mono
+      volume: 1.0
+      delay: 0
+      duplicates:` + tt.duplicates + `
+configs:
+  test:
+    channels:
+      - ref: chrome
+`
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidSourcePattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		expectedErr string
+	}{
+		{
+			name:        "invalid regex",
+			source:      `"re:^Chrome(:output_FL"`,
+			expectedErr: "invalid source regex",
+		},
+		{
+			name:        "selector without port",
+			source:      `{device.product.name: "Scarlett 2i2"}`,
+			expectedErr: "requires a 'port'",
+		},
+		{
+			name:        "selector without property",
+			source:      `{port: capture_FR}`,
+			expectedErr: "requires at least one property",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: guitar
+      type: input
+      sources: [` + tt.source + `]
+      audiomode: mono
+      volume: 1.0
+      delay: 0
+configs:
+  test:
+    channels:
+      - ref: guitar
+`
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidSegments(t *testing.T) {
+	fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 0
+
+configs:
+  test:
+    channels:
+      - ref: test_guitar
+    segments:
+      duration: 0.2
+`
+
+	configFile := createTempConfig(t, fullConfig)
+	defer os.Remove(configFile)
+
+	_, err := ValidateConfigurationFormat(configFile)
+	if err == nil {
+		t.Fatal("Expected error but got none")
+	}
+	if !containsSubstring(err.Error(), "'duration' must be at least 1 second") {
+		t.Errorf("Expected a segment duration error, got: %v", err)
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidAudio(t *testing.T) {
+	tests := []struct {
+		name        string
+		audio       string
+		expectedErr string
+	}{
+		{
+			name: "quantum not a power of two",
+			audio: `
+      quantum: 300
+`,
+			expectedErr: "'quantum' must be a power of two",
+		},
+		{
+			name: "sample rate out of range",
+			audio: `
+      sample_rate: 480
+`,
+			expectedErr: "'sample_rate' must be between 8000 and 384000",
+		},
+		{
+			name: "unknown clock check",
+			audio: `
+      clock_check: ignore
+`,
+			expectedErr: "invalid 'clock_check' 'ignore'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 0
+
+configs:
+  test:
+    channels:
+      - ref: test_guitar
+    audio:` + tt.audio
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigurationFormat_InvalidCapture(t *testing.T) {
+	tests := []struct {
+		name        string
+		capture     string
+		expectedErr string
+	}{
+		{
+			name: "unsupported codec",
+			capture: `
+      codec: mp3
+`,
+			expectedErr: "unsupported 'codec' 'mp3'",
+		},
+		{
+			name: "bit depth not supported by codec",
+			capture: `
+      codec: pcm_s24le
+      bit_depth: 16
+`,
+			expectedErr: "codec 'pcm_s24le' does not support a 'bit_depth' of 16",
+		},
+		{
+			name: "flac bit depth",
+			capture: `
+      bit_depth: 32
+`,
+			expectedErr: "codec 'flac' does not support a 'bit_depth' of 32",
+		},
+		{
+			name: "compression level on pcm",
+			capture: `
+      codec: pcm_f32le
+      compression_level: 5
+`,
+			expectedErr: "'compression_level' only applies to flac",
+		},
+		{
+			name: "compression level out of range",
+			capture: `
+      codec: flac
+      compression_level: 13
+`,
+			expectedErr: "'compression_level' must be between 0 and 12",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fullConfig := `
+active_config: test
+
+definitions:
+  channels:
+    - id: test_guitar
+      type: input
+      sources:
+        - system:capture_1
+      audiomode: mono
+      volume: 2.0
+      delay: 0
+
+configs:
+  test:
+    channels:
+      - ref: test_guitar
+    capture:` + tt.capture
+
+			configFile := createTempConfig(t, fullConfig)
+			defer os.Remove(configFile)
+
+			_, err := ValidateConfigurationFormat(configFile)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+
+			if !containsSubstring(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing '%s', got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Effect types of a channel's effects chain
+const (
+	EffectHighpass   = "highpass"
+	EffectEqualizer  = "equalizer"
+	EffectCompressor = "compressor"
+	EffectGate       = "gate"
+	EffectDeesser    = "deesser"
+	EffectReverb     = "reverb"
+)
+
+// effectTypes lists the effect types for error messages
+const effectTypes = "highpass, equalizer, compressor, gate, deesser or reverb"
+
+const (
+	// MinEffectFrequency and MaxEffectFrequency bound the audible range
+	MinEffectFrequency = 20.0
+	MaxEffectFrequency = 20000.0
+
+	// MaxBandGain bounds the boost or cut of an equalizer band, in dB
+	MaxBandGain = 24.0
+
+	// DefaultCompressorThreshold, DefaultCompressorRatio, DefaultCompressorAttack
+	// and DefaultCompressorRelease match FFmpeg's acompressor
+	DefaultCompressorThreshold = -20.0
+	DefaultCompressorRatio     = 4.0
+	DefaultCompressorAttack    = 20.0
+	DefaultCompressorRelease   = 250.0
+
+	// DefaultGateThreshold keeps a quiet guitar open while closing on hum
+	DefaultGateThreshold = -40.0
+	DefaultGateRatio     = 2.0
+
+	// DefaultDeesserIntensity halves the sibilance
+	DefaultDeesserIntensity = 0.5
+
+	// DefaultReverbSize and DefaultReverbDecay make a small room
+	DefaultReverbSize  = 60.0
+	DefaultReverbDecay = 0.5
+)
+
+// Effect is one stage of a channel's effects chain. The chain runs in order
+// on the recorded track, before the channel's volume, delay and pan. Only the
+// fields of its type apply.
+type Effect struct {
+	Type      string   `mapstructure:"type" yaml:"type"`                               // highpass, equalizer, compressor, gate, deesser or reverb
+	Frequency float64  `mapstructure:"frequency,omitempty" yaml:"frequency,omitempty"` // highpass: cutoff in Hz
+	Bands     []EQBand `mapstructure:"bands,omitempty" yaml:"bands,omitempty"`         // equalizer: bands applied in order
+	Threshold float64  `mapstructure:"threshold,omitempty" yaml:"threshold,omitempty"` // compressor, gate: level in dB (default -20 and -40)
+	Ratio     float64  `mapstructure:"ratio,omitempty" yaml:"ratio,omitempty"`         // compressor, gate: 1 to 20 (default 4 and 2)
+	Attack    float64  `mapstructure:"attack,omitempty" yaml:"attack,omitempty"`       // compressor, gate: milliseconds (default 20)
+	Release   float64  `mapstructure:"release,omitempty" yaml:"release,omitempty"`     // compressor, gate: milliseconds (default 250)
+	Makeup    float64  `mapstructure:"makeup,omitempty" yaml:"makeup,omitempty"`       // compressor: gain in dB after compression
+	Intensity float64  `mapstructure:"intensity,omitempty" yaml:"intensity,omitempty"` // deesser: 0 to 1 (default 0.5)
+	Send      float64  `mapstructure:"send,omitempty" yaml:"send,omitempty"`           // reverb: level of the reverb mixed with the dry track, 0 to 1
+	Size      float64  `mapstructure:"size,omitempty" yaml:"size,omitempty"`           // reverb: first reflection in milliseconds (default 60)
+	Decay     float64  `mapstructure:"decay,omitempty" yaml:"decay,omitempty"`         // reverb: 0 to 1, how much each reflection keeps (default 0.5)
+	Impulse   string   `mapstructure:"impulse,omitempty" yaml:"impulse,omitempty"`     // reverb: WAV impulse response convolved instead of the reflections
+}
+
+// EQBand is a peaking band of an equalizer effect
+type EQBand struct {
+	Frequency float64 `mapstructure:"frequency" yaml:"frequency"`     // Centre in Hz
+	Gain      float64 `mapstructure:"gain" yaml:"gain"`               // Boost or cut in dB
+	Q         float64 `mapstructure:"q,omitempty" yaml:"q,omitempty"` // Sharpness from 0.1 to 10 (default 1)
+}
+
+// ValidateEffects checks an effects chain of the configuration
+func ValidateEffects(effects []Effect) error {
+	for i, effect := range effects {
+		if err := validateEffect(effect); err != nil {
+			return fmt.Errorf("effects[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateMixEffects checks an effects chain requested for a single mix by a
+// web client. The request cannot point the mixer at files of the host, so a
+// reverb there has no impulse response: those come from the configuration.
+func ValidateMixEffects(effects []Effect) error {
+	for i, effect := range effects {
+		if effect.Impulse != "" {
+			return fmt.Errorf("effects[%d]: %s: 'impulse' can only be set in the configuration", i, effect.Type)
+		}
+	}
+	return ValidateEffects(effects)
+}
+
+// validateEffect checks the fields of an effect against its type
+func validateEffect(effect Effect) error {
+	inRange := func(field string, value, min, max float64, unit string) error {
+		if value < min || value > max {
+			return fmt.Errorf("%s: '%s' must be between %g and %g%s, got: %g", effect.Type, field, min, max, unit, value)
+		}
+		return nil
+	}
+	// Zero keeps the default of the optional fields
+	optional := func(field string, value, min, max float64, unit string) error {
+		if value == 0 {
+			return nil
+		}
+		return inRange(field, value, min, max, unit)
+	}
+
+	switch effect.Type {
+	case EffectHighpass:
+		return inRange("frequency", effect.Frequency, MinEffectFrequency, MaxEffectFrequency, " Hz")
+	case EffectEqualizer:
+		if len(effect.Bands) == 0 {
+			return fmt.Errorf("equalizer: 'bands' must list at least one band")
+		}
+		for i, band := range effect.Bands {
+			if band.Frequency < MinEffectFrequency || band.Frequency > MaxEffectFrequency {
+				return fmt.Errorf("equalizer: bands[%d]: 'frequency' must be between %g and %g Hz, got: %g", i, MinEffectFrequency, MaxEffectFrequency, band.Frequency)
+			}
+			if math.Abs(band.Gain) > MaxBandGain {
+				return fmt.Errorf("equalizer: bands[%d]: 'gain' must be between -%g and %g dB, got: %g", i, MaxBandGain, MaxBandGain, band.Gain)
+			}
+			if band.Q != 0 && (band.Q < 0.1 || band.Q > 10) {
+				return fmt.Errorf("equalizer: bands[%d]: 'q' must be between 0.1 and 10, got: %g", i, band.Q)
+			}
+		}
+		return nil
+	case EffectCompressor, EffectGate:
+		// FFmpeg's compressor cannot go below -60 dB
+		minThreshold := -80.0
+		if effect.Type == EffectCompressor {
+			minThreshold = -60
+		}
+		for _, err := range []error{
+			optional("threshold", effect.Threshold, minThreshold, 0, " dB"),
+			optional("ratio", effect.Ratio, 1, 20, ""),
+			optional("attack", effect.Attack, 0.01, 2000, " ms"),
+			optional("release", effect.Release, 0.01, 9000, " ms"),
+		} {
+			if err != nil {
+				return err
+			}
+		}
+		if effect.Type == EffectGate && effect.Makeup != 0 {
+			return fmt.Errorf("gate: 'makeup' only applies to a compressor")
+		}
+		return inRange("makeup", effect.Makeup, 0, 36, " dB")
+	case EffectDeesser:
+		return optional("intensity", effect.Intensity, 0, 1, "")
+	case EffectReverb:
+		if effect.Send <= 0 || effect.Send > 1 {
+			return fmt.Errorf("reverb: 'send' must be above 0 and at most 1, got: %g", effect.Send)
+		}
+		if effect.Impulse != "" {
+			if strings.ContainsAny(effect.Impulse, `'\:,;[]=`) {
+				return fmt.Errorf("reverb: 'impulse' path cannot contain any of ' \\ : , ; [ ] =, got: %s", effect.Impulse)
+			}
+			if _, err := os.Stat(expandPath(effect.Impulse)); err != nil {
+				return fmt.Errorf("reverb: 'impulse' file not found: %s", effect.Impulse)
+			}
+			if effect.Size != 0 || effect.Decay != 0 {
+				return fmt.Errorf("reverb: 'size' and 'decay' do not apply to an 'impulse' response")
+			}
+			return nil
+		}
+		if err := optional("size", effect.Size, 5, 1000, " ms"); err != nil {
+			return err
+		}
+		return optional("decay", effect.Decay, 0, 1, "")
+	case "":
+		return fmt.Errorf("'type' is required, one of %s", effectTypes)
+	default:
+		return fmt.Errorf("'type' must be %s, got: %s", effectTypes, effect.Type)
+	}
+}
+
+// orDefault returns value, or fallback when value is zero
+func orDefault(value, fallback float64) float64 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// effectFilter returns the FFmpeg filters of an effect that runs in line.
+// A reverb on an impulse response needs a side graph, see effectsChain.
+func effectFilter(effect Effect) string {
+	switch effect.Type {
+	case EffectHighpass:
+		return fmt.Sprintf("highpass=f=%g", effect.Frequency)
+	case EffectEqualizer:
+		bands := make([]string, len(effect.Bands))
+		for i, band := range effect.Bands {
+			bands[i] = fmt.Sprintf("equalizer=f=%g:t=q:w=%g:g=%g", band.Frequency, orDefault(band.Q, 1), band.Gain)
+		}
+		return strings.Join(bands, ",")
+	case EffectCompressor:
+		return fmt.Sprintf("acompressor=threshold=%gdB:ratio=%g:attack=%g:release=%g:makeup=%gdB",
+			orDefault(effect.Threshold, DefaultCompressorThreshold), orDefault(effect.Ratio, DefaultCompressorRatio),
+			orDefault(effect.Attack, DefaultCompressorAttack), orDefault(effect.Release, DefaultCompressorRelease), effect.Makeup)
+	case EffectGate:
+		return fmt.Sprintf("agate=threshold=%gdB:ratio=%g:attack=%g:release=%g",
+			orDefault(effect.Threshold, DefaultGateThreshold), orDefault(effect.Ratio, DefaultGateRatio),
+			orDefault(effect.Attack, DefaultCompressorAttack), orDefault(effect.Release, DefaultCompressorRelease))
+	case EffectDeesser:
+		return fmt.Sprintf("deesser=i=%g", orDefault(effect.Intensity, DefaultDeesserIntensity))
+	case EffectReverb:
+		// Three reflections, each later and quieter, over the dry track
+		size, decay := orDefault(effect.Size, DefaultReverbSize), orDefault(effect.Decay, DefaultReverbDecay)
+		delays, decays := make([]string, 3), make([]string, 3)
+		for i, spread := range []float64{1, 1.7, 2.9} {
+			delays[i] = fmt.Sprintf("%g", math.Round(size*spread))
+			decays[i] = fmt.Sprintf("%.3f", effect.Send*math.Pow(decay, float64(i+1)))
+		}
+		return fmt.Sprintf("aecho=in_gain=1:out_gain=1:delays=%s:decays=%s", strings.Join(delays, "|"), strings.Join(decays, "|"))
+	}
+	return ""
+}
+
+// effectsChain compiles the effects of the channel on track index. It returns
+// the side graphs ending in ";", the label the rest of the track reads, and
+// the filters it starts with. Without an impulse response there is no side
+// graph and the track reads its input stream.
+func effectsChain(index int, effects []Effect, sampleRate int) (graph, head string, filters []string) {
+	head = fmt.Sprintf("[0:%d]", index)
+	for i, effect := range effects {
+		if effect.Type != EffectReverb || effect.Impulse == "" {
+			filters = append(filters, effectFilter(effect))
+			continue
+		}
+
+		// The reverb convolves a copy of the track and mixes it back in
+		label := fmt.Sprintf("fx_%d_%d", index, i)
+		graph += fmt.Sprintf("%s%s[%s_dry][%s_send];", head, strings.Join(append(filters, "asplit=2"), ","), label, label)
+		graph += fmt.Sprintf("amovie=filename=%s,aresample=%d,aformat=channel_layouts=mono[%s_ir];", expandPath(effect.Impulse), sampleRate, label)
+		graph += fmt.Sprintf("[%s_send][%s_ir]afir,volume=%g[%s_wet];", label, label, effect.Send, label)
+		head = fmt.Sprintf("[%s_dry][%s_wet]", label, label)
+		filters = []string{"amix=inputs=2:normalize=0"}
+	}
+	return graph, head, filters
+}
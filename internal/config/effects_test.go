@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTrackFilter_Effects(t *testing.T) {
+	tests := []struct {
+		name     string
+		effects  []Effect
+		expected string
+	}{
+		{
+			name:     "highpass and default compressor",
+			effects:  []Effect{{Type: EffectHighpass, Frequency: 80}, {Type: EffectCompressor}},
+			expected: "[0:0]highpass=f=80,acompressor=threshold=-20dB:ratio=4:attack=20:release=250:makeup=0dB,volume=1.0,aformat=channel_layouts=stereo",
+		},
+		{
+			name:     "equalizer bands in order",
+			effects:  []Effect{{Type: EffectEqualizer, Bands: []EQBand{{Frequency: 250, Gain: -3}, {Frequency: 3000, Gain: 2.5, Q: 2}}}},
+			expected: "[0:0]equalizer=f=250:t=q:w=1:g=-3,equalizer=f=3000:t=q:w=2:g=2.5,volume=1.0,aformat=channel_layouts=stereo",
+		},
+		{
+			name:     "gate and deesser",
+			effects:  []Effect{{Type: EffectGate, Threshold: -50}, {Type: EffectDeesser}},
+			expected: "[0:0]agate=threshold=-50dB:ratio=2:attack=20:release=250,deesser=i=0.5,volume=1.0,aformat=channel_layouts=stereo",
+		},
+		{
+			name:     "reverb reflections scaled by the send",
+			effects:  []Effect{{Type: EffectReverb, Send: 0.4}},
+			expected: "[0:0]aecho=in_gain=1:out_gain=1:delays=60|102|174:decays=0.200|0.100|0.050,volume=1.0,aformat=channel_layouts=stereo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := Channel{Name: "guitar", AudioMode: "mono", Volume: 1.0, Effects: tt.effects}
+			if got := trackFilter(0, channel, 1, "mono", 48000); got != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTrackFilter_ImpulseReverb(t *testing.T) {
+	impulse := filepath.Join(t.TempDir(), "hall.wav")
+	channel := Channel{Name: "mic", AudioMode: "mono", Volume: 1.0, Delay: 20, Effects: []Effect{
+		{Type: EffectHighpass, Frequency: 100},
+		{Type: EffectReverb, Send: 0.3, Impulse: impulse},
+		{Type: EffectCompressor},
+	}}
+
+	// The dry track and the convolved send are mixed back before the rest of the chain
+	expected := "[0:1]highpass=f=100,asplit=2[fx_1_1_dry][fx_1_1_send];" +
+		"amovie=filename=" + impulse + ",aresample=48000,aformat=channel_layouts=mono[fx_1_1_ir];" +
+		"[fx_1_1_send][fx_1_1_ir]afir,volume=0.3[fx_1_1_wet];" +
+		"[fx_1_1_dry][fx_1_1_wet]amix=inputs=2:normalize=0,acompressor=threshold=-20dB:ratio=4:attack=20:release=250:makeup=0dB,volume=1.0,adelay=20,aformat=channel_layouts=stereo"
+	if got := trackFilter(1, channel, 1, "mono", 48000); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestValidateEffects(t *testing.T) {
+	impulse := filepath.Join(t.TempDir(), "hall.wav")
+	if err := os.WriteFile(impulse, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	valid := []Effect{
+		{Type: EffectHighpass, Frequency: 80},
+		{Type: EffectEqualizer, Bands: []EQBand{{Frequency: 400, Gain: -4, Q: 1.4}}},
+		{Type: EffectCompressor, Threshold: -18, Ratio: 3, Makeup: 4},
+		{Type: EffectGate},
+		{Type: EffectDeesser, Intensity: 0.8},
+		{Type: EffectReverb, Send: 0.25, Size: 120, Decay: 0.6},
+		{Type: EffectReverb, Send: 0.25, Impulse: impulse},
+	}
+	if err := ValidateEffects(valid); err != nil {
+		t.Errorf("Expected a valid chain, got: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		effect  Effect
+		message string
+	}{
+		{"missing type", Effect{}, "'type' is required"},
+		{"unknown type", Effect{Type: "chorus"}, "'type' must be highpass, equalizer"},
+		{"highpass without frequency", Effect{Type: EffectHighpass}, "highpass: 'frequency' must be between 20 and 20000 Hz"},
+		{"equalizer without bands", Effect{Type: EffectEqualizer}, "'bands' must list at least one band"},
+		{"equalizer gain", Effect{Type: EffectEqualizer, Bands: []EQBand{{Frequency: 100, Gain: 30}}}, "bands[0]: 'gain' must be between -24 and 24 dB"},
+		{"equalizer q", Effect{Type: EffectEqualizer, Bands: []EQBand{{Frequency: 100, Gain: 3, Q: 20}}}, "bands[0]: 'q' must be between 0.1 and 10"},
+		{"compressor threshold", Effect{Type: EffectCompressor, Threshold: -70}, "compressor: 'threshold' must be between -60 and 0 dB"},
+		{"compressor ratio", Effect{Type: EffectCompressor, Ratio: 50}, "'ratio' must be between 1 and 20"},
+		{"gate makeup", Effect{Type: EffectGate, Makeup: 6}, "'makeup' only applies to a compressor"},
+		{"deesser intensity", Effect{Type: EffectDeesser, Intensity: 2}, "'intensity' must be between 0 and 1"},
+		{"reverb without send", Effect{Type: EffectReverb}, "'send' must be above 0 and at most 1"},
+		{"reverb size", Effect{Type: EffectReverb, Send: 0.2, Size: 2000}, "'size' must be between 5 and 1000 ms"},
+		{"missing impulse", Effect{Type: EffectReverb, Send: 0.2, Impulse: "/nonexistent/hall.wav"}, "'impulse' file not found"},
+		{"impulse with decay", Effect{Type: EffectReverb, Send: 0.2, Impulse: impulse, Decay: 0.5}, "do not apply to an 'impulse' response"},
+		{"impulse path in the filter syntax", Effect{Type: EffectReverb, Send: 0.2, Impulse: "/tmp/a,b.wav"}, "'impulse' path cannot contain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEffects([]Effect{{Type: EffectHighpass, Frequency: 80}, tt.effect})
+			if err == nil {
+				t.Fatalf("Expected an error containing '%s'", tt.message)
+			}
+			if !strings.HasPrefix(err.Error(), "effects[1]: ") || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected 'effects[1]: ...%s...', got: %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestValidateMixEffects(t *testing.T) {
+	// Even an existing file cannot be named by a mix request, nor probed
+	impulse := filepath.Join(t.TempDir(), "hall.wav")
+	if err := os.WriteFile(impulse, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{impulse, "/nonexistent/hall.wav", "../../etc/passwd"} {
+		err := ValidateMixEffects([]Effect{{Type: EffectHighpass, Frequency: 80}, {Type: EffectReverb, Send: 0.2, Impulse: path}})
+		if err == nil || err.Error() != "effects[1]: reverb: 'impulse' can only be set in the configuration" {
+			t.Errorf("Expected the impulse %s to be refused, got: %v", path, err)
+		}
+	}
+
+	if err := ValidateMixEffects([]Effect{{Type: EffectReverb, Send: 0.2}, {Type: EffectCompressor}}); err != nil {
+		t.Errorf("Expected a chain without files to be valid, got: %v", err)
+	}
+	if err := ValidateMixEffects([]Effect{{Type: EffectReverb}}); err == nil || !strings.Contains(err.Error(), "'send' must be above 0") {
+		t.Errorf("Expected the effects to be validated, got: %v", err)
+	}
+}
+
+func TestLoadWithProfile_Effects(t *testing.T) {
+	configContent := `
+active_config: live
+definitions:
+    channels:
+        - id: mic
+          sources: ["system:capture_1"]
+          type: input
+          volume: 1.0
+          effects:
+            - type: highpass
+              frequency: 100
+            - type: compressor
+              threshold: -18
+              ratio: 3
+configs:
+    live:
+        channels:
+            - ref: mic
+    dry:
+        channels:
+            - ref: mic
+              effects: []
+    wet:
+        channels:
+            - ref: mic
+              effects:
+                - type: reverb
+                  send: 0.3
+`
+
+	configFile := createTempConfig(t, configContent)
+	defer os.Remove(configFile)
+
+	cfg, err := LoadWithProfile(configFile, "live")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if effects := cfg.Channels[0].Effects; len(effects) != 2 || effects[0].Type != EffectHighpass || effects[1].Ratio != 3 {
+		t.Errorf("Expected the chain of the definition, got %+v", effects)
+	}
+
+	cfg, err = LoadWithProfile(configFile, "dry")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if effects := cfg.Channels[0].Effects; len(effects) != 0 {
+		t.Errorf("Expected an empty override to leave the mic dry, got %+v", effects)
+	}
+
+	cfg, err = LoadWithProfile(configFile, "wet")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if effects := cfg.Channels[0].Effects; len(effects) != 1 || effects[0].Type != EffectReverb {
+		t.Errorf("Expected the override to replace the whole chain, got %+v", effects)
+	}
+
+	brokenFile := createTempConfig(t, strings.Replace(configContent, "send: 0.3", "size: 80", 1))
+	defer os.Remove(brokenFile)
+	if _, err := LoadWithProfile(brokenFile, "live"); err == nil || !containsSubstring(err.Error(), "effects override: effects[0]: reverb: 'send'") {
+		t.Errorf("Expected a reverb without send to fail, got: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package mix
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/audio"
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+const (
+	// alignSampleRate is enough to line up tracks to a sample of 62.5 µs
+	// while keeping the correlation of a minute of audio small
+	alignSampleRate = 16000
+
+	// alignWindow is the start of the take the offsets are measured on
+	alignWindow = 60 * time.Second
+
+	// alignmentSuffix names the offsets stored next to a recording
+	alignmentSuffix = ".alignment.json"
+)
+
+// AlignmentFile returns where the offsets of the last aligned mix of an MKV
+// file are stored
+func AlignmentFile(mkvFile string) string {
+	return strings.TrimSuffix(mkvFile, ".mkv") + alignmentSuffix
+}
+
+// LoadAlignment reads the offsets of the last aligned mix of an MKV file, nil
+// if it was mixed without alignment
+func LoadAlignment(mkvFile string) ([]config.TrackOffset, error) {
+	data, err := os.ReadFile(AlignmentFile(mkvFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets []config.TrackOffset
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return nil, fmt.Errorf("invalid alignment file: %w", err)
+	}
+	return offsets, nil
+}
+
+// saveAlignment stores the offsets of a mix next to its recording, or removes
+// those of a previous mix when it was not aligned
+func saveAlignment(mkvFile string, offsets []config.TrackOffset) error {
+	if offsets == nil {
+		if err := os.Remove(AlignmentFile(mkvFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(offsets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(AlignmentFile(mkvFile), data, 0644)
+}
+
+// alignedConfig measures how late the reference track hears each monitor
+// track and returns a configuration delaying the monitors by what was
+// measured, along with the offsets. Without an align section, or when the
+// tracks cannot be measured, the configured delays are kept.
+func (m *Mixer) alignedConfig(inputFile string, analysis *config.MKVAnalysis) (*config.Config, []config.TrackOffset) {
+	align := m.cfg.GetAlign()
+	if align == nil {
+		return m.cfg, nil
+	}
+
+	channels := m.cfg.EnabledChannels()
+	if len(channels) > len(analysis.Tracks) {
+		channels = channels[:len(analysis.Tracks)]
+	}
+
+	referenceTrack, monitors := -1, 0
+	for i, channel := range channels {
+		if channel.Name == align.Reference && referenceTrack < 0 {
+			referenceTrack = i
+		}
+		if channel.Type == "monitor" {
+			monitors++
+		}
+	}
+	if monitors == 0 {
+		slog.Debug("No monitor track to align", "file", inputFile)
+		return m.cfg, nil
+	}
+	if referenceTrack < 0 {
+		slog.Warn("Reference channel not recorded, keeping the configured delays", "reference", align.Reference, "file", inputFile)
+		return m.cfg, nil
+	}
+
+	reference, err := audio.DecodeTrack(inputFile, referenceTrack, alignSampleRate, alignWindow)
+	if err != nil {
+		slog.Warn("Failed to decode the reference track, keeping the configured delays", "reference", align.Reference, "error", err)
+		return m.cfg, nil
+	}
+
+	maxLag := align.MaxOffset * alignSampleRate / 1000
+	delays := make(map[string]float64)
+	offsets := []config.TrackOffset{}
+	for i, channel := range channels {
+		if channel.Type != "monitor" {
+			continue
+		}
+
+		monitor, err := audio.DecodeTrack(inputFile, i, alignSampleRate, alignWindow)
+		if err != nil {
+			slog.Warn("Failed to decode a monitor track, keeping its configured delay", "channel", channel.Name, "error", err)
+			continue
+		}
+
+		// The reference picks the monitor up late by the latency of its output
+		lag, confidence := audio.CrossCorrelate(monitor, reference, -maxLag, maxLag)
+		offset := trackOffset(i, channel, align, lag, confidence, channels[referenceTrack].DelayMs(m.cfg.GetSampleRate()), m.cfg.GetSampleRate())
+		if offset.Applied {
+			delays[channel.Name] = offset.Delay
+		}
+		offsets = append(offsets, offset)
+
+		slog.Info("Aligned monitor track", "channel", channel.Name, "reference", align.Reference,
+			"offset_ms", offset.Offset, "confidence", offset.Confidence, "applied", offset.Applied)
+	}
+
+	cfg := *m.cfg
+	cfg.Channels = make([]config.Channel, len(m.cfg.Channels))
+	copy(cfg.Channels, m.cfg.Channels)
+	for i, channel := range cfg.Channels {
+		if delay, ok := delays[channel.Name]; ok {
+			cfg.Channels[i].Delay = delay
+			cfg.Channels[i].DelaySamples = 0
+		}
+	}
+	return &cfg, offsets
+}
+
+// trackOffset turns the lag, in frames at the alignment rate, found between a
+// monitor track and the reference into the delay the mix gives the monitor:
+// the reference's own delay plus the offset, to a hundredth of a millisecond,
+// unless the match is too weak to trust and the configured delay is kept
+func trackOffset(track int, channel config.Channel, align *config.AlignConfig, lag, confidence, referenceDelay float64, sampleRate int) config.TrackOffset {
+	offset := config.TrackOffset{
+		Track:      track,
+		Channel:    channel.Name,
+		Reference:  align.Reference,
+		Offset:     math.Round(lag*1000/alignSampleRate*100) / 100,
+		Confidence: math.Round(confidence*1000) / 1000,
+		Delay:      channel.DelayMs(sampleRate),
+	}
+	if confidence >= align.MinConfidence {
+		offset.Delay = math.Round((referenceDelay+lag*1000/alignSampleRate)*100) / 100
+		offset.Applied = true
+	}
+	return offset
+}
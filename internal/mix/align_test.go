@@ -0,0 +1,138 @@
+package mix
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+func TestTrackOffset(t *testing.T) {
+	align := &config.AlignConfig{Reference: "mic", MaxOffset: 500, MinConfidence: 0.1}
+
+	tests := []struct {
+		name           string
+		channel        config.Channel
+		lag            float64
+		confidence     float64
+		referenceDelay float64
+		expected       config.TrackOffset
+	}{
+		{
+			name:       "late monitor delayed by the offset",
+			channel:    config.Channel{Name: "chrome", Delay: 250},
+			lag:        1600,
+			confidence: 0.5,
+			expected:   config.TrackOffset{Offset: 100, Confidence: 0.5, Delay: 100, Applied: true},
+		},
+		{
+			name:           "reference delay added to the offset",
+			channel:        config.Channel{Name: "chrome"},
+			lag:            -80,
+			confidence:     0.31234,
+			referenceDelay: 20,
+			expected:       config.TrackOffset{Offset: -5, Confidence: 0.312, Delay: 15, Applied: true},
+		},
+		{
+			name:       "fraction of a millisecond kept to a hundredth",
+			channel:    config.Channel{Name: "chrome"},
+			lag:        1.5,
+			confidence: 0.9,
+			expected:   config.TrackOffset{Offset: 0.09, Confidence: 0.9, Delay: 0.09, Applied: true},
+		},
+		{
+			name:       "weak match keeps the configured delay",
+			channel:    config.Channel{Name: "chrome", Delay: 250},
+			lag:        1600,
+			confidence: 0.05,
+			expected:   config.TrackOffset{Offset: 100, Confidence: 0.05, Delay: 250},
+		},
+		{
+			name:       "configured delay in samples reported in milliseconds",
+			channel:    config.Channel{Name: "chrome", DelaySamples: 480},
+			lag:        3200,
+			confidence: 0.0999,
+			expected:   config.TrackOffset{Offset: 200, Confidence: 0.1, Delay: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := trackOffset(2, tt.channel, align, tt.lag, tt.confidence, tt.referenceDelay, 48000)
+
+			expected := tt.expected
+			expected.Track, expected.Channel, expected.Reference = 2, tt.channel.Name, "mic"
+			if offset != expected {
+				t.Errorf("Expected %+v, got %+v", expected, offset)
+			}
+		})
+	}
+}
+
+func TestAlignment_SaveAndLoad(t *testing.T) {
+	mkvFile := filepath.Join(t.TempDir(), "rehearsal.mkv")
+	if file := AlignmentFile(mkvFile); filepath.Base(file) != "rehearsal.alignment.json" {
+		t.Errorf("Expected the alignment next to the recording, got %s", file)
+	}
+
+	// A recording never aligned has no offsets
+	if offsets, err := LoadAlignment(mkvFile); err != nil || offsets != nil {
+		t.Fatalf("Expected no offsets, got %+v (%v)", offsets, err)
+	}
+
+	saved := []config.TrackOffset{
+		{Track: 1, Channel: "chrome", Reference: "mic", Offset: 243.56, Confidence: 0.42, Delay: 263.56, Applied: true},
+		{Track: 2, Channel: "bluetooth", Reference: "mic", Offset: 12, Confidence: 0.02, Delay: 150},
+	}
+	if err := saveAlignment(mkvFile, saved); err != nil {
+		t.Fatalf("saveAlignment failed: %v", err)
+	}
+	loaded, err := LoadAlignment(mkvFile)
+	if err != nil {
+		t.Fatalf("LoadAlignment failed: %v", err)
+	}
+	if len(loaded) != len(saved) {
+		t.Fatalf("Expected %d offsets, got %+v", len(saved), loaded)
+	}
+	for i := range saved {
+		if loaded[i] != saved[i] {
+			t.Errorf("Offset %d: expected %+v, got %+v", i, saved[i], loaded[i])
+		}
+	}
+
+	// Mixing again without alignment removes the stale offsets
+	if err := saveAlignment(mkvFile, nil); err != nil {
+		t.Fatalf("saveAlignment failed: %v", err)
+	}
+	if _, err := os.Stat(AlignmentFile(mkvFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the alignment file to be removed, got: %v", err)
+	}
+	if err := saveAlignment(mkvFile, nil); err != nil {
+		t.Errorf("Expected removing a missing alignment to succeed, got: %v", err)
+	}
+
+	if err := os.WriteFile(AlignmentFile(mkvFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadAlignment(mkvFile); err == nil {
+		t.Error("Expected an invalid alignment file to fail")
+	}
+}
+
+func TestAlignedConfig_NoMonitorToAlign(t *testing.T) {
+	cfg := &config.Config{
+		Align: &config.AlignConfig{Reference: "mic"},
+		Channels: []config.Channel{
+			{Name: "mic", Type: "input", Sources: []string{"system:capture_1"}},
+			{Name: "guitar", Type: "input", Sources: []string{"system:capture_2"}},
+		},
+	}
+	analysis := &config.MKVAnalysis{Tracks: []config.TrackInfo{{Index: 0, Channels: 1}, {Index: 1, Channels: 1}}}
+
+	// Nothing to align: the recording, which does not exist, is never decoded
+	aligned, offsets := New(cfg).alignedConfig(filepath.Join(t.TempDir(), "missing.mkv"), analysis)
+	if aligned != cfg || offsets != nil {
+		t.Errorf("Expected the configuration unchanged without offsets, got %+v", offsets)
+	}
+}
@@ -39,8 +39,12 @@ func (m *Mixer) Mix(songName string) error {
 		return fmt.Errorf("failed to analyze input file: %w", err)
 	}
 
+	// Line the monitor tracks up with the reference when the profile asks to
+	cfg, offsets := m.alignedConfig(inputFile, analysis)
+	analysis.Alignment = offsets
+
 	// Build FFmpeg filter based on actual file structure
-	mixFilter, outputChannels := m.cfg.BuildMixFilterForFile(analysis)
+	mixFilter, outputChannels := cfg.BuildMixFilterForFile(analysis)
 	if mixFilter == "" {
 		return fmt.Errorf("no valid mix configuration found for file with %d tracks", len(analysis.Tracks))
 	}
@@ -50,7 +54,7 @@ func (m *Mixer) Mix(songName string) error {
 		"-i", inputFile,
 		"-filter_complex", mixFilter,
 		"-ac", fmt.Sprintf("%d", outputChannels),
-		"-ar", fmt.Sprintf("%d", m.cfg.Audio.SampleRate),
+		"-ar", fmt.Sprintf("%d", m.cfg.GetSampleRate()),
 		"-c:a", m.cfg.Output.Format,
 		"-y", // Overwrite output file
 		outputFile,
@@ -69,6 +73,10 @@ func (m *Mixer) Mix(songName string) error {
 		return fmt.Errorf("output file not created: %s", outputFile)
 	}
 
+	if err := saveAlignment(inputFile, offsets); err != nil {
+		slog.Warn("Failed to store the alignment of the mix", "file", inputFile, "error", err)
+	}
+
 	slog.Info("Mixed audio file saved to", "file", outputFile)
 	return nil
 }
@@ -87,7 +95,8 @@ func (m *Mixer) MixWithOptions(songName string, guitarVol, backingVol float64, d
 			m.cfg.Channels[i].Volume = backingVol
 		}
 		if delayMs >= 0 {
-			m.cfg.Channels[i].Delay = delayMs
+			m.cfg.Channels[i].Delay = float64(delayMs)
+			m.cfg.Channels[i].DelaySamples = 0
 		}
 	}
 
@@ -147,6 +156,35 @@ func (m *Mixer) MixWithChannelAndGlobalVolumes(songName string, channelVolumes m
 	return err
 }
 
+// MixWithChannelEffects creates a mix with custom volume levels, pans and effects chains for specific channels and a global volume
+func (m *Mixer) MixWithChannelEffects(songName string, channelVolumes, channelPans map[string]float64, channelEffects map[string][]config.Effect, globalVolume float64) error {
+	// Store original values
+	originalChannels := make([]config.Channel, len(m.cfg.Channels))
+	copy(originalChannels, m.cfg.Channels)
+
+	// Apply custom volumes, pans and effects to matching channels
+	for i, channel := range m.cfg.Channels {
+		if vol, exists := channelVolumes[channel.Name]; exists {
+			m.cfg.Channels[i].Volume = vol
+		}
+		if pan, exists := channelPans[channel.Name]; exists {
+			m.cfg.Channels[i].Pan = pan
+		}
+		if effects, exists := channelEffects[channel.Name]; exists {
+			m.cfg.Channels[i].Effects = effects
+		}
+	}
+
+	slog.Debug("Mixing with custom channel volumes, pans and effects", "song", songName, "volumes", channelVolumes, "pans", channelPans, "effects", channelEffects, "global_volume", globalVolume)
+
+	err := m.mixWithGlobalVolume(songName, globalVolume)
+
+	// Restore original values
+	m.cfg.Channels = originalChannels
+
+	return err
+}
+
 // mixWithGlobalVolume performs the actual mixing with global volume control
 func (m *Mixer) mixWithGlobalVolume(songName string, globalVolume float64) error {
 	cleanName := m.cleanFileName(songName)
@@ -166,8 +204,12 @@ func (m *Mixer) mixWithGlobalVolume(songName string, globalVolume float64) error
 		return fmt.Errorf("failed to analyze input file: %w", err)
 	}
 
+	// Line the monitor tracks up with the reference when the profile asks to
+	cfg, offsets := m.alignedConfig(inputFile, analysis)
+	analysis.Alignment = offsets
+
 	// Build FFmpeg filter with global volume based on actual file structure
-	mixFilter, outputChannels := m.cfg.BuildMixFilterForFileWithGlobalVolume(analysis, globalVolume)
+	mixFilter, outputChannels := cfg.BuildMixFilterForFileWithGlobalVolume(analysis, globalVolume)
 	if mixFilter == "" {
 		return fmt.Errorf("no valid mix configuration found for file with %d tracks", len(analysis.Tracks))
 	}
@@ -177,7 +219,7 @@ func (m *Mixer) mixWithGlobalVolume(songName string, globalVolume float64) error
 		"-i", inputFile,
 		"-filter_complex", mixFilter,
 		"-ac", fmt.Sprintf("%d", outputChannels),
-		"-ar", fmt.Sprintf("%d", m.cfg.Audio.SampleRate),
+		"-ar", fmt.Sprintf("%d", m.cfg.GetSampleRate()),
 		"-c:a", m.cfg.Output.Format,
 		"-y", // Overwrite output file
 		outputFile,
@@ -196,6 +238,10 @@ func (m *Mixer) mixWithGlobalVolume(songName string, globalVolume float64) error
 		return fmt.Errorf("output file not created: %s", outputFile)
 	}
 
+	if err := saveAlignment(inputFile, offsets); err != nil {
+		slog.Warn("Failed to store the alignment of the mix", "file", inputFile, "error", err)
+	}
+
 	slog.Info("Mixed audio file saved to", "file", outputFile)
 	return nil
 }
@@ -268,6 +314,13 @@ func (m *Mixer) analyzeMKVFile(filePath string) (*config.MKVAnalysis, error) {
 			Channels: stream.Channels,
 		}
 
+		// Tracks added by an overdub name the take they belong to
+		if take, exists := stream.Tags["take"]; exists && take != "" {
+			track.Take = take
+		} else if take, exists := stream.Tags["TAKE"]; exists && take != "" {
+			track.Take = take
+		}
+
 		tracks = append(tracks, track)
 	}
 
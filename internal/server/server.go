@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"mime"
 	"net"
 	"net/http"
@@ -12,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,6 +42,11 @@ type Server struct {
 	lastLocalFile string
 	fileLock      sync.RWMutex
 
+	httpServer *http.Server
+
+	// Closed on shutdown to end the streams that never complete on their own
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
 // StatusResponse represents the JSON response for status endpoint
@@ -46,6 +54,7 @@ type StatusResponse struct {
 	Status        string                    `json:"status"`
 	Message       string                    `json:"message,omitempty"`
 	Session       *service.RecordingSession `json:"session,omitempty"`
+	Channels      []service.ChannelStatus   `json:"channels"`
 	Config        *ResolvedConfigInfo       `json:"resolved_config"`
 	ActiveProfile string                    `json:"active_profile"`
 }
@@ -63,11 +72,15 @@ type ResolvedConfigInfo struct {
 
 // SourceInfo contains information about an audio source
 type SourceInfo struct {
-	Name        string `json:"name"`
-	Source      string `json:"source"`
-	Type        string `json:"type"`
-	Status      string `json:"status"` // "available", "unavailable", "unknown"
-	LastChecked string `json:"last_checked"`
+	Name        string   `json:"name"`
+	Source      string   `json:"source"`
+	Type        string   `json:"type"`
+	Status      string   `json:"status"` // "available", "unavailable", "duplicate", "unknown"
+	State       string   `json:"state"`  // Connection state, see service.ChannelStatus
+	LinkedPorts []string `json:"linked_ports,omitempty"`
+	LastChange  string   `json:"last_change,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	LastChecked string   `json:"last_checked"`
 }
 
 // SourcesResponse represents the JSON response for sources endpoint
@@ -131,9 +144,11 @@ type BackingtrackSelectRequest struct {
 
 // MixRenderRequest represents a request to render a custom mix
 type MixRenderRequest struct {
-	Filename     string             `json:"filename"`
-	TrackVolumes map[string]float64 `json:"track_volumes"`
-	GlobalVolume *float64           `json:"global_volume,omitempty"`
+	Filename     string                     `json:"filename"`
+	TrackVolumes map[string]float64         `json:"track_volumes"`
+	TrackPans    map[string]float64         `json:"track_pans,omitempty"`    // -1 (left) to 1 (right), the channel's pan when missing
+	TrackEffects map[string][]config.Effect `json:"track_effects,omitempty"` // Replace the channel's effects chain, an empty list mixes it dry
+	GlobalVolume *float64                   `json:"global_volume,omitempty"`
 }
 
 // MixFilesResponse represents the response for listing MKV files
@@ -175,7 +190,7 @@ type ChannelInfo struct {
 	AudioMode   string   `json:"audioMode"`
 	Type        string   `json:"type"`
 	Volume      float64  `json:"volume"`
-	Delay       int      `json:"delay"`
+	Delay       float64  `json:"delay"` // Milliseconds, negative when the channel is pulled earlier
 	Inheritance string   `json:"inheritance"` // "inherited" or "profile-specific"
 }
 
@@ -199,6 +214,7 @@ func New(configFile string, port string) (*Server, error) {
 		configFile:    configFile,
 		port:          port,
 		activeProfile: activeProfileName,
+		done:          make(chan struct{}),
 	}, nil
 }
 
@@ -208,9 +224,15 @@ func (s *Server) Start() error {
 	http.HandleFunc("/config", s.handleConfigPage)
 	http.HandleFunc("/mix", s.handleMixPage)
 	http.HandleFunc("/ready", s.handleStartReady)
+	http.HandleFunc("/record", s.handleStartRecording)
 	http.HandleFunc("/cancel", s.handleCancelReady)
 	http.HandleFunc("/stop", s.handleStopRecording)
+	http.HandleFunc("/overdub", s.handleStartOverdub)
+	http.HandleFunc("/calibrate", s.handleCalibrate)
+	http.HandleFunc("/calibrate/save", s.handleSaveCalibration)
 	http.HandleFunc("/status", s.handleStatus)
+	http.HandleFunc("/sessions", s.handleSessions)
+	http.HandleFunc("/sessions/", s.handleSession)
 	http.HandleFunc("/config/profiles", s.handleProfiles)
 	http.HandleFunc("/config/select", s.handleSelectProfile)
 	http.HandleFunc("/config/active", s.handleActiveProfile)
@@ -218,6 +240,7 @@ func (s *Server) Start() error {
 	http.HandleFunc("/config/unlock", s.handleUnlockProfile)
 	http.HandleFunc("/config/details/", s.handleProfileDetails)
 	http.HandleFunc("/sources", s.handleSources)
+	http.HandleFunc("/levels", s.handleLevels)
 	http.HandleFunc("/api/files", s.handleFiles)
 	http.HandleFunc("/api/files/stream/", s.handleFileStream)
 	http.HandleFunc("/api/files/download/", s.handleFileDownload)
@@ -256,7 +279,26 @@ func (s *Server) Start() error {
 		"local_url", fmt.Sprintf("http://%s:%s", localIP, s.port),
 		"localhost_url", fmt.Sprintf("http://localhost:%s", s.port))
 
-	return http.ListenAndServe(":"+s.port, nil)
+	s.httpServer = &http.Server{Addr: ":" + s.port}
+	// Shutdown waits for the handlers, the level streams included
+	s.httpServer.RegisterOnShutdown(func() {
+		s.doneOnce.Do(func() { close(s.done) })
+	})
+	if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting requests, waits for those in flight until ctx is
+// done, then shuts the service down with every recorder it runs
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+	if s.httpServer != nil {
+		err = s.httpServer.Shutdown(ctx)
+	}
+	s.service.Shutdown()
+	return err
 }
 
 // handleIndex serves the main web UI
@@ -340,6 +382,30 @@ func (s *Server) handleStartReady(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The metronome's tempo and count-in can be overridden for this session
+	var bpm float64
+	if value := r.FormValue("bpm"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid bpm: %s", value),
+				"operation", "start_ready")
+			return
+		}
+		bpm = parsed
+	}
+	var countIn *int
+	if value := r.FormValue("count_in"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid count-in: %s", value),
+				"operation", "start_ready")
+			return
+		}
+		countIn = &parsed
+	}
+
 	// Auto mix will be read from configuration, but we can still accept override from web UI
 	// For backward compatibility, we could allow temporary override here
 	// But for now, we'll use the configuration value
@@ -347,22 +413,20 @@ func (s *Server) handleStartReady(w http.ResponseWriter, r *http.Request) {
 
 	// Reload config if profile is specified and different
 	if profile != "" {
-		newCfg, err := config.LoadWithProfile(s.configFile, profile)
-		if err != nil {
+		// Switch the default recorder, named sessions keep running
+		if err := s.service.LoadProfile(profile); err != nil {
 			s.sendErrorResponse(w, http.StatusBadRequest,
-				fmt.Sprintf("Failed to load profile '%s': %v", profile, err),
+				fmt.Sprintf("Failed to switch profile: %v", err),
 				"profile", profile, "operation", "profile_load_for_ready")
 			return
 		}
-		s.cfg = newCfg
+		s.cfg = s.service.GetConfig()
 		s.activeProfile = profile
-		// Create new service with updated config
-		s.service = service.New(s.cfg, s.configFile, nil)
 	}
 
 	// Transition to READY state
 	slog.Info("Server: Starting READY state", "song_name", songName, "profile", profile)
-	if err := s.service.StartReady(songName); err != nil {
+	if err := s.service.StartReadyWithMetronome(songName, bpm, countIn); err != nil {
 		slog.Error("Server: StartReady failed", "error", err, "song_name", songName)
 		s.sendErrorResponse(w, http.StatusInternalServerError,
 			fmt.Sprintf("Failed to start ready: %v", err),
@@ -382,6 +446,34 @@ func (s *Server) handleStartReady(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleStartRecording starts the take of a READY session without waiting
+// for its trigger (READY -> RECORDING)
+func (s *Server) handleStartRecording(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		})
+		return
+	}
+
+	if err := s.service.StartRecording(); err != nil {
+		s.sendErrorResponse(w, http.StatusConflict,
+			fmt.Sprintf("Failed to start recording: %v", err),
+			"operation", "start_recording")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Recording started",
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
 // handleCancelReady cancels READY state (READY -> STANDBY)
 func (s *Server) handleCancelReady(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -423,6 +515,9 @@ func (s *Server) handleStopRecording(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Takes split on silence are mixed one by one
+	_, session := s.service.GetRecordingStatus()
+
 	// Stop recording
 	if err := s.service.StopRecording(); err != nil {
 		s.sendErrorResponse(w, http.StatusInternalServerError,
@@ -433,15 +528,33 @@ func (s *Server) handleStopRecording(w http.ResponseWriter, r *http.Request) {
 
 	message := "Recording stopped"
 	var mixError string
+	if session != nil && session.Overdub != "" {
+		message = fmt.Sprintf("Overdub %s added to %s", session.Overdub, filepath.Base(session.OutputFile))
+	}
 
-	// Auto-mix if enabled in configuration
-	if s.cfg.AutoMix && s.lastSongName != "" {
-		slog.Info("Starting automatic mixing", "song", s.lastSongName)
-		if err := s.service.Mix(s.lastSongName); err != nil {
-			mixError = fmt.Sprintf("Mixing failed: %v", err)
-			slog.Error("Mixing failed", "error", err)
-		} else {
+	// Auto-mix if enabled in configuration; an overdub leaves the mix to the user
+	if s.cfg.AutoMix && s.lastSongName != "" && (session == nil || session.Overdub == "") {
+		songs := []string{s.lastSongName}
+		if session != nil && len(session.Takes) > 1 {
+			songs = songs[:0]
+			for _, take := range session.Takes {
+				songs = append(songs, strings.TrimSuffix(filepath.Base(take.File), ".mkv"))
+			}
+		}
+
+		for _, song := range songs {
+			slog.Info("Starting automatic mixing", "song", song)
+			if err := s.service.Mix(song); err != nil {
+				mixError = fmt.Sprintf("Mixing failed: %v", err)
+				slog.Error("Mixing failed", "song", song, "error", err)
+				break
+			}
+		}
+		if mixError == "" {
 			message = "Recording stopped and mixed successfully"
+			if len(songs) > 1 {
+				message = fmt.Sprintf("Recording stopped and %d takes mixed successfully", len(songs))
+			}
 			slog.Info("Mixing completed successfully")
 		}
 	}
@@ -460,6 +573,158 @@ func (s *Server) handleStopRecording(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleStartOverdub plays a recording, or the selected backing track, and
+// records input channels against it (STANDBY -> RECORDING). /stop appends the
+// new tracks to the recording.
+func (s *Server) handleStartOverdub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Failed to parse form", "operation", "start_overdub")
+		return
+	}
+
+	filename := r.FormValue("file")
+	if filename == "" {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Recording file is required", "operation", "start_overdub")
+		return
+	}
+
+	var tracks []int
+	for _, value := range splitList(r.FormValue("tracks")) {
+		index, err := strconv.Atoi(value)
+		if err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid track index: %s", value),
+				"operation", "start_overdub")
+			return
+		}
+		tracks = append(tracks, index)
+	}
+	useBackingtrack := r.FormValue("backingtrack") == "true"
+	channels := splitList(r.FormValue("channels"))
+
+	if err := s.service.StartOverdub(filename, tracks, useBackingtrack, channels); err != nil {
+		s.sendErrorResponse(w, http.StatusConflict,
+			fmt.Sprintf("Failed to start overdub: %v", err),
+			"file", filename, "operation", "start_overdub")
+		return
+	}
+	s.lastSongName = strings.TrimSuffix(filename, ".mkv")
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Overdub started",
+		"file":    filename,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCalibrate measures the latency of a monitor channel of the active
+// profile. The request returns once the test signal has played.
+func (s *Server) handleCalibrate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Failed to parse form", "operation", "calibrate")
+		return
+	}
+
+	channel := r.FormValue("channel")
+	input := r.FormValue("input")
+	result, err := s.service.Calibrate(channel, input)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusConflict,
+			fmt.Sprintf("Calibration failed: %v", err),
+			"channel", channel, "input", input, "operation", "calibrate")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"success":     true,
+		"message":     fmt.Sprintf("Measured %.2fms on %s", result.Latency, result.Channel),
+		"calibration": result,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSaveCalibration writes a measured delay to a channel of the active
+// profile in the config file
+func (s *Server) handleSaveCalibration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Failed to parse form", "operation", "save_calibration")
+		return
+	}
+
+	channel := r.FormValue("channel")
+	if channel == "" {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Channel is required", "operation", "save_calibration")
+		return
+	}
+	delay, err := strconv.ParseFloat(r.FormValue("delay"), 64)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid delay: %s", r.FormValue("delay")),
+			"operation", "save_calibration")
+		return
+	}
+
+	updated, err := s.service.SaveChannelDelay(s.activeProfile, channel, delay)
+	if err != nil {
+		s.sendErrorResponse(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to save delay: %v", err),
+			"channel", channel, "operation", "save_calibration")
+		return
+	}
+	s.cfg = s.service.GetConfig()
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Delay of %s set to %gms in %s", channel, delay, updated),
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// splitList splits a comma-separated form value, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // handleStatus returns the current status and session info
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -495,11 +760,21 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	// Generate status message
 	message := s.generateStatusMessage(status, session)
 
+	// Report channel states in configuration order
+	channelStatus := s.service.GetChannelStatus()
+	channels := make([]service.ChannelStatus, 0, len(s.cfg.Channels))
+	for _, ch := range s.cfg.Channels {
+		if state, exists := channelStatus[ch.Name]; exists {
+			channels = append(channels, state)
+		}
+	}
+
 	// Prepare response
 	response := StatusResponse{
 		Status:        string(status),
 		Message:       message,
 		Session:       session,
+		Channels:      channels,
 		Config:        resolvedConfig,
 		ActiveProfile: s.activeProfile,
 	}
@@ -508,6 +783,131 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// handleSessions lists the named recorder sessions
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": s.service.ListSessions(),
+	})
+}
+
+// handleSession controls a named recorder session through /sessions/{id}/ready,
+// /sessions/{id}/stop, /sessions/{id}/remove and /sessions/{id}/status
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/sessions/"), "/")
+	if id == "" {
+		s.sendErrorResponse(w, http.StatusBadRequest, "Session ID is required", "operation", "session")
+		return
+	}
+
+	method := http.MethodPost
+	if action == "status" {
+		method = http.MethodGet
+	}
+	if r.Method != method {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		})
+		return
+	}
+
+	switch action {
+	case "ready":
+		if err := r.ParseForm(); err != nil {
+			s.sendErrorResponse(w, http.StatusBadRequest, "Failed to parse form", "session", id, "operation", "session_ready")
+			return
+		}
+		songName := r.FormValue("song")
+		profile := r.FormValue("profile")
+		if songName == "" {
+			s.sendErrorResponse(w, http.StatusBadRequest, "Song name is required", "session", id, "operation", "session_ready")
+			return
+		}
+
+		if err := s.service.StartSessionReady(id, profile, songName); err != nil {
+			s.sendErrorResponse(w, http.StatusConflict,
+				fmt.Sprintf("Failed to start ready: %v", err),
+				"session", id, "song_name", songName, "profile", profile, "operation", "session_ready")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Ready state activated",
+			"session": id,
+			"song":    songName,
+			"profile": profile,
+		})
+
+	case "stop":
+		if _, err := s.service.GetSession(id); err != nil {
+			s.sendErrorResponse(w, http.StatusNotFound, err.Error(), "session", id, "operation", "session_stop")
+			return
+		}
+		if err := s.service.StopSession(id); err != nil {
+			s.sendErrorResponse(w, http.StatusConflict,
+				fmt.Sprintf("Failed to stop session: %v", err),
+				"session", id, "operation", "session_stop")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Session stopped",
+			"session": id,
+		})
+
+	case "remove":
+		if _, err := s.service.GetSession(id); err != nil {
+			s.sendErrorResponse(w, http.StatusNotFound, err.Error(), "session", id, "operation", "session_remove")
+			return
+		}
+		if err := s.service.RemoveSession(id); err != nil {
+			s.sendErrorResponse(w, http.StatusConflict,
+				fmt.Sprintf("Failed to remove session: %v", err),
+				"session", id, "operation", "session_remove")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Session removed",
+			"session": id,
+		})
+
+	case "status":
+		session, err := s.service.GetSession(id)
+		if err != nil {
+			s.sendErrorResponse(w, http.StatusNotFound, err.Error(), "session", id, "operation", "session_status")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session)
+
+	default:
+		s.sendErrorResponse(w, http.StatusNotFound,
+			fmt.Sprintf("Unknown session action '%s', expected ready, stop, remove or status", action),
+			"session", id, "operation", "session")
+	}
+}
+
 // handleProfiles returns available configuration profiles
 func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -550,7 +950,7 @@ func (s *Server) getResolvedConfigInfo() *ResolvedConfigInfo {
 			AudioMode:   ch.AudioMode,
 			Type:        ch.Type,
 			Volume:      ch.Volume,
-			Delay:       ch.Delay,
+			Delay:       ch.DelayMs(s.cfg.GetSampleRate()),
 			Inheritance: inheritance,
 		}
 	}
@@ -559,7 +959,7 @@ func (s *Server) getResolvedConfigInfo() *ResolvedConfigInfo {
 		ActiveProfile: "current", // Could be enhanced to track actual active profile
 		OutputDir:     s.cfg.Output.Directory,
 		Channels:      channels,
-		SampleRate:    s.cfg.Audio.SampleRate,
+		SampleRate:    s.cfg.GetSampleRate(),
 		Format:        s.cfg.Output.Format,
 		AutoMix:       s.cfg.AutoMix,
 		Mix:           s.buildMixInfo(),
@@ -587,7 +987,7 @@ func (s *Server) buildMixInfoFromConfig(cfg *config.Config) MixInfo {
 	if len(cfg.Channels) > 0 {
 		for _, channel := range cfg.Channels {
 			if channel.Delay > 0 {
-				delayMs = channel.Delay
+				delayMs = int(math.Round(channel.Delay))
 				break
 			}
 		}
@@ -651,22 +1051,24 @@ func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
 	// Build sources response from configured channels
 	if s.cfg != nil && s.cfg.Channels != nil {
 		for _, ch := range s.cfg.Channels {
-			status, exists := channelStatus[ch.Name]
-			if !exists {
-				status = "unknown"
-			}
-
-			slog.Debug("Channel status", "channel", ch.Name, "sources", ch.Sources, "status", status)
-
-			// Create a source info entry for this channel
-			sourceDesc := strings.Join(ch.Sources, ", ")
-			sources = append(sources, SourceInfo{
+			info := SourceInfo{
 				Name:        ch.Name,
-				Source:      sourceDesc,
+				Source:      strings.Join(ch.Sources, ", "),
 				Type:        ch.Type,
-				Status:      status,
+				Status:      "unknown",
 				LastChecked: time.Now().Format(time.RFC3339),
-			})
+			}
+
+			if state, exists := channelStatus[ch.Name]; exists {
+				info.Status = sourceAvailability(state.State)
+				info.State = state.State
+				info.LinkedPorts = state.LinkedPorts
+				info.LastChange = state.LastChange.Format(time.RFC3339)
+				info.Error = state.Error
+			}
+
+			slog.Debug("Channel status", "channel", ch.Name, "sources", ch.Sources, "state", info.State)
+			sources = append(sources, info)
 		}
 	}
 
@@ -674,6 +1076,74 @@ func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(SourcesResponse{Sources: sources})
 }
 
+// levelStreamInterval is how often channel levels are pushed to the browser (20 Hz)
+const levelStreamInterval = 50 * time.Millisecond
+
+// handleLevels streams per-channel peak and RMS levels as Server-Sent Events.
+// An empty list is sent while not recording.
+func (s *Server) handleLevels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.sendErrorResponse(w, http.StatusInternalServerError, "Streaming not supported", "operation", "levels")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(levelStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-ticker.C:
+			levels := s.service.GetChannelLevels()
+			if levels == nil {
+				levels = []service.ChannelLevel{}
+			}
+			data, err := json.Marshal(levels)
+			if err != nil {
+				slog.Error("Failed to encode channel levels", "error", err)
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// sourceAvailability maps a channel connection state to the availability
+// reported by /sources before channel states existed
+func sourceAvailability(state string) string {
+	switch state {
+	case "found", "linked", "reconnected":
+		return "available"
+	case "duplicate":
+		return "duplicate"
+	case "waiting", "dropped":
+		return "unavailable"
+	default:
+		return "unknown"
+	}
+}
+
 // getLocalIP returns the local IP address for network access
 // handleConfigPage serves the configuration page
 func (s *Server) handleConfigPage(w http.ResponseWriter, r *http.Request) {
@@ -776,8 +1246,14 @@ func (s *Server) handleSelectProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create new service with updated config
-	s.service = service.New(s.cfg, s.configFile, nil)
+	// Switch the default recorder, named sessions keep running
+	if err := s.service.LoadProfile(s.activeProfile); err != nil {
+		s.sendErrorResponse(w, http.StatusInternalServerError,
+			fmt.Sprintf("Failed to switch profile: %v", err),
+			"profile", s.activeProfile, "operation", "select_profile")
+		return
+	}
+	s.cfg = s.service.GetConfig()
 
 	slog.Info("Profile changed", "profile", s.activeProfile)
 
@@ -946,7 +1422,7 @@ func (s *Server) handleProfileDetails(w http.ResponseWriter, r *http.Request) {
 			AudioMode:   ch.AudioMode,
 			Type:        ch.Type,
 			Volume:      ch.Volume,
-			Delay:       ch.Delay,
+			Delay:       ch.DelayMs(cfg.GetSampleRate()),
 			Inheritance: inheritance,
 		}
 	}
@@ -954,7 +1430,7 @@ func (s *Server) handleProfileDetails(w http.ResponseWriter, r *http.Request) {
 	profileInfo := &ResolvedConfigInfo{
 		OutputDir:  cfg.Output.Directory,
 		Channels:   channels,
-		SampleRate: cfg.Audio.SampleRate,
+		SampleRate: cfg.GetSampleRate(),
 		Format:     cfg.Output.Format,
 		AutoMix:    cfg.AutoMix,
 		Mix:        s.buildMixInfoFromConfig(cfg),
@@ -2562,10 +3038,23 @@ func (s *Server) getBackingtracksDirectory() string {
 func (s *Server) generateStatusMessage(status service.RecordingStatus, session *service.RecordingSession) string {
 	switch status {
 	case service.StatusStandby:
-		return ""
+		// A session the recorder ended on its own, such as a READY timeout
+		return s.service.GetLastError()
 	case service.StatusReady:
+		if session != nil && session.CountingIn {
+			return fmt.Sprintf("Count-in at %.0f bpm - recording starts on the downbeat", session.BPM)
+		}
+		if session != nil && session.Armed {
+			if session.Trigger == config.TriggerSignal {
+				return fmt.Sprintf("Armed - recording starts when %s reaches %.0f dBFS", s.cfg.GetTrigger().Channel, s.cfg.GetTrigger().Threshold)
+			}
+			return "Armed - press Record to start the take"
+		}
 		return "Waiting for audio sources - Please start audio playback"
 	case service.StatusRecording:
+		if session != nil && session.Overdub != "" {
+			return fmt.Sprintf("Overdub in progress - %s (%s)", session.SongName, session.Overdub)
+		}
 		if session != nil {
 			return fmt.Sprintf("Recording in progress - %s", session.SongName)
 		}
@@ -2779,8 +3268,46 @@ func (s *Server) handleMixRender(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for track, pan := range req.TrackPans {
+		if pan < -1 || pan > 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(GenericResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Pan of %s must be between -1 and 1, got: %.2f", track, pan),
+			})
+			return
+		}
+	}
+	for track, effects := range req.TrackEffects {
+		if err := config.ValidateMixEffects(effects); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(GenericResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid effects for %s: %v", track, err),
+			})
+			return
+		}
+	}
+
 	// Perform the mix with global volume if provided
-	if req.GlobalVolume != nil {
+	if len(req.TrackPans) > 0 || len(req.TrackEffects) > 0 {
+		globalVolume := 1.0
+		if req.GlobalVolume != nil {
+			globalVolume = *req.GlobalVolume
+		}
+		slog.Debug("Received mix request with pans and effects", "filename", req.Filename, "global_volume", globalVolume, "track_volumes", req.TrackVolumes, "track_pans", req.TrackPans, "track_effects", req.TrackEffects)
+		if err := s.service.MixWithTrackEffects(req.Filename, req.TrackVolumes, req.TrackPans, req.TrackEffects, globalVolume); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(GenericResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Failed to create mix: %v", err),
+			})
+			return
+		}
+	} else if req.GlobalVolume != nil {
 		slog.Debug("Received mix request with global volume", "filename", req.Filename, "global_volume", *req.GlobalVolume, "track_volumes", req.TrackVolumes)
 		if err := s.service.MixWithTrackAndGlobalVolumes(req.Filename, req.TrackVolumes, *req.GlobalVolume); err != nil {
 			w.Header().Set("Content-Type", "application/json")
@@ -25,10 +25,28 @@ import (
 type Service interface {
 	// Recording operations
 	StartReady(songName string) error
+	StartRecording() error
 	CancelReady() error
 	StopRecording() error
 	GetRecordingStatus() (RecordingStatus, *RecordingSession)
 
+	// StartReadyWithMetronome prepares for recording with the tempo and
+	// count-in of the profile's metronome overridden: a bpm of 0 and a nil
+	// countIn keep the profile's
+	StartReadyWithMetronome(songName string, bpm float64, countIn *int) error
+
+	// StartOverdub records input channels against the tracks of a recording,
+	// or the selected backing track, and appends them to it on stop
+	StartOverdub(filename string, tracks []int, useBackingtrack bool, channels []string) error
+
+	// Session operations: named recorders running alongside the default one,
+	// each with its own profile
+	StartSessionReady(id, profile, songName string) error
+	StopSession(id string) error
+	RemoveSession(id string) error
+	GetSession(id string) (*SessionStatus, error)
+	ListSessions() []SessionStatus
+
 	// Mixing operations
 	Mix(songName string) error
 	MixWithOptions(songName string, guitarVolume, backingVolume float64, delay int) error
@@ -45,9 +63,15 @@ type Service interface {
 
 	// Information operations
 	GetSongInfo(songName string) (*SongInfo, error)
-	GetChannelStatus() map[string]string
+	GetChannelStatus() map[string]ChannelStatus
+	GetChannelLevels() []ChannelLevel
 	GetLastError() string
 
+	// Calibration operations: Calibrate measures the latency of a monitor
+	// channel, SaveChannelDelay writes it to the config file
+	Calibrate(channel, input string) (*CalibrationResult, error)
+	SaveChannelDelay(profile, channel string, delay float64) (string, error)
+
 	// Backing track operations
 	ListBackingtracks() ([]BackingtrackInfo, error)
 	GetSelectedBackingtrack() (*BackingtrackInfo, error)
@@ -59,7 +83,11 @@ type Service interface {
 	AnalyzeMKVFile(filename string) (*MKVAnalysis, error)
 	MixWithTrackVolumes(filename string, trackVolumes map[string]float64) error
 	MixWithTrackAndGlobalVolumes(filename string, trackVolumes map[string]float64, globalVolume float64) error
+	MixWithTrackEffects(filename string, trackVolumes, trackPans map[string]float64, trackEffects map[string][]config.Effect, globalVolume float64) error
 	GetLastMixedFile() string
+
+	// Shutdown stops the takes being recorded and releases every recorder
+	Shutdown()
 }
 
 // RecordingStatus represents the current recording state
@@ -74,11 +102,76 @@ const (
 
 // RecordingSession contains information about the current recording session
 type RecordingSession struct {
-	SongName     string    `json:"song_name"`
-	StartTime    time.Time `json:"start_time"`
-	OutputFile   string    `json:"output_file"`
-	ChannelCount int       `json:"channel_count"`
-	ChannelNames []string  `json:"channel_names"`
+	SongName         string            `json:"song_name"`
+	StartTime        time.Time         `json:"start_time"`
+	OutputFile       string            `json:"output_file"`
+	ChannelCount     int               `json:"channel_count"`
+	ChannelNames     []string          `json:"channel_names"`
+	ConnectionEvents []ConnectionEvent `json:"connection_events,omitempty"`
+	Trigger          string            `json:"trigger"`                 // "sources", "signal" or "manual"
+	Armed            bool              `json:"armed,omitempty"`         // Capturing while READY, waiting for the trigger
+	PreRoll          float64           `json:"pre_roll,omitempty"`      // Seconds of audio recorded before the trigger
+	Takes            []Take            `json:"takes,omitempty"`         // Takes split on silence, in order
+	Overdub          string            `json:"overdub,omitempty"`       // Take being overdubbed onto OutputFile
+	BackingTrack     string            `json:"backing_track,omitempty"` // Backing track played into the playback channel
+	BPM              float64           `json:"bpm,omitempty"`           // Tempo of the metronome, 0 without click
+	CountingIn       bool              `json:"counting_in,omitempty"`   // READY, the take starts on the downbeat ending the count-in
+}
+
+// SessionStatus reports a named recorder session of the service
+type SessionStatus struct {
+	ID       string            `json:"id"`
+	Profile  string            `json:"profile"`
+	Client   string            `json:"client"` // Prefix of the JACK clients capturing its channels
+	Status   RecordingStatus   `json:"status"`
+	Session  *RecordingSession `json:"session,omitempty"`
+	Channels []ChannelStatus   `json:"channels"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Take is one file of a session split on silence
+type Take struct {
+	Number    int       `json:"number"`
+	File      string    `json:"file"`
+	StartTime time.Time `json:"start_time"`
+	Duration  float64   `json:"duration"` // Seconds, 0 while the take is recording
+}
+
+// ConnectionEvent records a source dropping out or reconnecting during recording
+type ConnectionEvent struct {
+	Time    time.Time `json:"time"`
+	Offset  float64   `json:"offset"` // Seconds into the take
+	Type    string    `json:"type"`   // "dropped", "reconnected"
+	Channel string    `json:"channel"`
+	Source  string    `json:"source"`
+	Take    int       `json:"take,omitempty"`
+}
+
+// CalibrationResult is the latency measured for a monitor channel
+type CalibrationResult struct {
+	Channel      string  `json:"channel"`
+	Input        string  `json:"input"`
+	Latency      float64 `json:"latency_ms"`    // Round trip from the monitor capture to the input, in milliseconds
+	Delay        float64 `json:"delay"`         // Latency rounded to a tenth of a millisecond for the channel's 'delay'
+	CurrentDelay float64 `json:"current_delay"` // The channel's delay in milliseconds when measured
+	Confidence   float64 `json:"confidence"`    // Normalized correlation peak, 0 to 1
+}
+
+// ChannelStatus reports the connection state of a channel
+type ChannelStatus struct {
+	Name        string    `json:"name"`
+	State       string    `json:"state"` // "waiting", "found", "duplicate", "linked", "dropped", "reconnected"
+	LinkedPorts []string  `json:"linked_ports,omitempty"`
+	LastChange  time.Time `json:"last_change"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// ChannelLevel is the signal level of a channel during recording
+type ChannelLevel struct {
+	Name     string  `json:"name"`
+	Peak     float64 `json:"peak"` // dBFS
+	RMS      float64 `json:"rms"`  // dBFS
+	Clipping bool    `json:"clipping"`
 }
 
 // SongInfo contains file path information for a song
@@ -116,9 +209,10 @@ type MKVFileInfo struct {
 
 // MKVAnalysis contains track information extracted from an MKV file
 type MKVAnalysis struct {
-	Filename    string      `json:"filename"`
-	TrackCount  int         `json:"track_count"`
-	Tracks      []TrackInfo `json:"tracks"`
+	Filename    string        `json:"filename"`
+	TrackCount  int           `json:"track_count"`
+	Tracks      []TrackInfo   `json:"tracks"`
+	Alignment   []TrackOffset `json:"alignment,omitempty"` // Offsets of the last aligned mix
 }
 
 // TrackInfo contains information about a single track within an MKV file
@@ -127,6 +221,19 @@ type TrackInfo struct {
 	Name     string `json:"name"`
 	Title    string `json:"title"`
 	Channels int    `json:"channels"`
+	Take     string `json:"take,omitempty"` // Overdub the track was added by, empty for the original take
+}
+
+// TrackOffset is the offset an aligned mix measured between a monitor track
+// and the reference track
+type TrackOffset struct {
+	Track      int     `json:"track"`
+	Channel    string  `json:"channel"`
+	Reference  string  `json:"reference"`
+	Offset     float64 `json:"offset_ms"`  // How late the reference hears the monitor, negative when it hears it early
+	Confidence float64 `json:"confidence"` // How alike the tracks are at that offset, from 0 to 1
+	Delay      float64 `json:"delay_ms"`   // Delay the mix applied, negative for a trim
+	Applied    bool    `json:"applied"`    // False when the configured delay was kept
 }
 
 // MixOptions contains mixing configuration
@@ -158,25 +265,54 @@ type JamCaptureService struct {
 	// Error tracking
 	lastError      string
 	lastErrorMutex sync.RWMutex
+
+	// Named recorder sessions, by ID
+	sessions      map[string]*recorderSession
+	sessionsMutex sync.Mutex
+}
+
+// recorderSession is a named recorder of the service with its own profile
+type recorderSession struct {
+	id       string
+	profile  string
+	client   string
+	cfg      *config.Config
+	recorder audio.Recorder
 }
 
+// recoverOnce rebuilds the recordings interrupted by a crash once per process
+var recoverOnce sync.Once
+
 // New creates a new JamCapture service instance
 func New(cfg *config.Config, configFile string, logWriter io.Writer) Service {
 	if logWriter == nil {
 		logWriter = io.Discard
 	}
 
+	recoverOnce.Do(func() {
+		for _, file := range audio.RecoverSegments(cfg.Output.Directory) {
+			slog.Info("Recovered interrupted recording", "file", file)
+		}
+	})
+
 	return &JamCaptureService{
 		cfg:        cfg,
 		configFile: configFile,
 		recorder:   audio.NewRecorder(cfg, logWriter),
 		logWriter:  logWriter,
+		sessions:   make(map[string]*recorderSession),
 	}
 }
 
 // StartReady prepares for recording (STANDBY -> READY)
 func (s *JamCaptureService) StartReady(songName string) error {
-	slog.Debug("Service.StartReady called", "song_name", songName)
+	return s.StartReadyWithMetronome(songName, 0, nil)
+}
+
+// StartReadyWithMetronome prepares for recording (STANDBY -> READY) with the
+// tempo and count-in of the metronome overridden for this session
+func (s *JamCaptureService) StartReadyWithMetronome(songName string, bpm float64, countIn *int) error {
+	slog.Debug("Service.StartReady called", "song_name", songName, "bpm", bpm)
 	s.clearLastError() // Clear any previous errors when starting a new operation
 
 	// Validate song name
@@ -187,6 +323,31 @@ func (s *JamCaptureService) StartReady(songName string) error {
 		return err
 	}
 
+	if err := s.cfg.ValidateMetronomeOverride(bpm, countIn); err != nil {
+		slog.Error("Service.StartReady validation failed", "error", err)
+		s.setLastError(err.Error())
+		return err
+	}
+	if bpm != 0 || countIn != nil {
+		if _, ok := s.recorder.(audio.MetronomePlayer); !ok {
+			err := fmt.Errorf("the %s audio backend has no metronome", s.cfg.Audio.Backend)
+			s.setLastError(err.Error())
+			return err
+		}
+	}
+
+	// A named session writing the same file would overwrite the take
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+	outputFile := filepath.Join(s.cfg.Output.Directory, cleanFileName(songName)+".mkv")
+	if owner := s.recordingOwner(outputFile, ""); owner != "" {
+		err := fmt.Errorf("song '%s' is already being recorded by %s", songName, owner)
+		s.setLastError(err.Error())
+		return err
+	}
+
+	s.offerBackingTrack(s.recorder)
+	s.offerMetronome(s.recorder, bpm, countIn)
 	err := s.recorder.StartReady(songName)
 	if err != nil {
 		slog.Error("Service.StartReady failed", "error", err)
@@ -197,6 +358,41 @@ func (s *JamCaptureService) StartReady(songName string) error {
 	return err
 }
 
+// offerBackingTrack hands the selected backing track to a recorder that plays
+// it into a playback channel when the take starts
+func (s *JamCaptureService) offerBackingTrack(recorder audio.Recorder) {
+	player, ok := recorder.(audio.BackingTrackPlayer)
+	if !ok {
+		return
+	}
+
+	file := ""
+	if backingtrack, err := s.GetSelectedBackingtrack(); err != nil {
+		slog.Warn("Failed to read selected backing track", "error", err)
+	} else if backingtrack != nil {
+		file = backingtrack.Path
+	}
+	player.SetBackingTrack(file)
+}
+
+// offerMetronome hands the tempo and count-in of the next session to a
+// recorder that plays the metronome, clearing any earlier override
+func (s *JamCaptureService) offerMetronome(recorder audio.Recorder, bpm float64, countIn *int) {
+	if player, ok := recorder.(audio.MetronomePlayer); ok {
+		player.SetMetronome(bpm, countIn)
+	}
+}
+
+// StartRecording starts the take of a READY session without waiting for its
+// trigger (READY -> RECORDING)
+func (s *JamCaptureService) StartRecording() error {
+	err := s.recorder.StartRecording()
+	if err != nil {
+		s.setLastError(fmt.Sprintf("Failed to start recording: %v", err))
+	}
+	return err
+}
+
 // CancelReady cancels ready state (READY -> STANDBY)
 func (s *JamCaptureService) CancelReady() error {
 	return s.recorder.CancelReady()
@@ -213,42 +409,118 @@ func (s *JamCaptureService) StopRecording() error {
 	return err
 }
 
+// StartOverdub plays a recording of the output directory, or the selected
+// backing track, and records input channels against it (STANDBY -> RECORDING).
+// StopRecording appends the new tracks to the recording.
+func (s *JamCaptureService) StartOverdub(filename string, tracks []int, useBackingtrack bool, channels []string) error {
+	s.clearLastError()
+
+	if filepath.Base(filename) != filename || filepath.Ext(filename) != ".mkv" {
+		err := fmt.Errorf("invalid recording name: %s", filename)
+		s.setLastError(err.Error())
+		return err
+	}
+
+	overdubber, ok := s.recorder.(audio.Overdubber)
+	if !ok {
+		err := fmt.Errorf("the %s audio backend cannot overdub", s.cfg.Audio.Backend)
+		s.setLastError(err.Error())
+		return err
+	}
+
+	options := audio.OverdubOptions{
+		File:     filepath.Join(s.cfg.Output.Directory, filename),
+		Tracks:   tracks,
+		Channels: channels,
+	}
+	if useBackingtrack {
+		backingtrack, err := s.GetSelectedBackingtrack()
+		if err != nil {
+			return fmt.Errorf("failed to read selected backing track: %w", err)
+		}
+		if backingtrack == nil {
+			err := fmt.Errorf("no backing track selected")
+			s.setLastError(err.Error())
+			return err
+		}
+		options.BackingTrack = backingtrack.Path
+	}
+
+	if err := overdubber.StartOverdub(options); err != nil {
+		slog.Error("Service.StartOverdub failed", "file", filename, "error", err)
+		s.setLastError(fmt.Sprintf("Failed to start overdub: %v", err))
+		return err
+	}
+	return nil
+}
+
 // GetRecordingStatus returns the current recording status and session info
 func (s *JamCaptureService) GetRecordingStatus() (RecordingStatus, *RecordingSession) {
 	status, session := s.recorder.GetStatus()
 
-	// Convert from audio.Status to service.RecordingStatus
-	var svcStatus RecordingStatus
-	switch status {
-	case audio.StatusStandby:
-		svcStatus = StatusStandby
-		// Auto-clear any previous errors when returning to STANDBY
+	// Auto-clear any previous errors when returning to STANDBY, or when
+	// successfully reaching READY or RECORDING
+	svcStatus := convertStatus(status)
+	if svcStatus != StatusError {
 		s.clearLastError()
+	}
+
+	return svcStatus, convertSession(session)
+}
+
+// convertStatus converts a recorder status to a service status
+func convertStatus(status audio.Status) RecordingStatus {
+	switch status {
 	case audio.StatusReady:
-		svcStatus = StatusReady
-		// Auto-clear any previous errors when successfully reaching READY
-		s.clearLastError()
+		return StatusReady
 	case audio.StatusRecording:
-		svcStatus = StatusRecording
-		// Auto-clear any previous errors when successfully recording
-		s.clearLastError()
+		return StatusRecording
 	case audio.StatusError:
-		svcStatus = StatusError
+		return StatusError
+	default:
+		return StatusStandby
 	}
+}
 
-	// Convert session if present
-	var svcSession *RecordingSession
-	if session != nil {
-		svcSession = &RecordingSession{
-			SongName:     session.SongName,
-			StartTime:    session.StartTime,
-			OutputFile:   session.OutputFile,
-			ChannelCount: session.ChannelCount,
-			ChannelNames: session.ChannelNames,
-		}
+// convertSession converts a recorder session, if present, to a service session
+func convertSession(session *audio.SessionInfo) *RecordingSession {
+	if session == nil {
+		return nil
 	}
 
-	return svcStatus, svcSession
+	svcSession := &RecordingSession{
+		SongName:     session.SongName,
+		StartTime:    session.StartTime,
+		OutputFile:   session.OutputFile,
+		ChannelCount: session.ChannelCount,
+		ChannelNames: session.ChannelNames,
+		Trigger:      session.Trigger,
+		Armed:        session.Armed,
+		PreRoll:      session.PreRoll,
+		Overdub:      session.Overdub,
+		BackingTrack: session.BackingTrack,
+		BPM:          session.BPM,
+		CountingIn:   session.CountingIn,
+	}
+	for _, event := range session.ConnectionEvents {
+		svcSession.ConnectionEvents = append(svcSession.ConnectionEvents, ConnectionEvent{
+			Time:    event.Time,
+			Offset:  event.Offset,
+			Type:    string(event.Type),
+			Channel: event.Channel,
+			Source:  event.Source,
+			Take:    event.Take,
+		})
+	}
+	for _, take := range session.Takes {
+		svcSession.Takes = append(svcSession.Takes, Take{
+			Number:    take.Number,
+			File:      take.File,
+			StartTime: take.StartTime,
+			Duration:  take.Duration,
+		})
+	}
+	return svcSession
 }
 
 // Mix mixes recorded tracks using configuration defaults
@@ -353,11 +625,337 @@ func (s *JamCaptureService) GetSongInfo(songName string) (*SongInfo, error) {
 	}, nil
 }
 
-// GetChannelStatus returns the availability status of configured channels
-func (s *JamCaptureService) GetChannelStatus() map[string]string {
-	return s.recorder.GetChannelStatus()
+// GetChannelStatus returns the connection state of configured channels
+func (s *JamCaptureService) GetChannelStatus() map[string]ChannelStatus {
+	return convertChannelStatus(s.recorder.GetChannelStatus())
 }
 
+// convertChannelStatus converts the channel states of a recorder
+func convertChannelStatus(channels map[string]audio.ChannelStatus) map[string]ChannelStatus {
+	result := make(map[string]ChannelStatus, len(channels))
+	for name, channel := range channels {
+		result[name] = ChannelStatus{
+			Name:        channel.Name,
+			State:       string(channel.State),
+			LinkedPorts: channel.LinkedPorts,
+			LastChange:  channel.LastChange,
+			Error:       channel.Error,
+		}
+	}
+	return result
+}
+
+// GetChannelLevels returns the latest per-channel levels while recording or
+// armed, or nil when the recorder does not meter its channels
+func (s *JamCaptureService) GetChannelLevels() []ChannelLevel {
+	source, ok := s.recorder.(audio.LevelSource)
+	if !ok {
+		return nil
+	}
+
+	var levels []ChannelLevel
+	for _, level := range source.GetLevels() {
+		levels = append(levels, ChannelLevel{
+			Name:     level.Name,
+			Peak:     level.Peak,
+			RMS:      level.RMS,
+			Clipping: level.Clipping,
+		})
+	}
+	return levels
+}
+
+// ===== SESSION SERVICE METHODS =====
+
+// sessionClient returns the JACK client prefix of a named session. Session IDs
+// hold no underscore, so jamcapture-room_guitar cannot be mistaken for the
+// guitar channel of another session, nor for a channel of the default recorder.
+func sessionClient(id string) string {
+	return audio.DefaultClientName + "-" + id
+}
+
+// validateSessionID checks that a session ID can be used in JACK client names
+func validateSessionID(id string) error {
+	if id == "" {
+		return fmt.Errorf("session ID cannot be empty")
+	}
+	if len(id) > 32 {
+		return fmt.Errorf("session ID must be 32 characters or less")
+	}
+	for _, r := range id {
+		if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-') {
+			return fmt.Errorf("session ID contains invalid character '%c'. Only lowercase letters, numbers and hyphens (-) are allowed.", r)
+		}
+	}
+	return nil
+}
+
+// Calibrate measures the latency of a monitor channel of the loaded profile,
+// heard back through one of its input channels. It takes a few seconds and
+// needs every recorder in STANDBY, since it links the same sources.
+func (s *JamCaptureService) Calibrate(channel, input string) (*CalibrationResult, error) {
+	if status, _ := s.recorder.GetStatus(); status == audio.StatusReady || status == audio.StatusRecording {
+		return nil, fmt.Errorf("cannot calibrate while a session is %s", status)
+	}
+	for _, session := range s.ListSessions() {
+		if session.Status == StatusReady || session.Status == StatusRecording {
+			return nil, fmt.Errorf("cannot calibrate while session '%s' is %s", session.ID, session.Status)
+		}
+	}
+
+	result, err := audio.Calibrate(s.cfg, audio.CalibrationOptions{Channel: channel, Input: input}, s.logWriter)
+	if err != nil {
+		return nil, err
+	}
+	return &CalibrationResult{
+		Channel:      result.Channel,
+		Input:        result.Input,
+		Latency:      result.Latency,
+		Delay:        result.Delay,
+		CurrentDelay: result.CurrentDelay,
+		Confidence:   result.Confidence,
+	}, nil
+}
+
+// SaveChannelDelay writes the delay of a channel of the loaded profile to the
+// config file and applies it to the next mixes. It returns the definition or
+// profile updated.
+func (s *JamCaptureService) SaveChannelDelay(profile, channel string, delay float64) (string, error) {
+	updated, err := config.SaveChannelDelay(s.configFile, profile, channel, delay)
+	if err != nil {
+		return "", err
+	}
+
+	s.configMutex.Lock()
+	for i := range s.cfg.Channels {
+		if s.cfg.Channels[i].Name == channel {
+			s.cfg.Channels[i].Delay = delay
+			s.cfg.Channels[i].DelaySamples = 0
+		}
+	}
+	s.configMutex.Unlock()
+
+	slog.Info("Channel delay saved", "channel", channel, "delay", delay, "updated", updated)
+	return updated, nil
+}
+
+// StartSessionReady puts a named session in READY, creating its recorder with
+// profile on first use. A session in STANDBY switches to profile when it
+// differs; an empty profile keeps the session's profile.
+func (s *JamCaptureService) StartSessionReady(id, profile, songName string) error {
+	if err := validateSessionID(id); err != nil {
+		return err
+	}
+	if errMsg := validateFileName(songName); errMsg != "" {
+		return fmt.Errorf("invalid song name: %s", errMsg)
+	}
+
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists && profile == "" {
+		return fmt.Errorf("a profile is required to create session '%s'", id)
+	}
+	if !exists || (profile != "" && profile != session.profile) {
+		if exists {
+			if status, _ := session.recorder.GetStatus(); status == audio.StatusReady || status == audio.StatusRecording {
+				return fmt.Errorf("session '%s' is %s with profile '%s'", id, status, session.profile)
+			}
+			session.recorder.Cleanup()
+		}
+
+		cfg, err := config.LoadWithProfile(s.configFile, profile)
+		if err != nil {
+			return fmt.Errorf("failed to load profile '%s': %w", profile, err)
+		}
+		session = &recorderSession{
+			id:       id,
+			profile:  profile,
+			client:   sessionClient(id),
+			cfg:      cfg,
+			recorder: audio.NewRecorder(cfg, s.logWriter),
+		}
+		if namer, ok := session.recorder.(audio.ClientNamer); ok {
+			namer.SetClientName(session.client)
+		}
+		s.sessions[id] = session
+	}
+
+	// Two sessions writing the same file would overwrite each other's take
+	outputFile := filepath.Join(session.cfg.Output.Directory, cleanFileName(songName)+".mkv")
+	if owner := s.recordingOwner(outputFile, id); owner != "" {
+		return fmt.Errorf("song '%s' is already being recorded by %s", songName, owner)
+	}
+
+	slog.Info("Starting session READY", "session", id, "profile", session.profile, "song_name", songName)
+	s.offerBackingTrack(session.recorder)
+	s.offerMetronome(session.recorder, 0, nil)
+	if err := session.recorder.StartReady(songName); err != nil {
+		return fmt.Errorf("failed to start session '%s': %w", id, err)
+	}
+	return nil
+}
+
+// recordingOwner returns which recorder, other than session exceptID, has a
+// session writing outputFile, or "" if none. Must hold s.sessionsMutex.
+func (s *JamCaptureService) recordingOwner(outputFile, exceptID string) string {
+	writes := func(recorder audio.Recorder) bool {
+		status, session := recorder.GetStatus()
+		active := status == audio.StatusReady || status == audio.StatusRecording
+		return active && session != nil && filepath.Clean(session.OutputFile) == filepath.Clean(outputFile)
+	}
+
+	if writes(s.recorder) {
+		return "the default session"
+	}
+	for id, session := range s.sessions {
+		if id != exceptID && writes(session.recorder) {
+			return fmt.Sprintf("session '%s'", id)
+		}
+	}
+	return ""
+}
+
+// StopSession stops a named session: a READY session returns to STANDBY, a
+// recording is stopped and, when its profile enables auto_mix, every take is mixed
+func (s *JamCaptureService) StopSession(id string) error {
+	s.sessionsMutex.Lock()
+	session, exists := s.sessions[id]
+	s.sessionsMutex.Unlock()
+	if !exists {
+		return fmt.Errorf("session '%s' not found", id)
+	}
+
+	status, recording := session.recorder.GetStatus()
+	switch status {
+	case audio.StatusReady:
+		return session.recorder.CancelReady()
+	case audio.StatusRecording:
+	default:
+		return fmt.Errorf("session '%s' is not recording, current: %s", id, status)
+	}
+
+	if err := session.recorder.Stop(); err != nil {
+		return fmt.Errorf("failed to stop session '%s': %w", id, err)
+	}
+	if !session.cfg.AutoMix || recording == nil {
+		return nil
+	}
+
+	songs := []string{strings.TrimSuffix(filepath.Base(recording.OutputFile), ".mkv")}
+	if len(recording.Takes) > 1 {
+		songs = songs[:0]
+		for _, take := range recording.Takes {
+			songs = append(songs, strings.TrimSuffix(filepath.Base(take.File), ".mkv"))
+		}
+	}
+	mixer := mix.New(session.cfg)
+	for _, song := range songs {
+		slog.Info("Mixing session take", "session", id, "song", song)
+		if err := mixer.Mix(song); err != nil {
+			return fmt.Errorf("session '%s' stopped but mixing '%s' failed: %w", id, song, err)
+		}
+	}
+	return nil
+}
+
+// RemoveSession releases the recorder of a named session in STANDBY or ERROR
+// and forgets the session
+func (s *JamCaptureService) RemoveSession(id string) error {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return fmt.Errorf("session '%s' not found", id)
+	}
+	if status, _ := session.recorder.GetStatus(); status == audio.StatusReady || status == audio.StatusRecording {
+		return fmt.Errorf("session '%s' is %s, stop it first", id, status)
+	}
+
+	slog.Info("Removing session", "session", id, "profile", session.profile)
+	session.recorder.Cleanup()
+	delete(s.sessions, id)
+	return nil
+}
+
+// Shutdown stops the takes being recorded, keeping what was captured, and
+// releases the default recorder and those of every named session
+func (s *JamCaptureService) Shutdown() {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+
+	release := func(name string, recorder audio.Recorder) {
+		if status, _ := recorder.GetStatus(); status == audio.StatusRecording {
+			if err := recorder.Stop(); err != nil {
+				slog.Error("Failed to stop recording on shutdown", "session", name, "error", err)
+			}
+		}
+		recorder.Cleanup()
+	}
+
+	for id, session := range s.sessions {
+		release(id, session.recorder)
+		delete(s.sessions, id)
+	}
+	release("default", s.recorder)
+	slog.Info("Service shut down")
+}
+
+// GetSession returns the state of a named session
+func (s *JamCaptureService) GetSession(id string) (*SessionStatus, error) {
+	s.sessionsMutex.Lock()
+	session, exists := s.sessions[id]
+	s.sessionsMutex.Unlock()
+	if !exists {
+		return nil, fmt.Errorf("session '%s' not found", id)
+	}
+
+	status := session.status()
+	return &status, nil
+}
+
+// ListSessions returns the state of every named session, sorted by ID
+func (s *JamCaptureService) ListSessions() []SessionStatus {
+	s.sessionsMutex.Lock()
+	sessions := make([]*recorderSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.sessionsMutex.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].id < sessions[j].id })
+	result := make([]SessionStatus, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, session.status())
+	}
+	return result
+}
+
+// status reports the session with its channels in configuration order
+func (r *recorderSession) status() SessionStatus {
+	status, session := r.recorder.GetStatus()
+	result := SessionStatus{
+		ID:       r.id,
+		Profile:  r.profile,
+		Client:   r.client,
+		Status:   convertStatus(status),
+		Session:  convertSession(session),
+		Channels: []ChannelStatus{},
+	}
+
+	channels := convertChannelStatus(r.recorder.GetChannelStatus())
+	for _, channel := range r.cfg.Channels {
+		if state, exists := channels[channel.Name]; exists {
+			result.Channels = append(result.Channels, state)
+		}
+	}
+	if err := r.recorder.GetLastError(); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
 
 // Helper functions
 
@@ -634,10 +1232,17 @@ func (s *JamCaptureService) saveBackingtrackConfig(config *BackingtrackConfig) e
 	return nil
 }
 
-// GetLastError returns the last error message (thread-safe)
+// GetLastError returns the last error message (thread-safe). Without a
+// service error, it reports why the recorder last ended a session on its
+// own, such as a READY timeout.
 func (s *JamCaptureService) GetLastError() string {
 	s.lastErrorMutex.RLock()
 	defer s.lastErrorMutex.RUnlock()
+	if s.lastError == "" {
+		if err := s.recorder.GetLastError(); err != nil {
+			return err.Error()
+		}
+	}
 	return s.lastError
 }
 
@@ -778,6 +1383,13 @@ func (s *JamCaptureService) AnalyzeMKVFile(filename string) (*MKVAnalysis, error
 			Channels: stream.Channels,
 		}
 
+		// Tracks added by an overdub name the take they belong to
+		if take, exists := stream.Tags["take"]; exists && take != "" {
+			track.Take = take
+		} else if take, exists := stream.Tags["TAKE"]; exists && take != "" {
+			track.Take = take
+		}
+
 		tracks = append(tracks, track)
 	}
 
@@ -787,6 +1399,15 @@ func (s *JamCaptureService) AnalyzeMKVFile(filename string) (*MKVAnalysis, error
 		Tracks:     tracks,
 	}
 
+	// An aligned mix leaves the offsets it measured next to the recording
+	offsets, err := mix.LoadAlignment(filePath)
+	if err != nil {
+		slog.Warn("Failed to read the alignment of the last mix", "filename", filename, "error", err)
+	}
+	for _, offset := range offsets {
+		analysis.Alignment = append(analysis.Alignment, TrackOffset(offset))
+	}
+
 	slog.Debug("MKV analysis completed", "filename", filename, "tracks", len(tracks))
 	return analysis, nil
 }
@@ -847,6 +1468,35 @@ func (s *JamCaptureService) MixWithTrackAndGlobalVolumes(filename string, trackV
 	return nil
 }
 
+// MixWithTrackEffects creates a custom mix using the specified track volumes,
+// track pans from -1 (left) to 1 (right), track effects chains replacing
+// those of the profile, and global volume
+func (s *JamCaptureService) MixWithTrackEffects(filename string, trackVolumes, trackPans map[string]float64, trackEffects map[string][]config.Effect, globalVolume float64) error {
+	// Remove .mkv extension to get the song name
+	songName := strings.TrimSuffix(filename, ".mkv")
+
+	// Create mixer with current config
+	mixer := mix.New(s.cfg)
+
+	slog.Info("Starting custom mix with pans and effects", "filename", filename, "song_name", songName, "volumes", trackVolumes, "pans", trackPans, "effects", trackEffects, "global_volume", globalVolume)
+
+	if err := mixer.MixWithChannelEffects(songName, trackVolumes, trackPans, trackEffects, globalVolume); err != nil {
+		s.setLastError(fmt.Sprintf("Custom mix with effects failed for %s: %v", filename, err))
+		return fmt.Errorf("custom mix with effects failed for %s: %w", filename, err)
+	}
+
+	slog.Info("Custom mix with pans and effects completed successfully", "filename", filename, "song_name", songName)
+
+	// Update last mixed file with the generated output filename (FLAC/WAV)
+	outputExtension := s.getOutputExtension()
+	outputFilename := songName + "." + outputExtension
+	if err := s.updateLastMixedFile(outputFilename); err != nil {
+		slog.Error("Failed to update last mixed file", "error", err, "filename", outputFilename)
+	}
+
+	return nil
+}
+
 // formatBytes formats bytes in human readable format
 func formatBytes(bytes int64) string {
 	const unit = 1024
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/audiolibrelab/jamcapture/internal/config"
+)
+
+// sessionTestConfig is a fake backend configuration with a "room" profile
+// recording the guitar and an auto-mixed "remote" profile recording the mic
+const sessionTestConfig = `
+active_config: room
+audio:
+  backend: fake
+  sample_rate: 48000
+  fake:
+    ports: [PORTS]
+definitions:
+  channels:
+    - id: guitar
+      type: input
+      sources: ["fake_guitar:output_FL"]
+      audiomode: mono
+      volume: 1.0
+    - id: mic
+      type: input
+      sources: ["fake_mic:output_FL"]
+      audiomode: mono
+      volume: 1.0
+configs:
+  room:
+    channels:
+      - ref: guitar
+    output:
+      directory: DIR
+      format: flac
+  remote:
+    auto_mix: true
+    channels:
+      - ref: mic
+    output:
+      directory: DIR
+      format: flac
+`
+
+// newSessionTestService returns a service on the room profile whose fake
+// backend exposes ports. Without ports no source is present and every
+// session waits in READY.
+func newSessionTestService(t *testing.T, ports ...string) (*JamCaptureService, string) {
+	dir := t.TempDir()
+	if len(ports) == 0 {
+		ports = []string{"none:output_FL"}
+	}
+	content := strings.NewReplacer("DIR", dir, "PORTS", `"`+strings.Join(ports, `", "`)+`"`).Replace(sessionTestConfig)
+	configFile := filepath.Join(dir, "jamcapture.yaml")
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.LoadWithProfile(configFile, "room")
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	svc := New(cfg, configFile, nil).(*JamCaptureService)
+	t.Cleanup(svc.Shutdown)
+	return svc, dir
+}
+
+// waitForSession waits until session id reaches status
+func waitForSession(t *testing.T, svc *JamCaptureService, id string, status RecordingStatus) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		session, err := svc.GetSession(id)
+		if err != nil {
+			t.Fatalf("GetSession failed: %v", err)
+		}
+		if session.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for session '%s' to be %s, got %s", id, status, session.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestStartSessionReady_ProfileRequiredOnFirstUse(t *testing.T) {
+	svc, _ := newSessionTestService(t)
+
+	if err := svc.StartSessionReady("room", "", "rehearsal"); err == nil || !strings.Contains(err.Error(), "a profile is required to create session 'room'") {
+		t.Fatalf("Expected a profile to be required, got: %v", err)
+	}
+	if sessions := svc.ListSessions(); len(sessions) != 0 {
+		t.Fatalf("Expected no session to be created, got %+v", sessions)
+	}
+
+	if err := svc.StartSessionReady("room", "room", "rehearsal"); err != nil {
+		t.Fatalf("StartSessionReady failed: %v", err)
+	}
+	if err := svc.StopSession("room"); err != nil {
+		t.Fatalf("StopSession failed: %v", err)
+	}
+
+	// Later ready calls keep the session's profile
+	if err := svc.StartSessionReady("room", "", "rehearsal"); err != nil {
+		t.Fatalf("Expected the session's profile to be reused, got: %v", err)
+	}
+	session, err := svc.GetSession("room")
+	if err != nil || session.Profile != "room" || session.Status != StatusReady || session.Client != "jamcapture-room" {
+		t.Errorf("Expected the room session READY on its profile, got %+v (%v)", session, err)
+	}
+	if sessions := svc.ListSessions(); len(sessions) != 1 || sessions[0].ID != "room" {
+		t.Errorf("Expected the room session to be listed, got %+v", sessions)
+	}
+}
+
+func TestStartSessionReady_RefusesProfileSwapWhileReady(t *testing.T) {
+	svc, _ := newSessionTestService(t)
+
+	if err := svc.StartSessionReady("stage", "room", "first"); err != nil {
+		t.Fatalf("StartSessionReady failed: %v", err)
+	}
+	if err := svc.StartSessionReady("stage", "remote", "second"); err == nil || !strings.Contains(err.Error(), "session 'stage' is READY with profile 'room'") {
+		t.Fatalf("Expected the profile swap to be refused while READY, got: %v", err)
+	}
+	if session, _ := svc.GetSession("stage"); session.Profile != "room" || session.Status != StatusReady {
+		t.Errorf("Expected the session to keep its profile and status, got %+v", session)
+	}
+
+	// Back in STANDBY the session switches to the new profile
+	if err := svc.StopSession("stage"); err != nil {
+		t.Fatalf("StopSession failed: %v", err)
+	}
+	waitForSession(t, svc, "stage", StatusStandby)
+	if err := svc.StartSessionReady("stage", "remote", "second"); err != nil {
+		t.Fatalf("Expected the profile swap in STANDBY, got: %v", err)
+	}
+	if session, _ := svc.GetSession("stage"); session.Profile != "remote" || len(session.Channels) != 1 || session.Channels[0].Name != "mic" {
+		t.Errorf("Expected the session on the remote profile, got %+v", session)
+	}
+}
+
+func TestStartSessionReady_RefusesTheSameOutputFile(t *testing.T) {
+	svc, _ := newSessionTestService(t)
+
+	if err := svc.StartSessionReady("room", "room", "Take One"); err != nil {
+		t.Fatalf("StartSessionReady failed: %v", err)
+	}
+	if err := svc.StartSessionReady("remote", "remote", "Take One"); err == nil || !strings.Contains(err.Error(), "already being recorded by session 'room'") {
+		t.Errorf("Expected a second session on the same file to be refused, got: %v", err)
+	}
+	if err := svc.StartReady("Take One"); err == nil || !strings.Contains(err.Error(), "already being recorded by session 'room'") {
+		t.Errorf("Expected the default recorder on the same file to be refused, got: %v", err)
+	}
+	if status, _ := svc.GetRecordingStatus(); status != StatusStandby {
+		t.Errorf("Expected the default recorder to stay in STANDBY, got %s", status)
+	}
+
+	// The other way round, the default recorder owns the file
+	if err := svc.StartReady("Take Two"); err != nil {
+		t.Fatalf("StartReady failed: %v", err)
+	}
+	if err := svc.StartSessionReady("remote", "remote", "Take Two"); err == nil || !strings.Contains(err.Error(), "already being recorded by the default session") {
+		t.Errorf("Expected a session on the default recorder's file to be refused, got: %v", err)
+	}
+	if err := svc.StartSessionReady("remote", "remote", "Take Three"); err != nil {
+		t.Errorf("Expected another song to be recorded, got: %v", err)
+	}
+}
+
+func TestRemoveSession(t *testing.T) {
+	svc, _ := newSessionTestService(t)
+
+	if err := svc.RemoveSession("room"); err == nil || !strings.Contains(err.Error(), "session 'room' not found") {
+		t.Errorf("Expected an unknown session to be refused, got: %v", err)
+	}
+
+	if err := svc.StartSessionReady("room", "room", "rehearsal"); err != nil {
+		t.Fatalf("StartSessionReady failed: %v", err)
+	}
+	if err := svc.RemoveSession("room"); err == nil || !strings.Contains(err.Error(), "stop it first") {
+		t.Errorf("Expected a READY session to be kept, got: %v", err)
+	}
+
+	if err := svc.StopSession("room"); err != nil {
+		t.Fatalf("StopSession failed: %v", err)
+	}
+	if err := svc.RemoveSession("room"); err != nil {
+		t.Fatalf("RemoveSession failed: %v", err)
+	}
+	if _, err := svc.GetSession("room"); err == nil {
+		t.Error("Expected the removed session to be gone")
+	}
+}
+
+func TestStopSession_AutoMix(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not available")
+	}
+
+	svc, dir := newSessionTestService(t, "fake_guitar:output_FL", "fake_mic:output_FL")
+
+	// The room profile keeps its take as recorded
+	if err := svc.StartSessionReady("room", "room", "room take"); err != nil {
+		t.Fatalf("StartSessionReady failed: %v", err)
+	}
+	// The remote profile mixes its take on stop
+	if err := svc.StartSessionReady("remote", "remote", "remote take"); err != nil {
+		t.Fatalf("StartSessionReady failed: %v", err)
+	}
+	waitForSession(t, svc, "room", StatusRecording)
+	waitForSession(t, svc, "remote", StatusRecording)
+	time.Sleep(time.Second)
+
+	for _, id := range []string{"room", "remote"} {
+		if err := svc.StopSession(id); err != nil {
+			t.Fatalf("StopSession %s failed: %v", id, err)
+		}
+	}
+
+	for _, file := range []string{"room_take.mkv", "remote_take.mkv", "remote_take.flac"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("Expected %s: %v", file, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "room_take.flac")); !os.IsNotExist(err) {
+		t.Errorf("Expected no mix without auto_mix, got: %v", err)
+	}
+
+	if err := svc.StopSession("remote"); err == nil || !strings.Contains(err.Error(), "is not recording") {
+		t.Errorf("Expected a stopped session to refuse a second stop, got: %v", err)
+	}
+}